@@ -0,0 +1,86 @@
+package acme
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ibanLengths is the length of the IBANs of each country in the SWIFT IBAN registry
+var ibanLengths = map[string]int{
+	"AD": 24, "AE": 23, "AL": 28, "AT": 20, "AZ": 28, "BA": 20, "BE": 16, "BG": 22, "BH": 22, "BI": 27,
+	"BR": 29, "BY": 28, "CH": 21, "CR": 22, "CY": 28, "CZ": 24, "DE": 22, "DJ": 27, "DK": 18, "DO": 28,
+	"EE": 20, "EG": 29, "ES": 24, "FI": 18, "FK": 18, "FO": 18, "FR": 27, "GB": 22, "GE": 22, "GI": 23,
+	"GL": 18, "GR": 27, "GT": 28, "HR": 21, "HU": 28, "IE": 22, "IL": 23, "IQ": 23, "IS": 26, "IT": 27,
+	"JO": 30, "KW": 30, "KZ": 20, "LB": 28, "LC": 32, "LI": 21, "LT": 20, "LU": 20, "LV": 21, "LY": 25,
+	"MC": 27, "MD": 24, "ME": 22, "MK": 19, "MN": 20, "MR": 27, "MT": 31, "MU": 30, "NI": 28, "NL": 18,
+	"NO": 15, "OM": 23, "PK": 24, "PL": 28, "PS": 29, "PT": 25, "QA": 29, "RO": 24, "RS": 22, "RU": 33,
+	"SA": 24, "SC": 31, "SD": 18, "SE": 24, "SI": 19, "SK": 24, "SM": 27, "SO": 23, "ST": 25, "SV": 28,
+	"TL": 23, "TN": 24, "TR": 26, "UA": 29, "VA": 22, "VG": 24, "XK": 20, "YE": 30,
+}
+
+var (
+	ibanPattern          = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]+$`)
+	bicPattern           = regexp.MustCompile(`^[A-Z]{4}[A-Z]{2}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+	sortCodePattern      = regexp.MustCompile(`^[0-9]{6}$`)
+	accountNumberPattern = regexp.MustCompile(`^[0-9]{8}$`)
+)
+
+// CheckIBAN checks an IBAN in its electronic format, without spaces: the length for its country and the
+// mod-97 check digits
+func CheckIBAN(iban string) error {
+	if !ibanPattern.MatchString(iban) {
+		return fmt.Errorf("IBAN must be a country code, 2 check digits and upper case letters or digits")
+	}
+	country := iban[:2]
+	length, ok := ibanLengths[country]
+	if !ok {
+		return fmt.Errorf("IBAN country %s is not in the IBAN registry", country)
+	}
+	if len(iban) != length {
+		return fmt.Errorf("%s IBANs have %d characters", country, length)
+	}
+	if ibanRemainder(iban) != 1 {
+		return fmt.Errorf("IBAN check digits are not valid")
+	}
+	return nil
+}
+
+// ibanRemainder is the remainder of the IBAN as an integer divided by 97, where the first 4 characters
+// are moved to the end and each letter is replaced by two digits, A being 10
+func ibanRemainder(iban string) int {
+	remainder := 0
+	for _, c := range iban[4:] + iban[:4] {
+		if c >= 'A' {
+			remainder = (remainder*100 + int(c-'A') + 10) % 97
+		} else {
+			remainder = (remainder*10 + int(c-'0')) % 97
+		}
+	}
+	return remainder
+}
+
+// CheckBIC checks the format of a BIC: a 4 letter institution code, a 2 letter country code, a 2
+// character location code and an optional 3 character branch code
+func CheckBIC(bic string) error {
+	if !bicPattern.MatchString(bic) {
+		return fmt.Errorf("BIC must be 8 or 11 upper case letters or digits, " +
+			"with letters for the institution and country codes")
+	}
+	return nil
+}
+
+// CheckSortCode checks that a UK sort code is 6 digits, without dashes
+func CheckSortCode(sortCode string) error {
+	if !sortCodePattern.MatchString(sortCode) {
+		return fmt.Errorf("sort code must be 6 digits")
+	}
+	return nil
+}
+
+// CheckUKAccountNumber checks that a UK account number is 8 digits
+func CheckUKAccountNumber(accountNumber string) error {
+	if !accountNumberPattern.MatchString(accountNumber) {
+		return fmt.Errorf("UK account numbers must be 8 digits")
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package acme_test
+
+import (
+	"testing"
+
+	"github.com/steinfletcher/payments"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckIBAN(t *testing.T) {
+	for _, iban := range []string{
+		"GB29NWBK60161331926819",
+		"GB83XABC10161234567801",
+		"DE89370400440532013000",
+		"FR1420041010050500013M02606",
+		"NO9386011117947",
+		"MT84MALT011000012345MTLCAST001S",
+	} {
+		assert.NoError(t, acme.CheckIBAN(iban), iban)
+	}
+}
+
+func TestCheckIBAN_RejectsInvalidIBANs(t *testing.T) {
+	tests := map[string]string{
+		"":                            "IBAN must be a country code, 2 check digits and upper case letters or digits",
+		"GB29 NWBK 6016 1331 9268 19": "IBAN must be a country code, 2 check digits and upper case letters or digits",
+		"gb29nwbk60161331926819":      "IBAN must be a country code, 2 check digits and upper case letters or digits",
+		"US29NWBK60161331926819":      "IBAN country US is not in the IBAN registry",
+		"GB29NWBK6016133192681":       "GB IBANs have 22 characters",
+		"DE8937040044053201300000":    "DE IBANs have 22 characters",
+		"GB28NWBK60161331926819":      "IBAN check digits are not valid",
+		"GB29NWBK60161331926818":      "IBAN check digits are not valid",
+	}
+	for iban, message := range tests {
+		err := acme.CheckIBAN(iban)
+		if assert.Error(t, err, iban) {
+			assert.Equal(t, message, err.Error(), iban)
+		}
+	}
+}
+
+func TestCheckBIC(t *testing.T) {
+	for _, bic := range []string{"NWBKGB2L", "COBADEFFXXX", "PSSTFRPPPAR", "DEUTDEDB101"} {
+		assert.NoError(t, acme.CheckBIC(bic), bic)
+	}
+	for _, bic := range []string{"", "NWBKGB2", "NWBKGB2LX", "nwbkgb2l", "NWB1GB2L", "NWBK1B2L", "NWBKGB2L-XX"} {
+		assert.Error(t, acme.CheckBIC(bic), bic)
+	}
+}
+
+func TestCheckSortCode(t *testing.T) {
+	assert.NoError(t, acme.CheckSortCode("403000"))
+	for _, sortCode := range []string{"", "40300", "4030000", "40-30-00", "40300A"} {
+		assert.Error(t, acme.CheckSortCode(sortCode), sortCode)
+	}
+}
+
+func TestCheckUKAccountNumber(t *testing.T) {
+	assert.NoError(t, acme.CheckUKAccountNumber("31926819"))
+	for _, accountNumber := range []string{"", "3192681", "319268190", "3192681X"} {
+		assert.Error(t, acme.CheckUKAccountNumber(accountNumber), accountNumber)
+	}
+}
@@ -2,113 +2,328 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/steinfletcher/payments"
+	"github.com/steinfletcher/payments/scheme"
 	"github.com/xeipuuv/gojsonschema"
 )
 
 type Server struct {
-	Router  *gin.Engine
-	service acme.PaymentService
-	server  *http.Server
+	Router         *gin.Engine
+	service        acme.PaymentService
+	approvals      acme.ApprovalService
+	idempotency    acme.IdempotencyService
+	schemes        *scheme.Registry
+	authenticators Authenticators
+	server         *http.Server
 }
 
 var jsonSchemaValidator = gojsonschema.NewStringLoader(acme.AttributesSchema)
 
 // NewServer creates a new server with all application routes defined
 // The caller must call `Start` to bind to the network and start serving requests
-func NewServer(service acme.PaymentService) *Server {
+func NewServer(service acme.PaymentService, approvals acme.ApprovalService, idempotency acme.IdempotencyService,
+	schemes *scheme.Registry, authenticators Authenticators, policy Policy) *Server {
 	r := gin.Default()
 
-	srv := &Server{Router: r, service: service}
+	srv := &Server{Router: r, service: service, approvals: approvals, idempotency: idempotency, schemes: schemes,
+		authenticators: authenticators}
 	r.GET("/health", srv.healthCheck)
 
 	v1 := r.Group("/v1")
-	v1.Use(errorHandler)
-
-	v1.GET("/payment", srv.getAllPayments)
-	v1.GET("/payment/:id", srv.getPayment)
-	v1.POST("/payment", srv.createPayment)
-	v1.PUT("/payment/:id", srv.updatePayment)
-	v1.DELETE("/payment/:id", srv.deletePayment)
+	v1.Use(errorHandler, srv.authenticate)
+
+	read, write, remove := authorize(policy.Read), authorize(policy.Write), authorize(policy.Delete)
+	v1.GET("/payment", read, srv.getAllPayments)
+	v1.GET("/payment/:id", read, srv.getPayment)
+	v1.GET("/payment/:id/versions", read, srv.getPaymentVersions)
+	v1.GET("/payment/:id/versions/:version", read, srv.getPaymentVersion)
+	v1.GET("/payment/:id/versions/:version/diff", read, srv.diffPaymentVersion)
+	v1.GET("/payment/:id/approvals", read, srv.getPaymentApprovals)
+	v1.GET("/payment/:id/mt103", read, srv.exportMT103)
+	v1.GET("/export/pain001", read, srv.exportPain001)
+	v1.POST("/export/bacs", write, srv.exportBACS)
+	v1.GET("/export/sepa", read, srv.exportSEPA)
+	// imports are not under /payment, as the router cannot tell /payment/import from /payment/:id
+	v1.POST("/import/pacs008", write, srv.importPacs008)
+	v1.POST("/import/mt103", write, srv.importMT103)
+	v1.POST("/payment", write, srv.createPayment)
+	v1.PUT("/payment/:id", write, srv.updatePayment)
+	v1.PATCH("/payment/:id", write, srv.patchPayment)
+	v1.DELETE("/payment/:id", remove, srv.deletePayment)
+	// approving and rejecting a payment are decisions of an approver, which the approval service records
+	decisions := map[acme.Action]transition{acme.ActionApprove: approvals.Approve, acme.ActionReject: approvals.Reject}
+	for _, action := range acme.Actions() {
+		t, ok := decisions[action]
+		if !ok {
+			t = srv.transition(action)
+		}
+		v1.POST("/payment/:id/"+string(action), write, srv.transitionPayment(t))
+	}
 
 	return srv
 }
 
 func (r *Server) createPayment(ctx *gin.Context) {
-	payment := acme.Payment{}
-	err := ctx.Bind(&payment)
+	payment, sent, err := bindPayment(ctx)
 	if err != nil {
 		ctx.Error(acme.InvalidRequestBody)
 		return
 	}
 
-	err = validatePayment(payment)
+	err = r.validatePayment(payment, sent.Attributes)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	err = checkOrganisation(ctx, payment)
 	if err != nil {
 		ctx.Error(err)
 		return
 	}
 
-	id, err := r.service.Create(payment)
+	organisationID := organisation(ctx)
+	key := ctx.GetHeader(idempotencyKeyHeader)
+	if key != "" {
+		replayed, err := r.reserveIdempotencyKey(ctx, organisationID, key, payment)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		if replayed {
+			return
+		}
+	}
+
+	payment.RecordedBy = principal(ctx).Subject
+	id, err := r.service.Create(organisationID, payment)
 	if err != nil {
+		r.releaseIdempotencyKey(organisationID, key)
 		ctx.Error(err)
 		return
 	}
 
-	ctx.Header("Location", id.String())
-	ctx.AbortWithStatus(http.StatusCreated)
+	response := acme.IdempotentResponse{
+		StatusCode: http.StatusCreated,
+		Header:     map[string]string{"Location": id.String()},
+	}
+	r.completeIdempotencyKey(organisationID, key, response)
+	writeResponse(ctx, response)
+}
+
+// sentPayment is what binding a payment document to acme.Payment loses: the attributes as they are
+// written in it and whether it has a version
+type sentPayment struct {
+	Attributes json.RawMessage `json:"attributes"`
+	Version    *int            `json:"version"`
+}
+
+// bindPayment reads the payment of the request body, and the payment as it was sent
+func bindPayment(ctx *gin.Context) (acme.Payment, sentPayment, error) {
+	body, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		return acme.Payment{}, sentPayment{}, err
+	}
+	var payment acme.Payment
+	err = json.Unmarshal(body, &payment)
+	if err != nil {
+		return acme.Payment{}, sentPayment{}, err
+	}
+	var sent sentPayment
+	err = json.Unmarshal(body, &sent)
+	return payment, sent, err
 }
 
-func validatePayment(p acme.Payment) error {
+// validatePayment checks a payment against the attributes schema, the precision of its amounts, the
+// structure of the accounts of its parties and the rules of its payment scheme. The schema checks the
+// attributes as they were sent, since Attributes leaves out empty strings and an empty string is a valid
+// value of a required field.
+func (r *Server) validatePayment(p acme.Payment, attributes json.RawMessage) error {
 	if p.OrganisationID == uuid.Nil {
 		err := acme.InvalidField
 		err.Detail = "organisation Id must be provided"
+		err.Meta = []acme.FieldError{{
+			Pointer: "/organisation_id",
+			Code:    "REQUIRED",
+			Message: "organisation_id is required",
+		}}
 		return err
 	}
-	result, err := gojsonschema.Validate(jsonSchemaValidator, gojsonschema.NewGoLoader(p.Attributes))
+	if len(attributes) == 0 {
+		attributes = json.RawMessage("{}")
+	}
+	result, err := gojsonschema.Validate(jsonSchemaValidator, gojsonschema.NewBytesLoader(attributes))
 	if err != nil {
 		return acme.ServerError
 	}
 	if !result.Valid() {
 		err := acme.InvalidField
 		err.Detail = fmt.Sprintf("invalid attributes: %s", result.Errors())
+		err.Meta = schemaFieldErrors(result.Errors())
+		return err
+	}
+	if errs := append(validateAmounts(p.Attributes), validateParties(p.Attributes)...); len(errs) > 0 {
+		err := acme.InvalidField
+		err.Detail = fmt.Sprintf("invalid attributes: %s", describeFieldErrors(errs))
+		err.Meta = errs
+		return err
+	}
+	if errs := r.schemes.Validate(p.Attributes); len(errs) > 0 {
+		err := acme.InvalidField
+		err.Detail = fmt.Sprintf("invalid attributes: %s", describeFieldErrors(errs))
+		err.Meta = errs
 		return err
 	}
 	return nil
 }
 
-func (r *Server) getPayment(ctx *gin.Context) {
-	id := ctx.Param("id")
-	externalID, err := uuid.Parse(id)
-	if err != nil {
-		ctx.Error(acme.InvalidID)
-		return
+// schemaFieldErrors converts the errors of the attributes schema into field errors. The code of a field
+// error is the type of the schema error, e.g. REQUIRED or PATTERN.
+func schemaFieldErrors(results []gojsonschema.ResultError) []acme.FieldError {
+	var errs []acme.FieldError
+	for _, result := range results {
+		var path []string
+		if field := result.Field(); field != "(root)" {
+			path = strings.Split(field, ".")
+		}
+		// a missing property is reported against the object that should contain it
+		if property, ok := result.Details()["property"].(string); ok && result.Type() == "required" {
+			path = append(path, property)
+		}
+		errs = append(errs, acme.FieldError{
+			Pointer: attributePointer(path...),
+			Code:    strings.ToUpper(result.Type()),
+			Message: result.Description(),
+		})
+	}
+	return errs
+}
+
+// attributePointer is the JSON pointer to an attribute of the request body
+func attributePointer(path ...string) string {
+	pointer := "/attributes"
+	for _, token := range path {
+		pointer += "/" + acme.EscapePointer(token)
+	}
+	return pointer
+}
+
+// validateAmounts checks that every currency is an ISO 4217 currency and that every amount has exactly
+// as many decimal places as the minor unit of its currency. The schema has already checked that the
+// amounts are well formed and that the objects are present.
+func validateAmounts(a acme.Attributes) []acme.FieldError {
+	var errs []acme.FieldError
+	fieldError := func(field, code string, err error) {
+		errs = append(errs, acme.FieldError{
+			Pointer: attributePointer(strings.Split(field, ".")...),
+			Code:    code,
+			Message: err.Error(),
+		})
 	}
+	check := func(amountField, currencyField, amount, currency string) {
+		if err := acme.CheckCurrency(currency); err != nil {
+			fieldError(currencyField, "UNKNOWN_CURRENCY", err)
+			return
+		}
+		money, err := acme.ParseMoney(amount, currency)
+		if err == nil {
+			err = money.CheckMinorUnits()
+		}
+		if err != nil {
+			fieldError(amountField, "INVALID_AMOUNT", err)
+		}
+	}
+
+	check("amount", "currency", a.Amount, a.Currency)
+	for i, charge := range a.ChargesInformation.SenderCharges {
+		field := fmt.Sprintf("charges_information.sender_charges.%d.", i)
+		check(field+"amount", field+"currency", charge.Amount, charge.Currency)
+	}
+	charges := a.ChargesInformation
+	check("charges_information.receiver_charges_amount", "charges_information.receiver_charges_currency",
+		charges.ReceiverChargesAmount, charges.ReceiverChargesCurrency)
+	check("fx.original_amount", "fx.original_currency", a.FX.OriginalAmount, a.FX.OriginalCurrency)
+	if _, err := a.FX.Rate(); err != nil {
+		fieldError("fx.exchange_rate", "INVALID_AMOUNT", err)
+	}
+	return errs
+}
+
+// describeFieldErrors lists attribute errors in the same "field: message" form as the schema errors
+func describeFieldErrors(errs []acme.FieldError) []string {
+	var descriptions []string
+	for _, err := range errs {
+		field := strings.Replace(strings.TrimPrefix(err.Pointer, "/attributes/"), "/", ".", -1)
+		descriptions = append(descriptions, field+": "+err.Message)
+	}
+	return descriptions
+}
 
-	payment, err := r.service.Get(externalID)
+func (r *Server) getPayment(ctx *gin.Context) {
+	payment, err := r.requestedPayment(ctx)
 	if err != nil {
 		ctx.Error(err)
 		return
 	}
 
+	if acceptsXML(ctx) {
+		if err := checkApproved(payment); err != nil {
+			ctx.Error(err)
+			return
+		}
+		ctx.Header("ETag", etag(payment.Version))
+		r.writePain001(ctx, []acme.Payment{payment})
+		return
+	}
+	ctx.Header("ETag", etag(payment.Version))
 	ctx.JSON(http.StatusOK, payment)
 }
 
+// requestedPayment reads the payment of the path, as it is now or as it was at the as_of parameter
+func (r *Server) requestedPayment(ctx *gin.Context) (acme.Payment, error) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return acme.Payment{}, acme.InvalidID
+	}
+
+	asOf, err := asOfParameter(ctx)
+	if err != nil {
+		return acme.Payment{}, err
+	}
+	if asOf.IsZero() {
+		return r.service.Get(organisation(ctx), id)
+	}
+	return r.service.GetAsOf(organisation(ctx), id, asOf)
+}
+
 func (r *Server) getAllPayments(ctx *gin.Context) {
-	payments, err := r.service.GetAll()
+	query, err := paymentQuery(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	page, err := r.service.List(organisation(ctx), query)
 	if err != nil {
 		ctx.Error(err)
 		return
 	}
-	ctx.JSON(http.StatusOK, payments)
+
+	ctx.JSON(http.StatusOK, acme.Payments{
+		Data:  page.Payments,
+		Links: pageLinks(ctx.Request.URL, page),
+	})
 }
 
 func (r *Server) updatePayment(ctx *gin.Context) {
@@ -119,19 +334,44 @@ func (r *Server) updatePayment(ctx *gin.Context) {
 		return
 	}
 
-	payment := acme.Payment{}
-	err = ctx.Bind(&payment)
+	payment, sent, err := bindPayment(ctx)
 	if err != nil {
 		ctx.Error(acme.InvalidField)
 		return
 	}
 
-	err = r.service.Update(externalID, payment)
+	err = r.validatePayment(payment, sent.Attributes)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	err = checkOrganisation(ctx, payment)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	// the If-Match header takes precedence over the version in the body, and without either the payment
+	// is updated whatever its version
+	expected, ok, err := ifMatch(ctx)
 	if err != nil {
 		ctx.Error(err)
 		return
 	}
+	if ok {
+		payment.Version = expected
+	} else if sent.Version == nil {
+		payment.Version = acme.AnyVersion
+	}
+
+	payment.RecordedBy = principal(ctx).Subject
+	version, err := r.service.Update(organisation(ctx), externalID, payment)
+	if err != nil {
+		ctx.Error(preconditionError(err, ok && expected != acme.AnyVersion))
+		return
+	}
 
+	ctx.Header("ETag", etag(version))
 	ctx.AbortWithStatus(http.StatusOK)
 }
 
@@ -143,15 +383,65 @@ func (r *Server) deletePayment(ctx *gin.Context) {
 		return
 	}
 
-	err := r.service.Delete(externalID)
+	// without an If-Match header the payment is deleted whatever its version
+	version, ok, err := ifMatch(ctx)
 	if err != nil {
 		ctx.Error(err)
 		return
 	}
+	if !ok {
+		version = acme.AnyVersion
+	}
+
+	err = r.service.Delete(organisation(ctx), externalID, version, principal(ctx).Subject)
+	if err != nil {
+		ctx.Error(preconditionError(err, version != acme.AnyVersion))
+		return
+	}
 
 	ctx.AbortWithStatus(http.StatusOK)
 }
 
+// transition takes an action of the payment lifecycle on the given version of a payment on behalf of a client
+type transition func(organisationID, id uuid.UUID, version int, recordedBy string) (acme.Payment, error)
+
+// transition takes an action with the payment service
+func (r *Server) transition(action acme.Action) transition {
+	return func(organisationID, id uuid.UUID, version int, recordedBy string) (acme.Payment, error) {
+		return r.service.Transition(organisationID, id, action, version, recordedBy)
+	}
+}
+
+// transitionPayment takes an action of the payment lifecycle and responds with the payment in its new
+// status. Like a delete, the action is taken whatever the version of the payment without an If-Match header.
+func (r *Server) transitionPayment(take transition) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		externalID, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			ctx.Error(acme.InvalidID)
+			return
+		}
+
+		version, ok, err := ifMatch(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		if !ok {
+			version = acme.AnyVersion
+		}
+
+		payment, err := take(organisation(ctx), externalID, version, principal(ctx).Subject)
+		if err != nil {
+			ctx.Error(preconditionError(err, version != acme.AnyVersion))
+			return
+		}
+
+		ctx.Header("ETag", etag(payment.Version))
+		ctx.JSON(http.StatusOK, payment)
+	}
+}
+
 func (r *Server) healthCheck(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{
 		"status": "OK",
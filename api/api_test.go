@@ -1,280 +1,2291 @@
 package api_test
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	m "github.com/petergtz/pegomock"
+	"github.com/pkg/errors"
 	"github.com/steinfletcher/apitest"
 	jsonpath "github.com/steinfletcher/apitest-jsonpath"
 	acme "github.com/steinfletcher/payments"
 	"github.com/steinfletcher/payments/api"
+	"github.com/steinfletcher/payments/iso20022"
 	"github.com/steinfletcher/payments/mocks"
+	"github.com/steinfletcher/payments/mt103"
+	"github.com/steinfletcher/payments/scheme"
 	"github.com/steinfletcher/payments/test"
 	"github.com/stretchr/testify/assert"
 )
 
+// organisationID is the organisation that requests are made on behalf of, which owns the test payments
+var organisationID = uuid.MustParse("743d5b63-8e6f-432e-a8fa-c5d8d2ee5fcb")
+
+// apiKey authenticates requests on behalf of organisationID and otherAPIKey on behalf of otherOrganisationID.
+// Both have every scope. readOnlyAPIKey acts for organisationID with the payments:read scope only.
+const (
+	apiKey         = "test-api-key"
+	otherAPIKey    = "other-test-api-key"
+	readOnlyAPIKey = "read-only-test-api-key"
+)
+
+var allScopes = []string{acme.ScopeRead, acme.ScopeWrite, acme.ScopeDelete}
+
+var otherOrganisationID = uuid.MustParse("57a3b643-cf4f-4f70-8636-0ddcdec07d68")
+
 func TestCreatePayment_Success(t *testing.T) {
 	id := uuid.New()
-	var payment acme.Payment
-	readJSON("testdata/create_payment.json", &payment)
+	var payment acme.Payment
+	readJSON("testdata/create_payment.json", &payment)
+
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Create(organisationID, recorded(payment))).ThenReturn(id, nil)
+
+	apiTest(paymentService).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(readFile("testdata/create_payment.json")).
+		Expect(t).
+		Status(http.StatusCreated).
+		Header("Location", id.String()).
+		End()
+}
+
+func TestCreatePayment_InvalidAttributes(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(readFile("testdata/create_payment_with_invalid_attributes.json")).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		HeaderNotPresent("Location").
+		Header("Content-Type", "application/problem+json").
+		Body(`{
+			"type": "/problems/invalid-field",
+			"title": "Invalid field",
+			"status": 400,
+			"detail": "invalid attributes: [(root): currency is required]",
+			"instance": "/v1/payment",
+			"code": "INVALID_FIELD",
+			"errors": [{
+				"pointer": "/attributes/currency",
+				"code": "REQUIRED",
+				"message": "currency is required"
+			}]
+		}`).
+		End()
+}
+
+func TestCreatePayment_MalformedAmount(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(readFile("testdata/create_payment_with_malformed_amount.json")).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		HeaderNotPresent("Location").
+		Assert(problem("INVALID_FIELD", "invalid attributes: [amount: Does not match pattern '^[0-9]+(\\.[0-9]+)?$']")).
+		Assert(jsonpath.Equal("$.errors[0].pointer", "/attributes/amount")).
+		Assert(jsonpath.Equal("$.errors[0].code", "PATTERN")).
+		End()
+}
+
+func TestCreatePayment_AmountMorePreciseThanCurrency(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(readFile("testdata/create_payment_with_imprecise_amount.json")).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		HeaderNotPresent("Location").
+		Body(`{
+			"type": "/problems/invalid-field",
+			"title": "Invalid field",
+			"status": 400,
+			"detail": "invalid attributes: [amount: GBP amounts have at most 2 decimal places]",
+			"instance": "/v1/payment",
+			"code": "INVALID_FIELD",
+			"errors": [{
+				"pointer": "/attributes/amount",
+				"code": "INVALID_AMOUNT",
+				"message": "GBP amounts have at most 2 decimal places"
+			}]
+		}`).
+		End()
+}
+
+func TestCreatePayment_AmountLessPreciseThanCurrency(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(strings.Replace(readFile("testdata/create_payment.json"), `"amount": "100.21"`, `"amount": "100"`, 1)).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		HeaderNotPresent("Location").
+		Assert(problem("INVALID_FIELD", "invalid attributes: [amount: GBP amounts have exactly 2 decimal places]")).
+		Assert(jsonpath.Equal("$.errors[0].pointer", "/attributes/amount")).
+		Assert(jsonpath.Equal("$.errors[0].code", "INVALID_AMOUNT")).
+		End()
+}
+
+func TestCreatePayment_UnknownCurrency(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(strings.Replace(readFile("testdata/create_payment.json"), `"original_currency": "USD"`,
+			`"original_currency": "XYZ"`, 1)).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		HeaderNotPresent("Location").
+		Body(`{
+			"type": "/problems/invalid-field",
+			"title": "Invalid field",
+			"status": 400,
+			"detail": "invalid attributes: [fx.original_currency: XYZ is not an ISO 4217 currency code]",
+			"instance": "/v1/payment",
+			"code": "INVALID_FIELD",
+			"errors": [{
+				"pointer": "/attributes/fx/original_currency",
+				"code": "UNKNOWN_CURRENCY",
+				"message": "XYZ is not an ISO 4217 currency code"
+			}]
+		}`).
+		End()
+}
+
+func TestCreatePayment_OtherOrganisation(t *testing.T) {
+	var payment acme.Payment
+	readJSON("testdata/create_payment.json", &payment)
+	paymentService := mocks.NewMockPaymentService()
+
+	apiTest(paymentService).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+otherAPIKey).
+		JSON(readFile("testdata/create_payment.json")).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		HeaderNotPresent("Location").
+		Assert(problem("ORGANISATION_MISMATCH", "The payment does not belong to the organisation of the request")).
+		Assert(jsonpath.Equal("$.errors[0].pointer", "/organisation_id")).
+		End()
+
+	paymentService.VerifyWasCalled(m.Never()).Create(otherOrganisationID, payment)
+}
+
+func TestPayments_Unauthenticated(t *testing.T) {
+	tests := map[string]struct {
+		authorization string
+		detail        string
+	}{
+		"missing":        {"", "The Authorization header must be set"},
+		"unknown scheme": {"Basic dXNlcjpwYXNz", "The Authorization header must use one of the schemes ApiKey, Bearer"},
+		"invalid key":    {"ApiKey invalid", "The API key is not valid"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			apiTest(mocks.NewMockPaymentService()).
+				Get("/v1/payment").
+				Header("Authorization", test.authorization).
+				Expect(t).
+				Status(http.StatusUnauthorized).
+				Header("WWW-Authenticate", "ApiKey, Bearer").
+				Assert(problem("UNAUTHORIZED", test.detail)).
+				End()
+		})
+	}
+}
+
+func TestPayments_AuthenticatedWithoutOrganisation(t *testing.T) {
+	authenticator := mocks.NewMockAuthenticator()
+	m.When(authenticator.Authenticate("token")).ThenReturn(acme.Principal{Subject: "client"}, nil)
+
+	apitest.New().
+		Handler(api.NewServer(mocks.NewMockPaymentService(), mocks.NewMockApprovalService(),
+			mocks.NewMockIdempotencyService(), schemes(), api.Authenticators{"Bearer": authenticator},
+			api.DefaultPolicy).Router).
+		Get("/v1/payment").
+		Header("Authorization", "Bearer token").
+		Expect(t).
+		Status(http.StatusUnauthorized).
+		Assert(problem("UNAUTHORIZED", "The request is not authenticated")).
+		End()
+}
+
+func TestPayments_AuthenticationError(t *testing.T) {
+	authenticator := mocks.NewMockAuthenticator()
+	m.When(authenticator.Authenticate("token")).ThenReturn(acme.Principal{}, acme.ServerError)
+
+	apitest.New().
+		Handler(api.NewServer(mocks.NewMockPaymentService(), mocks.NewMockApprovalService(),
+			mocks.NewMockIdempotencyService(), schemes(), api.Authenticators{"Bearer": authenticator},
+			api.DefaultPolicy).Router).
+		Get("/v1/payment").
+		Header("Authorization", "bearer token").
+		Expect(t).
+		Status(http.StatusInternalServerError).
+		HeaderNotPresent("WWW-Authenticate").
+		Assert(problem("SERVER_ERROR", "Sorry, something went wrong")).
+		End()
+}
+
+func TestPayments_Forbidden(t *testing.T) {
+	id := uuid.New()
+	tests := map[string]struct {
+		method string
+		url    string
+		scope  string
+	}{
+		"create": {http.MethodPost, "/v1/payment", acme.ScopeWrite},
+		"update": {http.MethodPut, fmt.Sprintf("/v1/payment/%s", id), acme.ScopeWrite},
+		"patch":  {http.MethodPatch, fmt.Sprintf("/v1/payment/%s", id), acme.ScopeWrite},
+		"delete": {http.MethodDelete, fmt.Sprintf("/v1/payment/%s", id), acme.ScopeDelete},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			apiTest(mocks.NewMockPaymentService()).
+				Method(test.method).
+				URL(test.url).
+				Header("Authorization", "ApiKey "+readOnlyAPIKey).
+				JSON(readFile("testdata/create_payment.json")).
+				Expect(t).
+				Status(http.StatusForbidden).
+				Assert(problem("FORBIDDEN", "The request requires one of the scopes "+test.scope)).
+				End()
+		})
+	}
+}
+
+func TestPayments_ReadOnlyCanRead(t *testing.T) {
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.List(organisationID, acme.PaymentQuery{Limit: 20})).ThenReturn(acme.PaymentPage{}, nil)
+
+	apiTest(paymentService).
+		Get("/v1/payment").
+		Header("Authorization", "ApiKey "+readOnlyAPIKey).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+}
+
+func TestPayments_ConfiguredPolicy(t *testing.T) {
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Delete(organisationID, uuid.Nil, acme.AnyVersion, "test")).ThenReturn(nil)
+	policy := api.Policy{Read: []string{acme.ScopeRead}, Delete: []string{"payments:admin", acme.ScopeRead}}
+
+	apitest.New().
+		Handler(api.NewServer(paymentService, mocks.NewMockApprovalService(), mocks.NewMockIdempotencyService(),
+			schemes(), authenticators(), policy).Router).
+		Delete(fmt.Sprintf("/v1/payment/%s", uuid.Nil)).
+		Header("Authorization", "ApiKey "+readOnlyAPIKey).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	apitest.New().
+		Handler(api.NewServer(paymentService, mocks.NewMockApprovalService(), mocks.NewMockIdempotencyService(),
+			schemes(), authenticators(), policy).Router).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(readFile("testdata/create_payment.json")).
+		Expect(t).
+		Status(http.StatusForbidden).
+		Assert(problem("FORBIDDEN", "The client is not allowed to perform this operation")).
+		End()
+}
+
+func TestCreatePayment_BreaksSchemeRules(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(strings.Replace(readFile("testdata/create_payment.json"), `"payment_scheme": "FPS"`,
+			`"payment_scheme": "SEPA"`, 1)).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		HeaderNotPresent("Location").
+		Assert(jsonpath.Equal("$.code", acme.InvalidField.Code)).
+		Assert(jsonpath.Equal("$.errors[0].pointer", "/attributes/currency")).
+		Assert(jsonpath.Equal("$.errors[0].code", "UNSUPPORTED_CURRENCY")).
+		Assert(jsonpath.Equal("$.errors[0].message", "SEPA payments must be in EUR")).
+		Assert(jsonpath.Equal("$.errors[1].pointer", "/attributes/debtor_party/bank_id")).
+		Assert(jsonpath.Equal("$.errors[1].code", "BIC_REQUIRED")).
+		End()
+}
+
+func TestCreatePayment_InvalidPartyAccounts(t *testing.T) {
+	body := strings.NewReplacer(
+		`"GB83XABC10161234567801"`, `"GB84XABC10161234567801"`,
+		`"403000"`, `"40-30-00"`,
+	).Replace(readFile("testdata/create_payment.json"))
+
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(body).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		HeaderNotPresent("Location").
+		Assert(jsonpath.Equal("$.code", acme.InvalidField.Code)).
+		Assert(jsonpath.Equal("$.errors[0].pointer", "/attributes/debtor_party/account_number")).
+		Assert(jsonpath.Equal("$.errors[0].code", "INVALID_IBAN")).
+		Assert(jsonpath.Equal("$.errors[0].message", "IBAN check digits are not valid")).
+		Assert(jsonpath.Equal("$.errors[1].pointer", "/attributes/beneficiary_party/bank_id")).
+		Assert(jsonpath.Equal("$.errors[1].code", "INVALID_SORT_CODE")).
+		End()
+}
+
+func TestCreatePayment_WithoutMandatoryField(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(readFile("testdata/create_payment_without_mandatory_field.json")).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		HeaderNotPresent("Location").
+		Assert(problem("INVALID_FIELD", "organisation Id must be provided")).
+		Assert(jsonpath.Equal("$.errors[0].pointer", "/organisation_id")).
+		Assert(jsonpath.Equal("$.errors[0].code", "REQUIRED")).
+		End()
+}
+
+func TestCreatePayment_EmptyMandatoryField(t *testing.T) {
+	id := uuid.New()
+	body := strings.Replace(readFile("testdata/create_payment.json"), `"Paying for goods/services"`, `""`, 1)
+	var payment acme.Payment
+	assert.NoError(t, json.Unmarshal([]byte(body), &payment))
+
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Create(organisationID, recorded(payment))).ThenReturn(id, nil)
+
+	apiTest(paymentService).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(body).
+		Expect(t).
+		Status(http.StatusCreated).
+		Header("Location", id.String()).
+		End()
+}
+
+func TestCreatePayment_WithoutSenderCharges(t *testing.T) {
+	body := readFile("testdata/create_payment.json")
+	start, end := strings.Index(body, `"sender_charges"`), strings.Index(body, `"receiver_charges_amount"`)
+
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(body[:start] + body[end:]).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Assert(jsonpath.Equal("$.errors[0].pointer", "/attributes/charges_information/sender_charges")).
+		Assert(jsonpath.Equal("$.errors[0].code", "REQUIRED")).
+		End()
+}
+
+func TestCreatePayment_IdempotencyKey(t *testing.T) {
+	id := uuid.New()
+	var payment acme.Payment
+	readJSON("testdata/create_payment.json", &payment)
+
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Create(organisationID, recorded(payment))).ThenReturn(id, nil)
+	idempotency := mocks.NewMockIdempotencyService()
+	m.When(idempotency.Reserve(organisationID, "key-1", fingerprint(payment))).ThenReturn(nil, nil)
+
+	idempotentAPITest(paymentService, idempotency).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Idempotency-Key", "key-1").
+		JSON(readFile("testdata/create_payment.json")).
+		Expect(t).
+		Status(http.StatusCreated).
+		Header("Location", id.String()).
+		End()
+
+	idempotency.VerifyWasCalledOnce().Complete(organisationID, "key-1", acme.IdempotentResponse{
+		StatusCode: http.StatusCreated,
+		Header:     map[string]string{"Location": id.String()},
+	})
+}
+
+func TestCreatePayment_IdempotencyKeyReplaysResponse(t *testing.T) {
+	id := uuid.New()
+	var payment acme.Payment
+	readJSON("testdata/create_payment.json", &payment)
+
+	paymentService := mocks.NewMockPaymentService()
+	idempotency := mocks.NewMockIdempotencyService()
+	m.When(idempotency.Reserve(organisationID, "key-1", fingerprint(payment))).ThenReturn(&acme.IdempotentResponse{
+		StatusCode: http.StatusCreated,
+		Header:     map[string]string{"Location": id.String()},
+	}, nil)
+
+	idempotentAPITest(paymentService, idempotency).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Idempotency-Key", "key-1").
+		JSON(readFile("testdata/create_payment.json")).
+		Expect(t).
+		Status(http.StatusCreated).
+		Header("Location", id.String()).
+		Header("Idempotent-Replayed", "true").
+		End()
+
+	paymentService.VerifyWasCalled(m.Never()).Create(organisationID, payment)
+}
+
+func TestCreatePayment_IdempotencyKeyReused(t *testing.T) {
+	var payment acme.Payment
+	readJSON("testdata/create_payment.json", &payment)
+
+	paymentService := mocks.NewMockPaymentService()
+	idempotency := mocks.NewMockIdempotencyService()
+	m.When(idempotency.Reserve(organisationID, "key-1", fingerprint(payment))).
+		ThenReturn(nil, acme.IdempotencyKeyReused)
+
+	idempotentAPITest(paymentService, idempotency).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Idempotency-Key", "key-1").
+		JSON(readFile("testdata/create_payment.json")).
+		Expect(t).
+		Status(http.StatusUnprocessableEntity).
+		Assert(problem("IDEMPOTENCY_KEY_REUSED", "The idempotency key has already been used for a different request")).
+		End()
+
+	paymentService.VerifyWasCalled(m.Never()).Create(organisationID, payment)
+}
+
+func TestCreatePayment_IdempotencyKeyReleasedOnFailure(t *testing.T) {
+	var payment acme.Payment
+	readJSON("testdata/create_payment.json", &payment)
+
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Create(organisationID, recorded(payment))).ThenReturn(uuid.UUID{}, acme.ServerError)
+	idempotency := mocks.NewMockIdempotencyService()
+	m.When(idempotency.Reserve(organisationID, "key-1", fingerprint(payment))).ThenReturn(nil, nil)
+
+	idempotentAPITest(paymentService, idempotency).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Idempotency-Key", "key-1").
+		JSON(readFile("testdata/create_payment.json")).
+		Expect(t).
+		Status(http.StatusInternalServerError).
+		End()
+
+	idempotency.VerifyWasCalledOnce().Release(organisationID, "key-1")
+}
+
+func TestCreatePayment_IdempotencyKeyTooLong(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Idempotency-Key", strings.Repeat("k", 256)).
+		JSON(readFile("testdata/create_payment.json")).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Assert(jsonpath.Equal("$.code", acme.InvalidIdempotencyKey.Code)).
+		End()
+}
+
+func TestCreatePayment_InvalidRequestBody(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(readFile("testdata/invalid_request_body.json")).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		HeaderNotPresent("Location").
+		Assert(problem("INVALID_REQUEST_BODY", "The request body is not valid")).
+		End()
+}
+
+func TestCreatePayment_WithServiceError(t *testing.T) {
+	var payment acme.Payment
+	readJSON("testdata/create_payment.json", &payment)
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Create(organisationID, recorded(payment))).ThenReturn(uuid.Nil, acme.ServerError)
+
+	apiTest(paymentService).
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(readFile("testdata/create_payment.json")).
+		Expect(t).
+		Status(http.StatusInternalServerError).
+		HeaderNotPresent("Location").
+		Assert(problem("SERVER_ERROR", "Sorry, something went wrong")).
+		End()
+}
+
+func TestGetPayment_Success(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Get(organisationID, id)).ThenReturn(aPayment(id), nil)
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Header("ETag", `"0"`).
+		Body(fmt.Sprintf(`{
+			"id": "%s",
+			"version": 0,
+			"organisation_id": "57a3b643-cf4f-4f70-8636-0ddcdec07d68",
+			"status": "pending",
+			"recorded_at": "2019-05-08T19:30:12Z",
+			"attributes": {
+				"key": "value"
+			}
+		}`, id)).
+		End()
+}
+
+func TestGetPayment_AsOf(t *testing.T) {
+	id := uuid.New()
+	asOf := time.Date(2019, 5, 9, 14, 0, 0, 0, time.UTC)
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.GetAsOf(organisationID, id, asOf)).ThenReturn(aPayment(id), nil)
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s?as_of=2019-05-09T14:00:00Z", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.id", id.String())).
+		End()
+
+	paymentService.VerifyWasCalled(m.Never()).Get(organisationID, id)
+}
+
+func TestGetPayment_InvalidAsOf(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Get(fmt.Sprintf("/v1/payment/%s?as_of=2019-05-09", uuid.New())).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Assert(problem("INVALID_QUERY_PARAMETER", "as_of must be a timestamp formatted as RFC 3339")).
+		End()
+}
+
+func TestGetPayment_NotFound(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Get(organisationID, id)).ThenReturn(acme.Payment{}, acme.PaymentNotFound)
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusNotFound).
+		Assert(problem("PAYMENT_NOT_FOUND", "We could not find a payment with the given ID")).
+		End()
+}
+
+func TestGetPayment_WrappedError(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Get(organisationID, id)).ThenReturn(acme.Payment{}, errors.WithStack(acme.PaymentNotFound))
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusNotFound).
+		Assert(problem("PAYMENT_NOT_FOUND", "We could not find a payment with the given ID")).
+		End()
+}
+
+func TestGetPayment_ErrorWithoutKind(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Get(organisationID, id)).ThenReturn(acme.Payment{}, acme.Error{Code: "UNKNOWN", Detail: "Unknown error"})
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusInternalServerError).
+		Assert(problem("UNKNOWN", "Unknown error")).
+		End()
+}
+
+func TestGetPayment_InvalidID(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Get("/v1/payment/invalidID").
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Assert(problem("INVALID_PAYMENT_ID", "The provided ID is not valid")).
+		End()
+}
+
+func TestGetPayment_Pain001(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Get(organisationID, id)).ThenReturn(anApprovedPayment(id), nil)
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Accept", "application/xml").
+		Expect(t).
+		Status(http.StatusOK).
+		Header("Content-Type", "application/xml").
+		Header("ETag", `"2"`).
+		Assert(pain001(func(document iso20022.Pain001) {
+			header := document.Initiation.GroupHeader
+			assert.Equal(t, "1", header.NumberOfTransactions)
+			assert.Equal(t, organisationID.String(), header.InitiatingParty.Name)
+
+			instruction := document.Initiation.PaymentInstructions[0]
+			assert.Equal(t, strings.Replace(id.String(), "-", "", -1), instruction.ID)
+			assert.Equal(t, "GB83XABC10161234567801", instruction.DebtorAccount.ID.IBAN)
+
+			transaction := instruction.Transactions[0]
+			assert.Equal(t, "Wil piano Jan", transaction.PaymentID.EndToEndID)
+			assert.Equal(t, iso20022.Amount{Currency: "GBP", Value: "100.21"}, transaction.Amount.Instructed)
+			assert.Equal(t, "SHAR", transaction.ChargeBearer)
+		})).
+		End()
+}
+
+func TestGetPayment_Pain001NotApproved(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Get(organisationID, id)).ThenReturn(aStoredPayment(id), nil)
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Accept", "application/xml").
+		Expect(t).
+		Status(http.StatusConflict).
+		HeaderNotPresent("ETag").
+		Assert(problem("PAYMENT_NOT_APPROVED", "The payment is pending, only approved payments can be exported")).
+		End()
+}
+
+func TestGetPayment_NotExportable(t *testing.T) {
+	id := uuid.New()
+	payment := aPayment(id)
+	payment.Status = acme.StatusApproved
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Get(organisationID, id)).ThenReturn(payment, nil)
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Accept", "application/xml").
+		Expect(t).
+		Status(http.StatusUnprocessableEntity).
+		Assert(problem("PAYMENT_NOT_EXPORTABLE",
+			fmt.Sprintf("payment %s: processing_date must be a date formatted as YYYY-MM-DD", id))).
+		End()
+}
+
+func TestExportPain001(t *testing.T) {
+	first, last := uuid.New(), uuid.New()
+	query := acme.PaymentQuery{Limit: 100, Filter: acme.PaymentFilter{Currency: "GBP", Status: acme.StatusApproved}}
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.List(organisationID, query)).ThenReturn(acme.PaymentPage{
+		Payments: []acme.Payment{anApprovedPayment(first)},
+		Next:     acme.CursorAfter(first),
+	}, nil)
+	query.Cursor = *acme.CursorAfter(first)
+	m.When(paymentService.List(organisationID, query)).ThenReturn(acme.PaymentPage{
+		Payments: []acme.Payment{anApprovedPayment(last)},
+	}, nil)
+
+	apiTest(paymentService).
+		Get("/v1/export/pain001").
+		QueryParams(map[string]string{"currency": "GBP"}).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Header("Content-Type", "application/xml").
+		Assert(pain001(func(document iso20022.Pain001) {
+			assert.Equal(t, "2", document.Initiation.GroupHeader.NumberOfTransactions)
+			assert.Equal(t, "200.42", document.Initiation.GroupHeader.ControlSum)
+			assert.Len(t, document.Initiation.PaymentInstructions, 2)
+		})).
+		End()
+}
+
+func TestExportPain001_NothingToExport(t *testing.T) {
+	paymentService := mocks.NewMockPaymentService()
+	query := acme.PaymentQuery{Limit: 100, Filter: acme.PaymentFilter{Status: acme.StatusApproved}}
+	m.When(paymentService.List(organisationID, query)).ThenReturn(acme.PaymentPage{}, nil)
+
+	apiTest(paymentService).
+		Get("/v1/export/pain001").
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusNotFound).
+		Assert(problem("NOTHING_TO_EXPORT", "No payments match the filters of the export")).
+		End()
+}
+
+func TestExportPain001_TooManyPayments(t *testing.T) {
+	paymentService := mocks.NewMockPaymentService()
+	query := acme.PaymentQuery{Limit: 100, Filter: acme.PaymentFilter{Status: acme.StatusApproved}}
+	for i := 0; i < 11; i++ {
+		var page acme.PaymentPage
+		for j := 0; j < 100; j++ {
+			page.Payments = append(page.Payments, anApprovedPayment(uuid.New()))
+		}
+		page.Next = acme.CursorAfter(page.Payments[99].ID)
+		m.When(paymentService.List(organisationID, query)).ThenReturn(page, nil)
+		query.Cursor = *page.Next
+	}
+
+	apiTest(paymentService).
+		Get("/v1/export/pain001").
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusUnprocessableEntity).
+		Assert(problem("PAYMENT_NOT_EXPORTABLE",
+			"more than 1000 payments match the filters, narrow them down e.g. with processing_date_from and processing_date_to")).
+		End()
+}
+
+func TestExportPain001_OnlyApproved(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Get("/v1/export/pain001").
+		QueryParams(map[string]string{"status": "pending"}).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Assert(problem("INVALID_QUERY_PARAMETER", "status must be approved, as only approved payments can be exported")).
+		End()
+}
+
+func TestExportPain001_InvalidFilter(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Get("/v1/export/pain001").
+		QueryParams(map[string]string{"currency": "gbp"}).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Assert(jsonpath.Equal("$.code", acme.InvalidQueryParameter.Code)).
+		End()
+}
+
+func TestExportSEPA(t *testing.T) {
+	var payments []acme.Payment
+	readJSON("testdata/sepa_payments.json", &payments)
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.List(organisationID, acme.PaymentQuery{Limit: 100, Filter: acme.PaymentFilter{
+		Currency: "EUR",
+		Status:   acme.StatusApproved,
+	}})).ThenReturn(acme.PaymentPage{Payments: payments}, nil)
+
+	apiTest(paymentService).
+		Get("/v1/export/sepa").
+		QueryParams(map[string]string{"status": "approved"}).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Header("Content-Type", "application/xml").
+		Header("Excluded-Payments", "2").
+		Assert(pain001(func(document iso20022.Pain001) {
+			assert.Equal(t, "3", document.Initiation.GroupHeader.NumberOfTransactions)
+			instructions := document.Initiation.PaymentInstructions
+			assert.Len(t, instructions, 2)
+			assert.Equal(t, "SLEV", instructions[0].ChargeBearer)
+			assert.Equal(t, "Jurgen Weiss", instructions[0].Debtor.Name)
+			assert.Equal(t, "Cafe Creme + Co", instructions[0].Transactions[0].Creditor.Name)
+		})).
+		End()
+}
+
+func TestExportSEPA_DryRun(t *testing.T) {
+	var payments []acme.Payment
+	readJSON("testdata/sepa_payments.json", &payments)
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.List(organisationID, acme.PaymentQuery{Limit: 100, Filter: acme.PaymentFilter{
+		Currency: "EUR",
+		Status:   acme.StatusApproved,
+	}})).ThenReturn(acme.PaymentPage{Payments: payments}, nil)
+
+	apiTest(paymentService).
+		Get("/v1/export/sepa").
+		QueryParams(map[string]string{"dry_run": "true"}).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(`{
+			"included": [
+				"8a4c5e1f-3b5d-4a8e-9f77-2d1c0b9e6a10",
+				"2b7e4c6a-1d3f-4e5a-8b9c-0d1e2f3a4b5c",
+				"5c1d2e3f-4a5b-4c6d-9e7f-8a9b0c1d2e3f"
+			],
+			"excluded": [{
+				"id": "7d2e3f4a-5b6c-4d7e-8f9a-0b1c2d3e4f5a",
+				"reason": "reference: the character '€' is not in the SEPA character set"
+			}, {
+				"id": "9e3f4a5b-6c7d-4e8f-9a0b-1c2d3e4f5a6b",
+				"reason": "beneficiary_party.account_number must be a valid IBAN"
+			}]
+		}`).
+		End()
+}
+
+func TestExportSEPA_EveryPaymentExcluded(t *testing.T) {
+	var payments []acme.Payment
+	readJSON("testdata/sepa_payments.json", &payments)
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.List(organisationID, acme.PaymentQuery{Limit: 100, Filter: acme.PaymentFilter{
+		Currency: "EUR",
+		Status:   acme.StatusApproved,
+	}})).ThenReturn(acme.PaymentPage{Payments: payments[3:]}, nil)
+
+	apiTest(paymentService).
+		Get("/v1/export/sepa").
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusUnprocessableEntity).
+		Assert(problem("PAYMENT_NOT_EXPORTABLE", "all 2 payments are excluded, see dry_run=true for the reasons")).
+		End()
+}
+
+func TestExportSEPA_InvalidDryRun(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Get("/v1/export/sepa").
+		QueryParams(map[string]string{"dry_run": "maybe"}).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Assert(problem(acme.InvalidQueryParameter.Code, "dry_run must be true or false")).
+		End()
+}
+
+func TestExportSEPA_InvalidFilters(t *testing.T) {
+	tests := map[string]map[string]string{
+		"currency must be EUR, as SEPA credit transfers are in euro":         {"currency": "GBP"},
+		"status must be approved, as only approved payments can be exported": {"status": "pending"},
+	}
+	for detail, params := range tests {
+		apiTest(mocks.NewMockPaymentService()).
+			Get("/v1/export/sepa").
+			QueryParams(params).
+			Header("Authorization", "ApiKey "+apiKey).
+			Expect(t).
+			Status(http.StatusBadRequest).
+			Assert(problem(acme.InvalidQueryParameter.Code, detail)).
+			End()
+	}
+}
+
+func TestExportBACS(t *testing.T) {
+	id := uuid.New()
+	payment := aBACSPayment(id)
+	date := time.Date(2019, 6, 20, 0, 0, 0, 0, time.UTC)
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.List(organisationID, bacsQuery(date))).ThenReturn(acme.PaymentPage{
+		Payments: []acme.Payment{payment},
+	}, nil)
+	submitted := payment
+	submitted.Version, submitted.Status = 3, acme.StatusSubmitted
+	m.When(paymentService.Transition(organisationID, id, acme.ActionSubmit, 2, "test")).ThenReturn(submitted, nil)
+
+	apiTest(paymentService).
+		Post("/v1/export/bacs").
+		QueryParams(map[string]string{"processing_date": "2019-06-20", "service_user_number": "123456"}).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Header("Content-Type", "text/plain").
+		Assert(func(res *http.Response, req *http.Request) error {
+			data, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				return err
+			}
+			lines := strings.Split(strings.TrimSuffix(string(data), "\r\n"), "\r\n")
+			assert.Len(t, lines, 9)
+			assert.Equal(t, "UHL1 19171", lines[3][:10])
+			assert.Equal(t, "4030003192681909920330112345678    00000010021EJ BROWN BLACK    EM S PIANO LESSONSW OWENS           ",
+				lines[4])
+			assert.Equal(t, "UTL10000000010021000000001002100000010000001", lines[8][:44])
+			return nil
+		}).
+		End()
+
+	paymentService.VerifyWasCalledOnce().Transition(organisationID, id, acme.ActionSubmit, 2, "test")
+}
+
+func TestExportBACS_LeavesOutChangedPayments(t *testing.T) {
+	id := uuid.New()
+	payment := aBACSPayment(id)
+	date := time.Date(2019, 6, 20, 0, 0, 0, 0, time.UTC)
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.List(organisationID, bacsQuery(date))).ThenReturn(acme.PaymentPage{
+		Payments: []acme.Payment{payment},
+	}, nil)
+	m.When(paymentService.Transition(organisationID, id, acme.ActionSubmit, 2, "test")).
+		ThenReturn(acme.Payment{}, acme.VersionConflict)
+
+	apiTest(paymentService).
+		Post("/v1/export/bacs").
+		QueryParams(map[string]string{"processing_date": "2019-06-20", "service_user_number": "123456"}).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusNotFound).
+		Assert(problem("NOTHING_TO_EXPORT", "No payments match the filters of the export")).
+		End()
+}
+
+func TestExportBACS_IdempotencyKeyReplaysFile(t *testing.T) {
+	date := time.Date(2019, 6, 20, 0, 0, 0, 0, time.UTC)
+	request := struct {
+		ProcessingDate    time.Time
+		ServiceUserNumber string
+	}{date, "123456"}
+	paymentService := mocks.NewMockPaymentService()
+	idempotency := mocks.NewMockIdempotencyService()
+	m.When(idempotency.Reserve(organisationID, "key-1", fingerprint(request))).ThenReturn(&acme.IdempotentResponse{
+		StatusCode: http.StatusOK,
+		Header:     map[string]string{"Content-Type": "text/plain"},
+		Body:       []byte("VOL1\r\n"),
+	}, nil)
+
+	idempotentAPITest(paymentService, idempotency).
+		Post("/v1/export/bacs").
+		QueryParams(map[string]string{"processing_date": "2019-06-20", "service_user_number": "123456"}).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Idempotency-Key", "key-1").
+		Expect(t).
+		Status(http.StatusOK).
+		Header("Content-Type", "text/plain").
+		Header("Idempotent-Replayed", "true").
+		Body("VOL1\r\n").
+		End()
+
+	paymentService.VerifyWasCalled(m.Never()).List(organisationID, bacsQuery(date))
+}
+
+func TestExportBACS_ReadOnlyForbidden(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/export/bacs").
+		QueryParams(map[string]string{"processing_date": "2019-06-20", "service_user_number": "123456"}).
+		Header("Authorization", "ApiKey "+readOnlyAPIKey).
+		Expect(t).
+		Status(http.StatusForbidden).
+		End()
+}
+
+func TestExportBACS_NothingToExport(t *testing.T) {
+	date := time.Date(2019, 6, 20, 0, 0, 0, 0, time.UTC)
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.List(organisationID, bacsQuery(date))).ThenReturn(acme.PaymentPage{}, nil)
+
+	apiTest(paymentService).
+		Post("/v1/export/bacs").
+		QueryParams(map[string]string{"processing_date": "2019-06-20", "service_user_number": "123456"}).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusNotFound).
+		Assert(problem("NOTHING_TO_EXPORT", "No payments match the filters of the export")).
+		End()
+}
+
+func TestExportBACS_NotExportable(t *testing.T) {
+	id := uuid.New()
+	date := time.Date(2019, 6, 20, 0, 0, 0, 0, time.UTC)
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.List(organisationID, bacsQuery(date))).ThenReturn(acme.PaymentPage{
+		Payments: []acme.Payment{aStoredPayment(id)},
+	}, nil)
+
+	apiTest(paymentService).
+		Post("/v1/export/bacs").
+		QueryParams(map[string]string{"processing_date": "2019-06-20", "service_user_number": "123456"}).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusUnprocessableEntity).
+		Assert(problem("PAYMENT_NOT_EXPORTABLE",
+			fmt.Sprintf("payment %s: debtor_party.account_number: UK account numbers must be 8 digits", id))).
+		End()
+
+	paymentService.VerifyWasCalled(m.Never()).Transition(organisationID, id, acme.ActionSubmit, 2, "test")
+}
+
+func TestExportBACS_InvalidParameters(t *testing.T) {
+	tests := map[string]map[string]string{
+		"processing_date is required":                            {"service_user_number": "123456"},
+		"processing_date must be a date formatted as YYYY-MM-DD": {"processing_date": "20/06/2019", "service_user_number": "123456"},
+		"service_user_number must be 6 digits":                   {"processing_date": "2019-06-20"},
+	}
+	for detail, params := range tests {
+		apiTest(mocks.NewMockPaymentService()).
+			Post("/v1/export/bacs").
+			QueryParams(params).
+			Header("Authorization", "ApiKey "+apiKey).
+			Expect(t).
+			Status(http.StatusBadRequest).
+			Assert(problem(acme.InvalidQueryParameter.Code, detail)).
+			End()
+	}
+}
+
+func TestImportPacs008(t *testing.T) {
+	id := uuid.New()
+	message, err := iso20022.ParsePacs008([]byte(readFile("testdata/pacs008.xml")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment := acme.Payment{
+		OrganisationID: organisationID,
+		Attributes:     message.Transfer.Transactions[0].Attributes(message.Transfer.GroupHeader),
+	}
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Create(organisationID, recorded(payment))).ThenReturn(id, nil)
+
+	apiTest(paymentService).
+		Post("/v1/import/pacs008").
+		Header("Authorization", "ApiKey "+apiKey).
+		ContentType("application/xml").
+		Body(readFile("testdata/pacs008.xml")).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.data", 2)).
+		Assert(jsonpath.Equal("$.data[0].transaction_id", "123456789012345678")).
+		Assert(jsonpath.Equal("$.data[0].end_to_end_reference", "Wil piano Jan")).
+		Assert(jsonpath.Equal("$.data[0].id", id.String())).
+		Assert(jsonpath.Equal("$.data[1].transaction_id", "987654321098765432")).
+		Assert(jsonpath.Equal("$.data[1].error.code", acme.InvalidField.Code)).
+		Assert(jsonpath.Present("$.data[1].error.meta")).
+		Assert(jsonpath.NotPresent("$.data[1].id")).
+		End()
+
+	paymentService.VerifyWasCalled(m.Once()).Create(organisationID, recorded(payment))
+}
+
+func TestImportPacs008_MandatoryElements(t *testing.T) {
+	id := uuid.New()
+	message, err := iso20022.ParsePacs008([]byte(readFile("testdata/pacs008_mandatory.xml")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment := acme.Payment{
+		OrganisationID: organisationID,
+		Attributes:     message.Transfer.Transactions[0].Attributes(message.Transfer.GroupHeader),
+	}
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Create(organisationID, recorded(payment))).ThenReturn(id, nil)
+
+	apiTest(paymentService).
+		Post("/v1/import/pacs008").
+		Header("Authorization", "ApiKey "+apiKey).
+		ContentType("application/xml").
+		Body(readFile("testdata/pacs008_mandatory.xml")).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.data", 1)).
+		Assert(jsonpath.Equal("$.data[0].id", id.String())).
+		Assert(jsonpath.NotPresent("$.data[0].error")).
+		End()
+
+	paymentService.VerifyWasCalled(m.Once()).Create(organisationID, recorded(payment))
+}
+
+func TestImportPacs008_IdempotencyKey(t *testing.T) {
+	id := uuid.New()
+	message, err := iso20022.ParsePacs008([]byte(readFile("testdata/pacs008.xml")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment := acme.Payment{
+		OrganisationID: organisationID,
+		Attributes:     message.Transfer.Transactions[0].Attributes(message.Transfer.GroupHeader),
+	}
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Create(organisationID, recorded(payment))).ThenReturn(id, nil)
+	idempotency := &storedResponses{}
+
+	idempotentAPITest(paymentService, idempotency).
+		Post("/v1/import/pacs008").
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Idempotency-Key", "key-1").
+		ContentType("application/xml").
+		Body(readFile("testdata/pacs008.xml")).
+		Expect(t).
+		Status(http.StatusOK).
+		HeaderNotPresent("Idempotent-Replayed").
+		Assert(jsonpath.Equal("$.data[0].id", id.String())).
+		End()
+
+	idempotentAPITest(paymentService, idempotency).
+		Post("/v1/import/pacs008").
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Idempotency-Key", "key-1").
+		ContentType("application/xml").
+		Body(readFile("testdata/pacs008.xml")).
+		Expect(t).
+		Status(http.StatusOK).
+		Header("Idempotent-Replayed", "true").
+		Assert(jsonpath.Len("$.data", 2)).
+		Assert(jsonpath.Equal("$.data[0].id", id.String())).
+		Assert(jsonpath.Equal("$.data[1].error.code", acme.InvalidField.Code)).
+		End()
+
+	paymentService.VerifyWasCalled(m.Once()).Create(organisationID, recorded(payment))
+	assert.Equal(t, fingerprint(message), idempotency.fingerprint)
+}
+
+func TestImportPacs008_IdempotencyKeyReused(t *testing.T) {
+	message, err := iso20022.ParsePacs008([]byte(readFile("testdata/pacs008.xml")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paymentService := mocks.NewMockPaymentService()
+	idempotency := mocks.NewMockIdempotencyService()
+	m.When(idempotency.Reserve(organisationID, "key-1", fingerprint(message))).
+		ThenReturn(nil, acme.IdempotencyKeyReused)
+
+	idempotentAPITest(paymentService, idempotency).
+		Post("/v1/import/pacs008").
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Idempotency-Key", "key-1").
+		ContentType("application/xml").
+		Body(readFile("testdata/pacs008.xml")).
+		Expect(t).
+		Status(http.StatusUnprocessableEntity).
+		Assert(problem("IDEMPOTENCY_KEY_REUSED", "The idempotency key has already been used for a different request")).
+		End()
+
+	paymentService.VerifyWasCalled(m.Never()).Create(organisationID, acme.Payment{})
+}
+
+func TestImportPacs008_NotPacs008(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/import/pacs008").
+		Header("Authorization", "ApiKey "+apiKey).
+		ContentType("application/xml").
+		Body(`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.09"></Document>`).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Assert(problem("INVALID_MESSAGE", "the message is not a pacs.008 document")).
+		End()
+}
+
+func TestImportPacs008_UnsupportedMediaType(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/import/pacs008").
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(`{}`).
+		Expect(t).
+		Status(http.StatusUnsupportedMediaType).
+		Assert(problem("UNSUPPORTED_MEDIA_TYPE", "Content-Type must be application/xml")).
+		End()
+}
+
+func TestImportPacs008_ReadOnlyForbidden(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/import/pacs008").
+		Header("Authorization", "ApiKey "+readOnlyAPIKey).
+		ContentType("application/xml").
+		Body(readFile("testdata/pacs008.xml")).
+		Expect(t).
+		Status(http.StatusForbidden).
+		End()
+}
+
+func TestGetPaymentMT103(t *testing.T) {
+	id := uuid.New()
+	payment := anApprovedPayment(id)
+	payment.Attributes = mt103Attributes()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Get(organisationID, id)).ThenReturn(payment, nil)
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s/mt103", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Header("Content-Type", "text/plain").
+		Header("ETag", `"2"`).
+		Assert(func(res *http.Response, req *http.Request) error {
+			data, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				return err
+			}
+			text := readFile("testdata/mt103.txt")
+			textBlock := text[strings.Index(text, "{4:") : strings.Index(text, "-}")+2]
+			assert.Equal(t, textBlock, strings.Replace(string(data), "\r\n", "\n", -1))
+			return nil
+		}).
+		End()
+}
+
+func TestGetPaymentMT103_NotApproved(t *testing.T) {
+	id := uuid.New()
+	payment := aStoredPayment(id)
+	payment.Attributes = mt103Attributes()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Get(organisationID, id)).ThenReturn(payment, nil)
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s/mt103", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusConflict).
+		Assert(problem("PAYMENT_NOT_APPROVED", "The payment is pending, only approved payments can be exported")).
+		End()
+}
+
+func TestGetPaymentMT103_NotExportable(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Get(organisationID, id)).ThenReturn(anApprovedPayment(id), nil)
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s/mt103", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusUnprocessableEntity).
+		Assert(problem("PAYMENT_NOT_EXPORTABLE", "debtor_party.bank_id must be a BIC to be the ordering institution")).
+		End()
+}
+
+func TestImportMT103(t *testing.T) {
+	id := uuid.New()
+	payment := acme.Payment{OrganisationID: organisationID, Attributes: mt103Attributes()}
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Create(organisationID, recorded(payment))).ThenReturn(id, nil)
+
+	apiTest(paymentService).
+		Post("/v1/import/mt103").
+		Header("Authorization", "ApiKey "+apiKey).
+		ContentType("text/plain").
+		Body(readFile("testdata/mt103.txt")).
+		Expect(t).
+		Status(http.StatusCreated).
+		Header("Location", id.String()).
+		End()
+
+	paymentService.VerifyWasCalled(m.Once()).Create(organisationID, recorded(payment))
+}
+
+func TestImportMT103_WithoutOptionalFields(t *testing.T) {
+	id := uuid.New()
+	text := strings.Replace(readFile("testdata/mt103.txt"), ":57C://SC403000\n", "", 1)
+	text = strings.Replace(text, ":70:Em's piano lessons\n", "", 1)
+	text = strings.Replace(text, "\n1 The Beneficiary Localtown SE2", "", 1)
+	message, err := mt103.Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attributes, err := message.Attributes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment := acme.Payment{OrganisationID: organisationID, Attributes: attributes}
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Create(organisationID, recorded(payment))).ThenReturn(id, nil)
+
+	apiTest(paymentService).
+		Post("/v1/import/mt103").
+		Header("Authorization", "ApiKey "+apiKey).
+		ContentType("text/plain").
+		Body(text).
+		Expect(t).
+		Status(http.StatusCreated).
+		Header("Location", id.String()).
+		End()
+
+	paymentService.VerifyWasCalled(m.Once()).Create(organisationID, recorded(payment))
+}
+
+func TestImportMT103_InvalidMessage(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/import/mt103").
+		Header("Authorization", "ApiKey "+apiKey).
+		ContentType("text/plain").
+		Body(strings.Replace(readFile("testdata/mt103.txt"), ":32A:170118GBP100,21\n", "", 1)).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Assert(problem("INVALID_MESSAGE", "field 32A is required")).
+		End()
+}
+
+func TestImportMT103_InvalidPayment(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/import/mt103").
+		Header("Authorization", "ApiKey "+apiKey).
+		ContentType("text/plain").
+		Body(strings.Replace(readFile("testdata/mt103.txt"), "GBP100,21", "GBP100,215", 1)).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Assert(jsonpath.Equal("$.code", acme.InvalidField.Code)).
+		Assert(jsonpath.Equal("$.errors[0].pointer", "/attributes/amount")).
+		Assert(jsonpath.Equal("$.errors[0].code", "INVALID_AMOUNT")).
+		End()
+}
+
+func TestImportMT103_UnsupportedMediaType(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/import/mt103").
+		Header("Authorization", "ApiKey "+apiKey).
+		ContentType("application/xml").
+		Body(readFile("testdata/mt103.txt")).
+		Expect(t).
+		Status(http.StatusUnsupportedMediaType).
+		Assert(problem("UNSUPPORTED_MEDIA_TYPE", "Content-Type must be text/plain")).
+		End()
+}
+
+func TestGetAllPayments_Success(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.List(organisationID, acme.PaymentQuery{Limit: 20})).ThenReturn(acme.PaymentPage{
+		Payments: []acme.Payment{
+			aPayment(id),
+		},
+	}, nil)
+
+	apiTest(paymentService).
+		Get("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Body(fmt.Sprintf(`{
+			"data": [{
+				"attributes": {
+					"key": "value"
+				},
+				"id": "%s",
+				"organisation_id": "57a3b643-cf4f-4f70-8636-0ddcdec07d68",
+				"status": "pending",
+				"recorded_at": "2019-05-08T19:30:12Z",
+				"version": 0
+			}]
+		}`, id)).
+		Status(http.StatusOK).
+		End()
+}
+
+func TestGetAllPayments_EmptyArrayIfNone(t *testing.T) {
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.List(organisationID, acme.PaymentQuery{Limit: 20})).ThenReturn(acme.PaymentPage{
+		Payments: []acme.Payment{},
+	}, nil)
+
+	apiTest(paymentService).
+		Get("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Body(fmt.Sprintf(`{
+		  "data": []
+		}`)).
+		Status(http.StatusOK).
+		End()
+}
+
+func TestGetAllPayments_Paginated(t *testing.T) {
+	first, last := uuid.New(), uuid.New()
+	cursor := acme.Cursor{ID: uuid.New()}
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.List(organisationID, acme.PaymentQuery{Limit: 2, Cursor: cursor})).ThenReturn(acme.PaymentPage{
+		Payments: []acme.Payment{aPayment(first), aPayment(last)},
+		Next:     acme.CursorAfter(last),
+		Prev:     acme.CursorBefore(first),
+	}, nil)
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment?limit=2&cursor=%s", cursor)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.data[0].id", first.String())).
+		Assert(jsonpath.Equal("$.data[1].id", last.String())).
+		Assert(jsonpath.Equal("$.links.next", "/v1/payment?cursor="+acme.CursorAfter(last).String()+"&limit=2")).
+		Assert(jsonpath.Equal("$.links.prev", "/v1/payment?cursor="+acme.CursorBefore(first).String()+"&limit=2")).
+		End()
+}
+
+func TestGetAllPayments_AsOf(t *testing.T) {
+	id := uuid.New()
+	asOf := time.Date(2019, 5, 9, 14, 0, 0, 0, time.FixedZone("", 3600))
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.List(organisationID, acme.PaymentQuery{Limit: 20, AsOf: asOf})).ThenReturn(acme.PaymentPage{
+		Payments: []acme.Payment{aPayment(id)},
+		Next:     acme.CursorAfter(id),
+	}, nil)
+
+	apiTest(paymentService).
+		Get("/v1/payment?as_of=2019-05-09T14:00:00%2B01:00").
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.data", 1)).
+		Assert(jsonpath.Equal("$.links.next",
+			"/v1/payment?as_of=2019-05-09T14%3A00%3A00%2B01%3A00&cursor="+acme.CursorAfter(id).String())).
+		End()
+}
+
+func TestGetAllPayments_InvalidLimit(t *testing.T) {
+	for _, limit := range []string{"0", "101", "ten"} {
+		apiTest(mocks.NewMockPaymentService()).
+			Get("/v1/payment?limit="+limit).
+			Header("Authorization", "ApiKey "+apiKey).
+			Expect(t).
+			Status(http.StatusBadRequest).
+			Assert(problem("INVALID_QUERY_PARAMETER", "limit must be a number between 1 and 100")).
+			End()
+	}
+}
+
+func TestGetAllPayments_InvalidCursor(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Get("/v1/payment?cursor=not-a-cursor").
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Assert(problem("INVALID_QUERY_PARAMETER", "cursor is not valid")).
+		End()
+}
+
+func TestGetAllPayments_Filtered(t *testing.T) {
+	id := uuid.New()
+	min, max := acme.NewDecimal(10, 0), acme.NewDecimal(100050, 2)
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.List(organisationID, acme.PaymentQuery{
+		Limit: 20,
+		Filter: acme.PaymentFilter{
+			OrganisationID: organisationID,
+			Currency:       "GBP",
+			PaymentScheme:  "FPS",
+			PaymentType:    "Credit",
+			ProcessedFrom:  time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+			ProcessedTo:    time.Date(2017, 1, 31, 0, 0, 0, 0, time.UTC),
+			AmountMin:      &min,
+			AmountMax:      &max,
+			Reference:      "piano",
+			Status:         acme.StatusApproved,
+		},
+	})).ThenReturn(acme.PaymentPage{Payments: []acme.Payment{aPayment(id)}}, nil)
+
+	apiTest(paymentService).
+		Get("/v1/payment?organisation_id=743d5b63-8e6f-432e-a8fa-c5d8d2ee5fcb&currency=GBP&payment_scheme=FPS"+
+			"&payment_type=Credit&processing_date_from=2017-01-01&processing_date_to=2017-01-31"+
+			"&amount_min=10&amount_max=1000.50&reference=piano&status=approved").
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.data[0].id", id.String())).
+		End()
+}
+
+func TestGetAllPayments_InvalidFilter(t *testing.T) {
+	tests := map[string]string{
+		"organisation_id=123":             "organisation_id is not a valid ID",
+		"currency=pounds":                 "currency must be a three letter currency code",
+		"processing_date_from=18-01-2017": "processing_date_from must be a date formatted as YYYY-MM-DD",
+		"processing_date_to=tomorrow":     "processing_date_to must be a date formatted as YYYY-MM-DD",
+		"processing_date_from=2017-02-01&processing_date_to=2017-01-01": "processing_date_from must not be after processing_date_to",
+		"amount_min=ten":               "amount_min must be a decimal number",
+		"amount_max=1e3":               "amount_max must be a decimal number",
+		"amount_min=100&amount_max=10": "amount_min must not be greater than amount_max",
+		"status=paid":                  "status must be one of pending, approved, submitted, settled, rejected, returned, cancelled",
+	}
+	for query, detail := range tests {
+		apiTest(mocks.NewMockPaymentService()).
+			Get("/v1/payment?"+query).
+			Header("Authorization", "ApiKey "+apiKey).
+			Expect(t).
+			Status(http.StatusBadRequest).
+			Assert(problem("INVALID_QUERY_PARAMETER", detail)).
+			End()
+	}
+}
+
+func TestGetAllPayments_ServerError(t *testing.T) {
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.List(organisationID, acme.PaymentQuery{Limit: 20})).ThenReturn(acme.PaymentPage{}, acme.ServerError)
+
+	apiTest(paymentService).
+		Get("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Assert(problem("SERVER_ERROR", "Sorry, something went wrong")).
+		Status(http.StatusInternalServerError).
+		End()
+}
+
+func TestGetPaymentVersions_Success(t *testing.T) {
+	id := uuid.New()
+	updated := aPayment(id)
+	updated.Version = 1
+	tombstone := aPayment(id)
+	tombstone.Version = 2
+	tombstone.Deleted = true
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.GetVersions(organisationID, id)).ThenReturn([]acme.Payment{aPayment(id), updated, tombstone}, nil)
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s/versions", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(fmt.Sprintf(`{
+			"data": [{
+				"id": "%[1]s",
+				"version": 0,
+				"organisation_id": "57a3b643-cf4f-4f70-8636-0ddcdec07d68",
+				"status": "pending",
+				"recorded_at": "2019-05-08T19:30:12Z",
+				"attributes": {"key": "value"}
+			}, {
+				"id": "%[1]s",
+				"version": 1,
+				"organisation_id": "57a3b643-cf4f-4f70-8636-0ddcdec07d68",
+				"status": "pending",
+				"recorded_at": "2019-05-08T19:30:12Z",
+				"attributes": {"key": "value"}
+			}, {
+				"id": "%[1]s",
+				"version": 2,
+				"organisation_id": "57a3b643-cf4f-4f70-8636-0ddcdec07d68",
+				"status": "pending",
+				"recorded_at": "2019-05-08T19:30:12Z",
+				"attributes": {"key": "value"},
+				"deleted": true
+			}]
+		}`, id)).
+		End()
+}
+
+func TestGetPaymentVersions_NotFound(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.GetVersions(organisationID, id)).ThenReturn(nil, acme.PaymentNotFound)
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s/versions", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusNotFound).
+		Assert(jsonpath.Equal("$.code", acme.PaymentNotFound.Code)).
+		End()
+}
+
+func TestGetPaymentVersions_InvalidID(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Get("/v1/payment/invalidID/versions").
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Assert(jsonpath.Equal("$.code", acme.InvalidID.Code)).
+		End()
+}
+
+func TestGetPaymentVersion_Success(t *testing.T) {
+	id := uuid.New()
+	payment := aPayment(id)
+	payment.Version = 3
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.GetVersion(organisationID, id, 3)).ThenReturn(payment, nil)
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s/versions/3", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(fmt.Sprintf(`{
+			"id": "%s",
+			"version": 3,
+			"organisation_id": "57a3b643-cf4f-4f70-8636-0ddcdec07d68",
+			"status": "pending",
+			"recorded_at": "2019-05-08T19:30:12Z",
+			"attributes": {"key": "value"}
+		}`, id)).
+		End()
+}
+
+func TestGetPaymentVersion_InvalidVersion(t *testing.T) {
+	for _, version := range []string{"latest", "-1"} {
+		apiTest(mocks.NewMockPaymentService()).
+			Get(fmt.Sprintf("/v1/payment/%s/versions/%s", uuid.New(), version)).
+			Header("Authorization", "ApiKey "+apiKey).
+			Expect(t).
+			Status(http.StatusBadRequest).
+			Assert(problem("INVALID_VERSION", "The provided version is not valid")).
+			End()
+	}
+}
+
+func TestGetPaymentVersion_NotFound(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.GetVersion(organisationID, id, 7)).ThenReturn(acme.Payment{}, acme.PaymentVersionNotFound)
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s/versions/7", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusNotFound).
+		Assert(problem("PAYMENT_VERSION_NOT_FOUND", "We could not find the given version of the payment")).
+		End()
+}
+
+func TestDiffPaymentVersion_ComparesWithPreviousVersion(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Diff(organisationID, id, 3, 4)).ThenReturn(acme.Changes{
+		From: 3,
+		To:   4,
+		Data: []acme.Change{{
+			Op:   "replace",
+			Path: "/attributes/amount",
+			Old:  json.RawMessage(`"100.21"`),
+			New:  json.RawMessage(`"200.00"`),
+		}},
+	}, nil)
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s/versions/4/diff", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(`{
+			"from": 3,
+			"to": 4,
+			"data": [{"op": "replace", "path": "/attributes/amount", "old": "100.21", "new": "200.00"}]
+		}`).
+		End()
+}
+
+func TestDiffPaymentVersion_FromVersion(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Diff(organisationID, id, 0, 4)).ThenReturn(acme.Changes{From: 0, To: 4, Data: []acme.Change{}}, nil)
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s/versions/4/diff?from=0", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(`{"from": 0, "to": 4, "data": []}`).
+		End()
+}
+
+func TestDiffPaymentVersion_FirstVersionRequiresFrom(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Get(fmt.Sprintf("/v1/payment/%s/versions/0/diff", uuid.New())).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Assert(problem("INVALID_QUERY_PARAMETER", "from must be provided for the first version of a payment")).
+		End()
+}
+
+func TestDiffPaymentVersion_InvalidFrom(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Get(fmt.Sprintf("/v1/payment/%s/versions/4/diff?from=first", uuid.New())).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Assert(problem("INVALID_QUERY_PARAMETER", "from must be a version number")).
+		End()
+}
+
+func TestDiffPaymentVersion_VersionNotFound(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Diff(organisationID, id, 8, 9)).ThenReturn(acme.Changes{}, acme.PaymentVersionNotFound)
+
+	apiTest(paymentService).
+		Get(fmt.Sprintf("/v1/payment/%s/versions/9/diff", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusNotFound).
+		Assert(jsonpath.Equal("$.code", acme.PaymentVersionNotFound.Code)).
+		End()
+}
+
+func TestDeletePayment_Success(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Delete(organisationID, id, acme.AnyVersion, "test")).ThenReturn(nil)
+
+	apiTest(paymentService).Debug().
+		Delete(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+}
+
+func TestDeletePayment_IfMatch(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Delete(organisationID, id, 2, "test")).ThenReturn(nil)
+
+	apiTest(paymentService).
+		Delete(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("If-Match", `"2"`).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+}
+
+func TestDeletePayment_IfMatchPreconditionFailed(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Delete(organisationID, id, 2, "test")).ThenReturn(acme.VersionConflict)
+
+	apiTest(paymentService).
+		Delete(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("If-Match", `"2"`).
+		Expect(t).
+		Status(http.StatusPreconditionFailed).
+		Assert(jsonpath.Equal("$.code", acme.PreconditionFailed.Code)).
+		End()
+}
+
+func TestDeletePayment_WithInvalidID(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Delete("/v1/payment/invalid_uuid").
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Assert(jsonpath.Equal("$.code", acme.InvalidID.Code)).
+		End()
+}
+
+func TestDeletePayment_NotFound(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Delete(organisationID, id, acme.AnyVersion, "test")).ThenReturn(acme.PaymentNotFound)
+
+	apiTest(paymentService).
+		Delete(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusNotFound).
+		Assert(jsonpath.Equal("$.code", acme.PaymentNotFound.Code)).
+		End()
+}
+
+func TestDeletePayment_NotPending(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Delete(organisationID, id, acme.AnyVersion, "test")).ThenReturn(acme.PaymentNotPending)
+
+	apiTest(paymentService).
+		Delete(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusConflict).
+		Assert(jsonpath.Equal("$.code", acme.PaymentNotPending.Code)).
+		End()
+}
+
+func TestTransitionPayment_Success(t *testing.T) {
+	id := uuid.New()
+	submitted := aPayment(id)
+	submitted.Version = 1
+	submitted.Status = acme.StatusSubmitted
+	submitted.RecordedBy = "test"
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Transition(organisationID, id, acme.ActionSubmit, acme.AnyVersion, "test")).
+		ThenReturn(submitted, nil)
+
+	apiTest(paymentService).
+		Post(fmt.Sprintf("/v1/payment/%s/submit", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Header("ETag", `"1"`).
+		Assert(jsonpath.Equal("$.status", "submitted")).
+		Assert(jsonpath.Equal("$.recorded_by", "test")).
+		End()
+}
+
+func TestTransitionPayment_Actions(t *testing.T) {
+	for _, action := range []acme.Action{acme.ActionSubmit, acme.ActionSettle, acme.ActionReturn, acme.ActionCancel} {
+		id := uuid.New()
+		paymentService := mocks.NewMockPaymentService()
+		m.When(paymentService.Transition(organisationID, id, action, acme.AnyVersion, "test")).
+			ThenReturn(aPayment(id), nil)
+
+		apiTest(paymentService).
+			Post(fmt.Sprintf("/v1/payment/%s/%s", id, action)).
+			Header("Authorization", "ApiKey "+apiKey).
+			Expect(t).
+			Status(http.StatusOK).
+			End()
+	}
+}
+
+func TestTransitionPayment_InvalidTransition(t *testing.T) {
+	id := uuid.New()
+	invalid := acme.InvalidTransition
+	invalid.Detail = "A settled payment cannot cancel"
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Transition(organisationID, id, acme.ActionCancel, acme.AnyVersion, "test")).
+		ThenReturn(acme.Payment{}, invalid)
+
+	apiTest(paymentService).
+		Post(fmt.Sprintf("/v1/payment/%s/cancel", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusConflict).
+		Assert(problem("INVALID_TRANSITION", "A settled payment cannot cancel")).
+		End()
+}
+
+func TestTransitionPayment_IfMatchPreconditionFailed(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Transition(organisationID, id, acme.ActionSubmit, 2, "test")).
+		ThenReturn(acme.Payment{}, acme.VersionConflict)
+
+	apiTest(paymentService).
+		Post(fmt.Sprintf("/v1/payment/%s/submit", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("If-Match", `"2"`).
+		Expect(t).
+		Status(http.StatusPreconditionFailed).
+		Assert(jsonpath.Equal("$.code", acme.PreconditionFailed.Code)).
+		End()
+}
+
+func TestTransitionPayment_WithInvalidID(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post("/v1/payment/invalid_uuid/approve").
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Assert(jsonpath.Equal("$.code", acme.InvalidID.Code)).
+		End()
+}
+
+func TestTransitionPayment_ReadOnlyForbidden(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Post(fmt.Sprintf("/v1/payment/%s/approve", uuid.New())).
+		Header("Authorization", "ApiKey "+readOnlyAPIKey).
+		Expect(t).
+		Status(http.StatusForbidden).
+		Assert(jsonpath.Equal("$.code", acme.Forbidden.Code)).
+		End()
+}
+
+func TestApprovePayment(t *testing.T) {
+	id := uuid.New()
+	approvals := mocks.NewMockApprovalService()
+	m.When(approvals.Approve(organisationID, id, 2, "test")).ThenReturn(aStoredPayment(id), nil)
+
+	approvalAPITest(mocks.NewMockPaymentService(), approvals).
+		Post(fmt.Sprintf("/v1/payment/%s/approve", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("If-Match", `"2"`).
+		Expect(t).
+		Status(http.StatusOK).
+		Header("ETag", `"2"`).
+		Assert(jsonpath.Equal("$.status", "pending")).
+		End()
+}
+
+func TestApprovePayment_SelfApproval(t *testing.T) {
+	id := uuid.New()
+	approvals := mocks.NewMockApprovalService()
+	m.When(approvals.Approve(organisationID, id, acme.AnyVersion, "test")).ThenReturn(acme.Payment{}, acme.SelfApproval)
+
+	approvalAPITest(mocks.NewMockPaymentService(), approvals).
+		Post(fmt.Sprintf("/v1/payment/%s/approve", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusForbidden).
+		Assert(problem("SELF_APPROVAL", "The payment must be approved by a client other than the ones that recorded it")).
+		End()
+}
+
+func TestApprovePayment_DuplicateApproval(t *testing.T) {
+	id := uuid.New()
+	approvals := mocks.NewMockApprovalService()
+	m.When(approvals.Approve(organisationID, id, acme.AnyVersion, "test")).
+		ThenReturn(acme.Payment{}, acme.DuplicateApproval)
+
+	approvalAPITest(mocks.NewMockPaymentService(), approvals).
+		Post(fmt.Sprintf("/v1/payment/%s/approve", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusConflict).
+		Assert(jsonpath.Equal("$.code", acme.DuplicateApproval.Code)).
+		End()
+}
+
+func TestRejectPayment(t *testing.T) {
+	id := uuid.New()
+	rejected := aPayment(id)
+	rejected.Status = acme.StatusRejected
+	approvals := mocks.NewMockApprovalService()
+	m.When(approvals.Reject(organisationID, id, acme.AnyVersion, "test")).ThenReturn(rejected, nil)
 
-	paymentService := mocks.NewMockPaymentService()
-	m.When(paymentService.Create(payment)).ThenReturn(id, nil)
+	approvalAPITest(mocks.NewMockPaymentService(), approvals).
+		Post(fmt.Sprintf("/v1/payment/%s/reject", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.status", "rejected")).
+		End()
+}
 
-	apiTest(paymentService).
-		Post("/v1/payment").
-		JSON(readFile("testdata/create_payment.json")).
+func TestGetPaymentApprovals(t *testing.T) {
+	id := uuid.New()
+	approvals := mocks.NewMockApprovalService()
+	m.When(approvals.GetApprovals(organisationID, id)).ThenReturn([]acme.Approval{{
+		PaymentID:      id,
+		PaymentVersion: 2,
+		Approver:       "approver",
+		Decision:       acme.DecisionApproved,
+		RecordedAt:     time.Date(2019, 6, 17, 14, 23, 10, 0, time.UTC),
+	}}, nil)
+
+	approvalAPITest(mocks.NewMockPaymentService(), approvals).
+		Get(fmt.Sprintf("/v1/payment/%s/approvals", id)).
+		Header("Authorization", "ApiKey "+readOnlyAPIKey).
 		Expect(t).
-		Status(http.StatusCreated).
-		Header("Location", id.String()).
+		Status(http.StatusOK).
+		Body(fmt.Sprintf(`{
+			"data": [{
+				"payment_id": "%s",
+				"payment_version": 2,
+				"approver": "approver",
+				"decision": "approved",
+				"recorded_at": "2019-06-17T14:23:10Z"
+			}]
+		}`, id)).
 		End()
 }
 
-func TestCreatePayment_InvalidAttributes(t *testing.T) {
-	apiTest(mocks.NewMockPaymentService()).
-		Post("/v1/payment").
-		JSON(readFile("testdata/create_payment_with_invalid_attributes.json")).
+func TestGetPaymentApprovals_NotFound(t *testing.T) {
+	id := uuid.New()
+	approvals := mocks.NewMockApprovalService()
+	m.When(approvals.GetApprovals(organisationID, id)).ThenReturn(nil, acme.PaymentNotFound)
+
+	approvalAPITest(mocks.NewMockPaymentService(), approvals).
+		Get(fmt.Sprintf("/v1/payment/%s/approvals", id)).
+		Header("Authorization", "ApiKey "+apiKey).
 		Expect(t).
-		Status(http.StatusBadRequest).
-		HeaderNotPresent("Location").
-		Body(`{
-			"code": "INVALID_FIELD",
-			"detail": "invalid attributes: [(root): currency is required]"
-		}`).
+		Status(http.StatusNotFound).
+		Assert(jsonpath.Equal("$.code", acme.PaymentNotFound.Code)).
 		End()
 }
 
-func TestCreatePayment_WithoutMandatoryField(t *testing.T) {
-	apiTest(mocks.NewMockPaymentService()).
-		Post("/v1/payment").
-		JSON(readFile("testdata/create_payment_without_mandatory_field.json")).
+func TestUpdatePayment_Success(t *testing.T) {
+	id := uuid.New()
+	var payment acme.Payment
+	readJSON("testdata/update_payment.json", &payment)
+	payment.Version = acme.AnyVersion
+
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Update(organisationID, id, recorded(payment))).ThenReturn(1, nil)
+
+	apiTest(paymentService).
+		Put(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(readFile("testdata/update_payment.json")).
 		Expect(t).
-		Status(http.StatusBadRequest).
-		HeaderNotPresent("Location").
-		Body(`{
-			"code": "INVALID_FIELD",
-			"detail": "organisation Id must be provided"
-		}`).
+		Status(http.StatusOK).
+		Header("ETag", `"1"`).
 		End()
 }
 
-func TestCreatePayment_InvalidRequestBody(t *testing.T) {
-	apiTest(mocks.NewMockPaymentService()).
-		Post("/v1/payment").
-		JSON(readFile("testdata/invalid_request_body.json")).
+func TestUpdatePayment_VersionConflict(t *testing.T) {
+	id := uuid.New()
+	body := withVersion(readFile("testdata/update_payment.json"), 0)
+	var payment acme.Payment
+	assert.NoError(t, json.Unmarshal([]byte(body), &payment))
+
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Update(organisationID, id, recorded(payment))).ThenReturn(0, acme.VersionConflict)
+
+	apiTest(paymentService).
+		Put(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(body).
 		Expect(t).
-		Status(http.StatusBadRequest).
-		HeaderNotPresent("Location").
-		Body(`{
-			"code": "INVALID_REQUEST_BODY",
-			"detail": "The request body is not valid"
-		}`).
+		Status(http.StatusConflict).
+		Assert(problem("VERSION_CONFLICT", "The payment has been changed since the given version")).
 		End()
 }
 
-func TestCreatePayment_WithServiceError(t *testing.T) {
+func TestUpdatePayment_BodyVersion(t *testing.T) {
+	id := uuid.New()
+	body := withVersion(readFile("testdata/update_payment.json"), 2)
 	var payment acme.Payment
-	readJSON("testdata/create_payment.json", &payment)
+	assert.NoError(t, json.Unmarshal([]byte(body), &payment))
+	assert.Equal(t, 2, payment.Version)
+
 	paymentService := mocks.NewMockPaymentService()
-	m.When(paymentService.Create(payment)).ThenReturn(uuid.Nil, acme.ServerError)
+	m.When(paymentService.Update(organisationID, id, recorded(payment))).ThenReturn(3, nil)
 
 	apiTest(paymentService).
-		Post("/v1/payment").
-		JSON(readFile("testdata/create_payment.json")).
+		Put(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(body).
 		Expect(t).
-		Status(http.StatusInternalServerError).
-		HeaderNotPresent("Location").
-		Body(`{
-			"code": "SERVER_ERROR",
-			"detail": "Sorry, something went wrong"
-		}`).
+		Status(http.StatusOK).
+		Header("ETag", `"3"`).
 		End()
 }
 
-func TestGetPayment_Success(t *testing.T) {
+func TestUpdatePayment_IfMatch(t *testing.T) {
 	id := uuid.New()
+	var payment acme.Payment
+	readJSON("testdata/update_payment.json", &payment)
+	payment.Version = 3
+
 	paymentService := mocks.NewMockPaymentService()
-	m.When(paymentService.Get(id)).ThenReturn(aPayment(id), nil)
+	m.When(paymentService.Update(organisationID, id, recorded(payment))).ThenReturn(4, nil)
 
 	apiTest(paymentService).
-		Get(fmt.Sprintf("/v1/payment/%s", id)).
+		Put(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("If-Match", `"3"`).
+		JSON(readFile("testdata/update_payment.json")).
 		Expect(t).
 		Status(http.StatusOK).
-		Body(fmt.Sprintf(`{
-			"id": "%s",
-			"version": 0,
-			"organisation_id": "57a3b643-cf4f-4f70-8636-0ddcdec07d68",
-			"attributes": {
-				"key": "value"
-			}
-		}`, id)).
+		Header("ETag", `"4"`).
 		End()
 }
 
-func TestGetPayment_NotFound(t *testing.T) {
+func TestUpdatePayment_IfMatchAnyVersion(t *testing.T) {
 	id := uuid.New()
+	var payment acme.Payment
+	readJSON("testdata/update_payment.json", &payment)
+	payment.Version = acme.AnyVersion
+
 	paymentService := mocks.NewMockPaymentService()
-	m.When(paymentService.Get(id)).ThenReturn(acme.Payment{}, acme.PaymentNotFound)
+	m.When(paymentService.Update(organisationID, id, recorded(payment))).ThenReturn(7, nil)
 
 	apiTest(paymentService).
-		Get(fmt.Sprintf("/v1/payment/%s", id)).
+		Put(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("If-Match", "*").
+		JSON(readFile("testdata/update_payment.json")).
 		Expect(t).
-		Status(http.StatusBadRequest).
-		Body(`{
-			"code": "PAYMENT_NOT_FOUND",
-			"detail": "We could not find a payment with the given ID"
-		}`).
+		Status(http.StatusOK).
+		Header("ETag", `"7"`).
 		End()
 }
 
-func TestGetPayment_InvalidID(t *testing.T) {
+func TestUpdatePayment_IfMatchPreconditionFailed(t *testing.T) {
+	id := uuid.New()
+	var payment acme.Payment
+	readJSON("testdata/update_payment.json", &payment)
+	payment.Version = 3
+
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Update(organisationID, id, recorded(payment))).ThenReturn(0, acme.VersionConflict)
+
+	apiTest(paymentService).
+		Put(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("If-Match", `"3"`).
+		JSON(readFile("testdata/update_payment.json")).
+		Expect(t).
+		Status(http.StatusPreconditionFailed).
+		Assert(problem("PRECONDITION_FAILED", "The If-Match header does not match the current version of the payment")).
+		End()
+}
+
+func TestUpdatePayment_MalformedIfMatch(t *testing.T) {
+	for _, header := range []string{"3", `W/"3"`, `"3", "4"`, `"-1"`} {
+		apiTest(mocks.NewMockPaymentService()).
+			Put(fmt.Sprintf("/v1/payment/%s", uuid.New())).
+			Header("Authorization", "ApiKey "+apiKey).
+			Header("If-Match", header).
+			JSON(readFile("testdata/update_payment.json")).
+			Expect(t).
+			Status(http.StatusPreconditionFailed).
+			Assert(jsonpath.Equal("$.code", acme.PreconditionFailed.Code)).
+			End()
+	}
+}
+
+func TestUpdatePayment_InvalidAttributes(t *testing.T) {
 	apiTest(mocks.NewMockPaymentService()).
-		Get("/v1/payment/invalidID").
+		Put(fmt.Sprintf("/v1/payment/%s", uuid.New())).
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(readFile("testdata/create_payment_with_invalid_attributes.json")).
 		Expect(t).
 		Status(http.StatusBadRequest).
-		Body(`{
-			"code": "INVALID_PAYMENT_ID",
-			"detail": "The provided ID is not valid"
-		}`).
+		Assert(jsonpath.Equal("$.code", acme.InvalidField.Code)).
 		End()
 }
 
-func TestGetAllPayments_Success(t *testing.T) {
+func TestPatchPayment_MergePatch(t *testing.T) {
 	id := uuid.New()
+	current := aStoredPayment(id)
+	patched := aStoredPayment(id)
+	patched.Attributes.Amount = "200.00"
+	patched.Attributes.BeneficiaryParty.Name = "Wilfred Owens"
 	paymentService := mocks.NewMockPaymentService()
-	m.When(paymentService.GetAll()).ThenReturn(acme.Payments{
-		Data: []acme.Payment{
-			aPayment(id),
-		},
-	}, nil)
+	m.When(paymentService.Get(organisationID, id)).ThenReturn(current, nil)
+	m.When(paymentService.Update(organisationID, id, recorded(patched))).ThenReturn(3, nil)
 
 	apiTest(paymentService).
-		Get("/v1/payment").
+		Patch(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Content-Type", "application/merge-patch+json").
+		Body(`{"attributes": {"amount": "200.00", "beneficiary_party": {"name": "Wilfred Owens"}}}`).
 		Expect(t).
-		Body(fmt.Sprintf(`{
-			"data": [{
-				"attributes": {
-					"key": "value"
-				},
-				"id": "%s",
-				"organisation_id": "57a3b643-cf4f-4f70-8636-0ddcdec07d68",
-				"version": 0
-			}]
-		}`, id)).
 		Status(http.StatusOK).
+		Header("ETag", `"3"`).
 		End()
 }
 
-func TestGetAllPayments_EmptyArrayIfNone(t *testing.T) {
+func TestPatchPayment_JSONPatch(t *testing.T) {
+	id := uuid.New()
+	current := aStoredPayment(id)
+	patched := aStoredPayment(id)
+	patched.Attributes.Amount = "200.00"
+	patched.Attributes.ChargesInformation.SenderCharges = patched.Attributes.ChargesInformation.SenderCharges[1:]
 	paymentService := mocks.NewMockPaymentService()
-	m.When(paymentService.GetAll()).ThenReturn(acme.Payments{
-		Data: []acme.Payment{},
-	}, nil)
+	m.When(paymentService.Get(organisationID, id)).ThenReturn(current, nil)
+	m.When(paymentService.Update(organisationID, id, recorded(patched))).ThenReturn(3, nil)
 
 	apiTest(paymentService).
-		Get("/v1/payment").
+		Patch(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Content-Type", "application/json-patch+json").
+		Header("If-Match", `"2"`).
+		Body(`[
+			{"op": "test", "path": "/attributes/amount", "value": "100.21"},
+			{"op": "replace", "path": "/attributes/amount", "value": "200.00"},
+			{"op": "remove", "path": "/attributes/charges_information/sender_charges/0"}
+		]`).
 		Expect(t).
-		Body(fmt.Sprintf(`{
-		  "data": []
-		}`)).
 		Status(http.StatusOK).
+		Header("ETag", `"3"`).
 		End()
 }
 
-func TestGetAllPayments_ServerError(t *testing.T) {
+func TestPatchPayment_UnsupportedMediaType(t *testing.T) {
+	apiTest(mocks.NewMockPaymentService()).
+		Patch(fmt.Sprintf("/v1/payment/%s", uuid.New())).
+		Header("Authorization", "ApiKey "+apiKey).
+		JSON(`{"attributes": {"amount": "200.00"}}`).
+		Expect(t).
+		Status(http.StatusUnsupportedMediaType).
+		Assert(problem("UNSUPPORTED_MEDIA_TYPE", "Content-Type must be application/merge-patch+json or application/json-patch+json")).
+		End()
+}
+
+func TestPatchPayment_PatchCannotBeApplied(t *testing.T) {
+	id := uuid.New()
 	paymentService := mocks.NewMockPaymentService()
-	m.When(paymentService.GetAll()).ThenReturn(acme.Payments{}, acme.ServerError)
+	m.When(paymentService.Get(organisationID, id)).ThenReturn(aStoredPayment(id), nil)
 
 	apiTest(paymentService).
-		Get("/v1/payment").
+		Patch(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Content-Type", "application/json-patch+json").
+		Body(`[{"op": "test", "path": "/attributes/amount", "value": "1.00"}]`).
 		Expect(t).
-		Body(`{
-			"code": "SERVER_ERROR",
-			"detail": "Sorry, something went wrong"
-		}`).
-		Status(http.StatusInternalServerError).
+		Status(http.StatusUnprocessableEntity).
+		Assert(problem("INVALID_PATCH", "operation 0: test failed: the value at \"/attributes/amount\" is different")).
 		End()
 }
 
-func TestDeletePayment_Success(t *testing.T) {
+func TestPatchPayment_ReadOnlyFields(t *testing.T) {
 	id := uuid.New()
 	paymentService := mocks.NewMockPaymentService()
-	m.When(paymentService.Delete(id)).ThenReturn(nil)
+	m.When(paymentService.Get(organisationID, id)).ThenReturn(aStoredPayment(id), nil)
 
-	apiTest(paymentService).Debug().
-		Delete(fmt.Sprintf("/v1/payment/%s", id)).
+	apiTest(paymentService).
+		Patch(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Content-Type", "application/merge-patch+json").
+		Body(`{"version": 7}`).
 		Expect(t).
-		Status(http.StatusOK).
+		Status(http.StatusUnprocessableEntity).
+		Assert(problem("INVALID_PATCH", "id, version, status, recorded_at and recorded_by cannot be changed")).
 		End()
 }
 
-func TestDeletePayment_WithInvalidID(t *testing.T) {
-	apiTest(mocks.NewMockPaymentService()).
-		Delete("/v1/payment/invalid_uuid").
+func TestPatchPayment_RevalidatesPayment(t *testing.T) {
+	id := uuid.New()
+	paymentService := mocks.NewMockPaymentService()
+	m.When(paymentService.Get(organisationID, id)).ThenReturn(aStoredPayment(id), nil)
+
+	apiTest(paymentService).
+		Patch(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Content-Type", "application/merge-patch+json").
+		Body(`{"attributes": {"amount": "one hundred"}}`).
 		Expect(t).
 		Status(http.StatusBadRequest).
-		Assert(jsonpath.Equal("$.code", acme.InvalidID.Code)).
+		Assert(jsonpath.Equal("$.code", acme.InvalidField.Code)).
 		End()
 }
 
-func TestDeletePayment_NotFound(t *testing.T) {
+func TestPatchPayment_IfMatchPreconditionFailed(t *testing.T) {
 	id := uuid.New()
 	paymentService := mocks.NewMockPaymentService()
-	m.When(paymentService.Delete(id)).ThenReturn(acme.PaymentNotFound)
+	m.When(paymentService.Get(organisationID, id)).ThenReturn(aStoredPayment(id), nil)
 
 	apiTest(paymentService).
-		Delete(fmt.Sprintf("/v1/payment/%s", id)).
+		Patch(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Content-Type", "application/merge-patch+json").
+		Header("If-Match", `"1"`).
+		Body(`{"attributes": {"amount": "200.00"}}`).
 		Expect(t).
-		Status(http.StatusBadRequest).
-		Assert(jsonpath.Equal("$.code", acme.PaymentNotFound.Code)).
+		Status(http.StatusPreconditionFailed).
+		Assert(jsonpath.Equal("$.code", acme.PreconditionFailed.Code)).
 		End()
 }
 
-func TestUpdatePayment_Success(t *testing.T) {
+func TestPatchPayment_NotFound(t *testing.T) {
 	id := uuid.New()
-	var payment acme.Payment
-	readJSON("testdata/update_payment.json", &payment)
-
 	paymentService := mocks.NewMockPaymentService()
-	m.When(paymentService.Update(id, payment)).ThenReturn(nil)
+	m.When(paymentService.Get(organisationID, id)).ThenReturn(acme.Payment{}, acme.PaymentNotFound)
 
 	apiTest(paymentService).
-		Put(fmt.Sprintf("/v1/payment/%s", id)).
-		JSON(readFile("testdata/update_payment.json")).
+		Patch(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Content-Type", "application/merge-patch+json").
+		Body(`{"attributes": {"amount": "200.00"}}`).
 		Expect(t).
-		Status(http.StatusOK).
+		Status(http.StatusNotFound).
+		Assert(jsonpath.Equal("$.code", acme.PaymentNotFound.Code)).
 		End()
 }
 
 func TestUpdatePayment_InvalidID(t *testing.T) {
 	apiTest(mocks.NewMockPaymentService()).
 		Put("/v1/payment/not_a_uuid").
+		Header("Authorization", "ApiKey "+apiKey).
 		JSON(readFile("testdata/update_payment.json")).
 		Expect(t).
 		Status(http.StatusBadRequest).
-		Body(`{
-			"code": "INVALID_PAYMENT_ID",
-			"detail": "The provided ID is not valid"
-		}`).
+		Assert(problem("INVALID_PAYMENT_ID", "The provided ID is not valid")).
 		End()
 }
 
 func TestUpdatePayment_InvalidRequestBody(t *testing.T) {
 	apiTest(mocks.NewMockPaymentService()).
 		Put(fmt.Sprintf("/v1/payment/%s", uuid.New())).
+		Header("Authorization", "ApiKey "+apiKey).
 		JSON(readFile("testdata/invalid_request_body.json")).
 		Expect(t).
 		Status(http.StatusBadRequest).
-		Body(`{
-			"code": "INVALID_FIELD",
-			"detail": "The request body is not valid"
-		}`).
+		Assert(problem("INVALID_FIELD", "The request body is not valid")).
 		End()
 }
 
@@ -282,24 +2293,24 @@ func TestUpdatePayment_ServiceError(t *testing.T) {
 	id := uuid.New()
 	var payment acme.Payment
 	readJSON("testdata/update_payment.json", &payment)
+	payment.Version = acme.AnyVersion
 
 	paymentService := mocks.NewMockPaymentService()
-	m.When(paymentService.Update(id, payment)).ThenReturn(acme.ServerError)
+	m.When(paymentService.Update(organisationID, id, recorded(payment))).ThenReturn(0, acme.ServerError)
 
 	apiTest(paymentService).
 		Put(fmt.Sprintf("/v1/payment/%s", id)).
+		Header("Authorization", "ApiKey "+apiKey).
 		JSON(readFile("testdata/update_payment.json")).
 		Expect(t).
 		Status(http.StatusInternalServerError).
-		Body(`{
-			"code": "SERVER_ERROR",
-			"detail": "Sorry, something went wrong"
-		}`).
+		Assert(problem("SERVER_ERROR", "Sorry, something went wrong")).
 		End()
 }
 
 func TestHealthCheck(t *testing.T) {
-	srv := api.NewServer(mocks.NewMockPaymentService())
+	srv := api.NewServer(mocks.NewMockPaymentService(), mocks.NewMockApprovalService(), mocks.NewMockIdempotencyService(),
+		schemes(), authenticators(), api.DefaultPolicy)
 	go srv.Start("9001")
 	defer srv.Close()
 	cli := http.Client{Timeout: 1 * time.Second}
@@ -316,7 +2327,9 @@ func aPayment(id ...uuid.UUID) acme.Payment {
 	payment := acme.Payment{
 		Version:        0,
 		OrganisationID: uuid.MustParse("57a3b643-cf4f-4f70-8636-0ddcdec07d68"),
-		Attributes:     map[string]string{"key": "value"},
+		Attributes:     acme.Attributes{Extra: map[string]json.RawMessage{"key": json.RawMessage(`"value"`)}},
+		Status:         acme.StatusPending,
+		RecordedAt:     time.Date(2019, 5, 8, 19, 30, 12, 0, time.UTC),
 	}
 	if len(id) > 0 {
 		payment.ID = id[0]
@@ -324,10 +2337,158 @@ func aPayment(id ...uuid.UUID) acme.Payment {
 	return payment
 }
 
+// aStoredPayment is a valid payment at version 2
+func aStoredPayment(id uuid.UUID) acme.Payment {
+	var payment acme.Payment
+	readJSON("testdata/create_payment.json", &payment)
+	payment.ID = id
+	payment.Version = 2
+	payment.Status = acme.StatusPending
+	payment.RecordedAt = time.Date(2019, 5, 8, 19, 30, 12, 0, time.UTC)
+	payment.RecordedBy = "other"
+	return payment
+}
+
+// anApprovedPayment is a valid payment at version 2 that has been approved
+func anApprovedPayment(id uuid.UUID) acme.Payment {
+	payment := aStoredPayment(id)
+	payment.Status = acme.StatusApproved
+	return payment
+}
+
+// aBACSPayment is an approved payment that fits a BACS Standard 18 file
+func aBACSPayment(id uuid.UUID) acme.Payment {
+	payment := anApprovedPayment(id)
+	payment.Attributes.DebtorParty.AccountNumber = "12345678"
+	payment.Attributes.DebtorParty.AccountNumberCode = "BBAN"
+	return payment
+}
+
 func apiTest(service acme.PaymentService) *apitest.APITest {
+	return idempotentAPITest(service, mocks.NewMockIdempotencyService())
+}
+
+func idempotentAPITest(service acme.PaymentService, idempotency acme.IdempotencyService) *apitest.APITest {
+	return serverTest(service, mocks.NewMockApprovalService(), idempotency)
+}
+
+func approvalAPITest(service acme.PaymentService, approvals acme.ApprovalService) *apitest.APITest {
+	return serverTest(service, approvals, mocks.NewMockIdempotencyService())
+}
+
+func serverTest(service acme.PaymentService, approvals acme.ApprovalService,
+	idempotency acme.IdempotencyService) *apitest.APITest {
 	return apitest.New().
 		Recorder(test.Recorder).
-		Handler(api.NewServer(service).Router)
+		Handler(api.NewServer(service, approvals, idempotency, schemes(), authenticators(), api.DefaultPolicy).Router)
+}
+
+// now is the time of the clock of the scheme rules, a Wednesday morning in London
+var now = time.Date(2019, 6, 19, 10, 0, 0, 0, time.UTC)
+
+// schemes has the default rules of the payment schemes with a clock stopped at now
+func schemes() *scheme.Registry {
+	registry, err := scheme.NewDefaultRegistry(func() time.Time { return now })
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
+
+// authenticators accept apiKey, otherAPIKey and readOnlyAPIKey with the ApiKey scheme. The Bearer scheme accepts no tokens.
+func authenticators() api.Authenticators {
+	invalid := acme.Unauthorized
+	invalid.Detail = "The API key is not valid"
+
+	apiKeys := mocks.NewMockAuthenticator()
+	m.When(apiKeys.Authenticate("invalid")).ThenReturn(acme.Principal{}, invalid)
+	m.When(apiKeys.Authenticate(apiKey)).
+		ThenReturn(acme.Principal{Subject: "test", OrganisationID: organisationID, Scopes: allScopes}, nil)
+	m.When(apiKeys.Authenticate(otherAPIKey)).
+		ThenReturn(acme.Principal{Subject: "other", OrganisationID: otherOrganisationID, Scopes: allScopes}, nil)
+	m.When(apiKeys.Authenticate(readOnlyAPIKey)).
+		ThenReturn(acme.Principal{Subject: "read-only", OrganisationID: organisationID, Scopes: []string{acme.ScopeRead}}, nil)
+	return api.Authenticators{"ApiKey": apiKeys, "Bearer": mocks.NewMockAuthenticator()}
+}
+
+// fingerprint is the SHA-256 of the JSON encoded request, which identifies a request made with an idempotency key
+func fingerprint(request interface{}) string {
+	data, err := json.Marshal(request)
+	if err != nil {
+		panic(err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// storedResponses is an acme.IdempotencyService of a single key that replays the response stored for it,
+// so a test can send a request twice and see the second one answered with the response to the first
+type storedResponses struct {
+	fingerprint string
+	response    *acme.IdempotentResponse
+}
+
+func (s *storedResponses) Reserve(organisationID uuid.UUID, key, fingerprint string) (*acme.IdempotentResponse, error) {
+	if s.fingerprint != "" && s.fingerprint != fingerprint {
+		return nil, acme.IdempotencyKeyReused
+	}
+	s.fingerprint = fingerprint
+	return s.response, nil
+}
+
+func (s *storedResponses) Complete(organisationID uuid.UUID, key string, response acme.IdempotentResponse) error {
+	s.response = &response
+	return nil
+}
+
+func (s *storedResponses) Release(organisationID uuid.UUID, key string) error {
+	s.fingerprint = ""
+	return nil
+}
+
+// problem asserts that the response is a problem details document for the application error with
+// the given code and detail
+// pain001 asserts that the response is a pain.001 document and passes it to check
+func pain001(check func(document iso20022.Pain001)) func(*http.Response, *http.Request) error {
+	return func(res *http.Response, req *http.Request) error {
+		var document iso20022.Pain001
+		err := xml.NewDecoder(res.Body).Decode(&document)
+		if err != nil {
+			return err
+		}
+		check(document)
+		return nil
+	}
+}
+
+func problem(code, detail string) func(*http.Response, *http.Request) error {
+	return func(res *http.Response, req *http.Request) error {
+		if contentType := res.Header.Get("Content-Type"); contentType != "application/problem+json" {
+			return fmt.Errorf("expected a problem details document, got content type %q", contentType)
+		}
+		var body map[string]interface{}
+		err := json.NewDecoder(res.Body).Decode(&body)
+		if err != nil {
+			return err
+		}
+
+		expected := map[string]interface{}{
+			"type":     "/problems/" + strings.Replace(strings.ToLower(code), "_", "-", -1),
+			"status":   float64(res.StatusCode),
+			"detail":   detail,
+			"instance": req.URL.Path,
+			"code":     code,
+		}
+		for name, value := range expected {
+			if body[name] != value {
+				return fmt.Errorf("expected %s to be %v, got %v", name, value, body[name])
+			}
+		}
+		if title, _ := body["title"].(string); title == "" {
+			return fmt.Errorf("expected a title")
+		}
+		return nil
+	}
 }
 
 func readFile(file string) string {
@@ -348,3 +2509,38 @@ func readJSON(file string, data interface{}) {
 		panic(err)
 	}
 }
+
+// mt103Attributes are the attributes of the payment in testdata/mt103.txt
+func mt103Attributes() acme.Attributes {
+	message, err := mt103.Parse(readFile("testdata/mt103.txt"))
+	if err != nil {
+		panic(err)
+	}
+	attributes, err := message.Attributes()
+	if err != nil {
+		panic(err)
+	}
+	return attributes
+}
+
+// bacsQuery is the query of the payments that are submitted to BACS on the date
+func bacsQuery(date time.Time) acme.PaymentQuery {
+	return acme.PaymentQuery{Limit: 100, Filter: acme.PaymentFilter{
+		PaymentScheme: "BACS",
+		PaymentType:   "Credit",
+		Status:        acme.StatusApproved,
+		ProcessedFrom: date,
+		ProcessedTo:   date,
+	}}
+}
+
+// withVersion adds a version to a payment document
+func withVersion(document string, version int) string {
+	return strings.Replace(document, "{", fmt.Sprintf(`{"version": %d, `, version), 1)
+}
+
+// recorded is the payment as it is passed to the service when it is stored on behalf of apiKey
+func recorded(payment acme.Payment) acme.Payment {
+	payment.RecordedBy = "test"
+	return payment
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/steinfletcher/payments"
+)
+
+// approvals lists the decisions made by approvers about a payment
+type approvals struct {
+	Data []acme.Approval `json:"data"`
+}
+
+// getPaymentApprovals lists the audit trail of the approval of a payment, oldest first
+func (r *Server) getPaymentApprovals(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(acme.InvalidID)
+		return
+	}
+
+	decisions, err := r.approvals.GetApprovals(organisation(ctx), id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, approvals{Data: decisions})
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	acme "github.com/steinfletcher/payments"
+)
+
+const principalKey = "principal"
+
+// Authenticators maps the schemes of the Authorization header, e.g. "Bearer", to the authenticator of
+// their credentials. Schemes are matched ignoring case.
+type Authenticators map[string]acme.Authenticator
+
+// authenticate is a middleware that rejects requests without valid credentials and otherwise sets the
+// principal that made the request on the context
+func (r *Server) authenticate(ctx *gin.Context) {
+	principal, err := r.authenticators.authenticate(ctx.GetHeader("Authorization"))
+	if err != nil {
+		if e, ok := errors.Cause(err).(acme.Error); ok && e.Kind == acme.KindUnauthorized {
+			ctx.Header("WWW-Authenticate", strings.Join(r.authenticators.schemes(), ", "))
+		}
+		ctx.Error(err)
+		ctx.Abort()
+		return
+	}
+	ctx.Set(principalKey, principal)
+}
+
+func (a Authenticators) authenticate(authorization string) (acme.Principal, error) {
+	unauthorized := acme.Unauthorized
+	if authorization == "" {
+		unauthorized.Detail = "The Authorization header must be set"
+		return acme.Principal{}, unauthorized
+	}
+
+	parts := strings.SplitN(authorization, " ", 2)
+	var authenticator acme.Authenticator
+	for scheme, candidate := range a {
+		if strings.EqualFold(scheme, parts[0]) {
+			authenticator = candidate
+		}
+	}
+	if authenticator == nil || len(parts) != 2 {
+		unauthorized.Detail = "The Authorization header must use one of the schemes " + strings.Join(a.schemes(), ", ")
+		return acme.Principal{}, unauthorized
+	}
+
+	principal, err := authenticator.Authenticate(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return acme.Principal{}, err
+	}
+	// every request is made on behalf of an organisation
+	if principal.OrganisationID == uuid.Nil {
+		return acme.Principal{}, unauthorized
+	}
+	return principal, nil
+}
+
+func (a Authenticators) schemes() []string {
+	var schemes []string
+	for scheme := range a {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Policy lists the scopes that allow each kind of operation on payments. A client needs one of the
+// scopes of an operation to perform it, so an operation without scopes cannot be performed at all.
+type Policy struct {
+	Read   []string
+	Write  []string
+	Delete []string
+}
+
+// DefaultPolicy requires the payments:read scope to read payments, payments:write to create and update
+// them and payments:delete to delete them
+var DefaultPolicy = Policy{
+	Read:   []string{acme.ScopeRead},
+	Write:  []string{acme.ScopeWrite},
+	Delete: []string{acme.ScopeDelete},
+}
+
+// authorize returns a middleware that rejects requests from clients without one of the scopes
+func authorize(scopes []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if principal(ctx).HasAnyScope(scopes...) {
+			return
+		}
+		err := acme.Forbidden
+		if len(scopes) > 0 {
+			err.Detail = "The request requires one of the scopes " + strings.Join(scopes, ", ")
+		}
+		ctx.Error(err)
+		ctx.Abort()
+	}
+}
+
+// principal is the authenticated client that made the request
+func principal(ctx *gin.Context) acme.Principal {
+	return ctx.MustGet(principalKey).(acme.Principal)
+}
+
+// organisation is the organisation the request is made on behalf of
+func organisation(ctx *gin.Context) uuid.UUID {
+	return principal(ctx).OrganisationID
+}
+
+// checkOrganisation rejects a payment that belongs to another organisation than the request, which
+// would otherwise let a client create or move payments into an organisation it does not act for
+func checkOrganisation(ctx *gin.Context, payment acme.Payment) error {
+	if payment.OrganisationID == organisation(ctx) {
+		return nil
+	}
+	err := acme.OrganisationMismatch
+	err.Meta = []acme.FieldError{{
+		Pointer: "/organisation_id",
+		Code:    "ORGANISATION_MISMATCH",
+		Message: "organisation_id must be the organisation of the request",
+	}}
+	return err
+}
@@ -1,23 +1,30 @@
 package api
 
 import (
-	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
 	acme "github.com/steinfletcher/payments"
 )
 
-// errorToStatusCodeLookup maps application errors to http status codes
-// It helps to decouple application errors from the HTTP layer
-var errorToStatusCodeLookup = map[string]int{
-	acme.InvalidID.Code:          http.StatusBadRequest,
-	acme.InvalidRequestBody.Code: http.StatusBadRequest,
-	acme.PaymentNotFound.Code:    http.StatusBadRequest,
-	acme.InvalidField.Code:       http.StatusBadRequest,
-	acme.ServerError.Code:        http.StatusInternalServerError,
+const problemContentType = "application/problem+json"
+
+// problem is an RFC 7807 problem details document. Code is the code of the application error, which
+// clients written before problem details were introduced rely on.
+type problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail"`
+	Instance string            `json:"instance"`
+	Code     string            `json:"code"`
+	Errors   []acme.FieldError `json:"errors,omitempty"`
+	Meta     interface{}       `json:"meta,omitempty"`
 }
 
-// errorHandler is a middleware that sets any present application errors on the response
+// errorHandler is a middleware that sets any present application errors on the response. Errors
+// wrapped with github.com/pkg/errors are unwrapped, anything else is reported as a server error.
 func errorHandler(c *gin.Context) {
 	c.Next()
 
@@ -26,12 +33,41 @@ func errorHandler(c *gin.Context) {
 	}
 
 	for _, err := range c.Errors {
-		if parsedError, ok := err.Err.(acme.Error); ok {
-			statusCode := errorToStatusCodeLookup[parsedError.Code]
-			c.JSON(statusCode, parsedError)
+		if parsedError, ok := errors.Cause(err.Err).(acme.Error); ok {
+			writeProblem(c, parsedError)
 			return
 		}
 	}
 
-	c.JSON(http.StatusInternalServerError, acme.ServerError)
+	writeProblem(c, acme.ServerError)
+}
+
+func writeProblem(c *gin.Context, err acme.Error) {
+	status := err.HTTPStatus()
+	p := problem{
+		Type:     "/problems/" + strings.Replace(strings.ToLower(err.Code), "_", "-", -1),
+		Title:    problemTitle(err.Code),
+		Status:   status,
+		Detail:   err.Detail,
+		Instance: c.Request.URL.Path,
+		Code:     err.Code,
+	}
+	if fieldErrors, ok := err.Meta.([]acme.FieldError); ok {
+		p.Errors = fieldErrors
+	} else {
+		p.Meta = err.Meta
+	}
+
+	// gin only sets the JSON content type when none is set
+	c.Header("Content-Type", problemContentType)
+	c.JSON(status, p)
+}
+
+// problemTitle is a short summary of a type of problem, e.g. "Payment not found" for PAYMENT_NOT_FOUND
+func problemTitle(code string) string {
+	title := strings.Replace(strings.ToLower(code), "_", " ", -1)
+	if title == "" {
+		return title
+	}
+	return strings.ToUpper(title[:1]) + title[1:]
 }
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	acme "github.com/steinfletcher/payments"
+)
+
+// etag is the entity tag of a version of a payment
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// ifMatch reads the version the client expects a payment to be at from the If-Match header.
+// ok is false when the header is not set and the version is acme.AnyVersion for "*".
+// Weak and multiple entity tags never match a single version of a payment.
+func ifMatch(ctx *gin.Context) (version int, ok bool, err error) {
+	header := ctx.GetHeader("If-Match")
+	if header == "" {
+		return 0, false, nil
+	}
+	if header == "*" {
+		return acme.AnyVersion, true, nil
+	}
+
+	value, err := strconv.Unquote(header)
+	if err != nil || header[0] != '"' {
+		return 0, true, acme.PreconditionFailed
+	}
+	version, err = strconv.Atoi(value)
+	if err != nil || version < 0 {
+		return 0, true, acme.PreconditionFailed
+	}
+	return version, true, nil
+}
+
+// preconditionError reports a version conflict as a failed precondition when the expected version
+// came from the If-Match header
+func preconditionError(err error, fromHeader bool) error {
+	if e, ok := errors.Cause(err).(acme.Error); ok && fromHeader && e.Code == acme.VersionConflict.Code {
+		return acme.PreconditionFailed
+	}
+	return err
+}
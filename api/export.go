@@ -0,0 +1,292 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/steinfletcher/payments"
+	"github.com/steinfletcher/payments/bacs"
+	"github.com/steinfletcher/payments/iso20022"
+	"github.com/steinfletcher/payments/mt103"
+)
+
+const (
+	xmlContentType = "application/xml"
+	// textContentType is the content type of SWIFT MT messages and BACS files, which are plain text
+	textContentType = "text/plain"
+	// maxExportSize is the most payments a pain.001 or a SEPA export holds, so a document is built in
+	// memory from a bounded number of payments
+	maxExportSize = 1000
+)
+
+// exportPain001 renders every approved payment that matches the filters as a pain.001 customer credit
+// transfer initiation. The filters are the ones of the payment list.
+func (r *Server) exportPain001(ctx *gin.Context) {
+	payments, err := r.exportedPayments(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	r.writePain001(ctx, payments)
+}
+
+// exportMT103 renders the text block of an MT103 single customer credit transfer of the payment, which
+// must be approved. The payment is read as it is now, or as it was at the as_of parameter.
+func (r *Server) exportMT103(ctx *gin.Context) {
+	payment, err := r.requestedPayment(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	if err := checkApproved(payment); err != nil {
+		ctx.Error(err)
+		return
+	}
+	message, err := mt103.New(payment.Attributes)
+	if err != nil {
+		ctx.Error(notExportable(err))
+		return
+	}
+	ctx.Header("ETag", etag(payment.Version))
+	ctx.Data(http.StatusOK, textContentType, []byte(message.String()))
+}
+
+// sepaDryRun lists the payments that an export of SEPA credit transfers would include and exclude
+type sepaDryRun struct {
+	Included []uuid.UUID    `json:"included"`
+	Excluded []sepaExcluded `json:"excluded"`
+}
+
+type sepaExcluded struct {
+	ID     uuid.UUID `json:"id"`
+	Reason string    `json:"reason"`
+}
+
+// exportSEPA renders the approved EUR payments that match the filters as a SEPA credit transfer
+// initiation, with a payment instruction for each debtor account and processing date. Payments that cannot
+// be SEPA credit transfers are left out and counted in the Excluded-Payments header. With dry_run=true it
+// lists the payments that would be included and excluded, with the reasons, instead.
+func (r *Server) exportSEPA(ctx *gin.Context) {
+	filter, err := exportFilter(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	if filter.Currency != "" && filter.Currency != "EUR" {
+		ctx.Error(invalidQueryParameter("currency must be EUR, as SEPA credit transfers are in euro"))
+		return
+	}
+	dryRun, err := boolParameter(ctx, "dry_run")
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	header := iso20022.NewHeader(time.Now(), organisation(ctx).String())
+	export, err := iso20022.NewSEPAExporter(r.service, maxExportSize).Export(organisation(ctx), filter, header, dryRun)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if dryRun {
+		response := sepaDryRun{Included: export.Included, Excluded: []sepaExcluded{}}
+		if response.Included == nil {
+			response.Included = []uuid.UUID{}
+		}
+		for _, e := range export.Excluded {
+			response.Excluded = append(response.Excluded, sepaExcluded{ID: e.PaymentID, Reason: e.Reason})
+		}
+		ctx.JSON(http.StatusOK, response)
+		return
+	}
+	if export.Document == nil {
+		ctx.Error(notExportable(fmt.Errorf("all %d payments are excluded, see dry_run=true for the reasons",
+			len(export.Excluded))))
+		return
+	}
+	data, err := export.Document.Marshal()
+	if err != nil {
+		ctx.Error(acme.ServerError)
+		return
+	}
+	ctx.Header("Excluded-Payments", strconv.Itoa(len(export.Excluded)))
+	ctx.Data(http.StatusOK, xmlContentType, data)
+}
+
+// bacsExport is what an export of a BACS file is fingerprinted by for its idempotency key
+type bacsExport struct {
+	ProcessingDate    time.Time
+	ServiceUserNumber string
+}
+
+// exportBACS renders the approved BACS credits of the organisation that are processed on the
+// processing_date parameter as a Standard 18 file of the service user with the service_user_number
+// parameter, and submits them so that they are not in another file. An export with an Idempotency-Key
+// is answered with the same file when it is retried.
+func (r *Server) exportBACS(ctx *gin.Context) {
+	processingDate, err := dateParameter(ctx, "processing_date")
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	if processingDate.IsZero() {
+		ctx.Error(invalidQueryParameter("processing_date is required"))
+		return
+	}
+	serviceUserNumber := ctx.Query("service_user_number")
+	if bacs.CheckServiceUserNumber(serviceUserNumber) != nil {
+		ctx.Error(invalidQueryParameter("service_user_number must be 6 digits"))
+		return
+	}
+
+	organisationID := organisation(ctx)
+	key := ctx.GetHeader(idempotencyKeyHeader)
+	if key != "" {
+		request := bacsExport{ProcessingDate: processingDate, ServiceUserNumber: serviceUserNumber}
+		replayed, err := r.reserveIdempotencyKey(ctx, organisationID, key, request)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		if replayed {
+			return
+		}
+	}
+
+	header := bacs.NewHeader(serviceUserNumber, processingDate, time.Now())
+	file, err := r.submitBACS(organisationID, header, principal(ctx).Subject)
+	if err != nil {
+		r.releaseIdempotencyKey(organisationID, key)
+		ctx.Error(err)
+		return
+	}
+	response := acme.IdempotentResponse{
+		StatusCode: http.StatusOK,
+		Header:     map[string]string{"Content-Type": textContentType},
+		Body:       file.Marshal(),
+	}
+	r.completeIdempotencyKey(organisationID, key, response)
+	writeResponse(ctx, response)
+}
+
+// submitBACS builds the file of the payments that are processed on the processing date of the header and
+// submits them. The payments are checked to fit the file before any is submitted.
+func (r *Server) submitBACS(organisationID uuid.UUID, header bacs.Header, recordedBy string) (bacs.File, error) {
+	generator := bacs.NewGenerator(r.service)
+	payments, err := generator.Eligible(organisationID, header.ProcessingDate)
+	if err != nil {
+		return bacs.File{}, err
+	}
+	if len(payments) == 0 {
+		return bacs.File{}, acme.NothingToExport
+	}
+	if _, err := bacs.NewFile(header, payments); err != nil {
+		return bacs.File{}, notExportable(err)
+	}
+
+	submitted, err := generator.Submit(organisationID, payments, recordedBy)
+	if len(submitted) == 0 {
+		if err != nil {
+			return bacs.File{}, err
+		}
+		return bacs.File{}, acme.NothingToExport
+	}
+	if err != nil {
+		log.Printf("submitted %d of %d BACS payments: %s", len(submitted), len(payments), err)
+	}
+	file, err := bacs.NewFile(header, submitted)
+	if err != nil {
+		return bacs.File{}, acme.ServerError
+	}
+	return file, nil
+}
+
+// exportedPayments reads every approved payment that matches the filters of the request, a page at a time,
+// up to maxExportSize payments
+func (r *Server) exportedPayments(ctx *gin.Context) ([]acme.Payment, error) {
+	filter, err := exportFilter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	asOf, err := asOfParameter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := acme.PaymentQuery{Limit: maxPageSize, Filter: filter, AsOf: asOf}
+	var payments []acme.Payment
+	for {
+		page, err := r.service.List(organisation(ctx), query)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, page.Payments...)
+		if len(payments) > maxExportSize {
+			return nil, notExportable(fmt.Errorf(
+				"more than %d payments match the filters, narrow them down e.g. with processing_date_from and processing_date_to", maxExportSize))
+		}
+		if page.Next == nil {
+			break
+		}
+		query.Cursor = *page.Next
+	}
+	if len(payments) == 0 {
+		return nil, acme.NothingToExport
+	}
+	return payments, nil
+}
+
+// exportFilter reads the filters of the payment list for an export, which only holds approved payments
+func exportFilter(ctx *gin.Context) (acme.PaymentFilter, error) {
+	filter, err := paymentFilter(ctx)
+	if err != nil {
+		return filter, err
+	}
+	if filter.Status != "" && filter.Status != acme.StatusApproved {
+		return filter, invalidQueryParameter("status must be approved, as only approved payments can be exported")
+	}
+	filter.Status = acme.StatusApproved
+	return filter, nil
+}
+
+func (r *Server) writePain001(ctx *gin.Context, payments []acme.Payment) {
+	header := iso20022.NewHeader(time.Now(), organisation(ctx).String())
+	document, err := iso20022.NewPain001(header, payments)
+	if err != nil {
+		ctx.Error(notExportable(err))
+		return
+	}
+	data, err := document.Marshal()
+	if err != nil {
+		ctx.Error(acme.ServerError)
+		return
+	}
+	ctx.Data(http.StatusOK, xmlContentType, data)
+}
+
+// checkApproved checks that a payment has the approvals it needs to be sent to the bank
+func checkApproved(payment acme.Payment) error {
+	if payment.Status != acme.StatusApproved {
+		err := acme.PaymentNotApproved
+		err.Detail = fmt.Sprintf("The payment is %s, only approved payments can be exported", payment.Status)
+		return err
+	}
+	return nil
+}
+
+// acceptsXML reports whether the client prefers XML to JSON
+func acceptsXML(ctx *gin.Context) bool {
+	return ctx.NegotiateFormat(gin.MIMEJSON, xmlContentType) == xmlContentType
+}
+
+func notExportable(err error) error {
+	e := acme.PaymentNotExportable
+	e.Detail = err.Error()
+	return e
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	acme "github.com/steinfletcher/payments"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKey    = 255
+)
+
+// reserveIdempotencyKey claims the idempotency key of a request. If the request has already completed
+// the stored response is written and replayed is true.
+func (r *Server) reserveIdempotencyKey(ctx *gin.Context, organisationID uuid.UUID, key string,
+	request interface{}) (replayed bool, err error) {
+	if len(key) > maxIdempotencyKey {
+		return false, acme.InvalidIdempotencyKey
+	}
+	fingerprint, err := fingerprint(request)
+	if err != nil {
+		return false, acme.ServerError
+	}
+
+	stored, err := r.idempotency.Reserve(organisationID, key, fingerprint)
+	if err != nil || stored == nil {
+		return false, err
+	}
+	ctx.Header("Idempotent-Replayed", "true")
+	writeResponse(ctx, *stored)
+	return true, nil
+}
+
+// completeIdempotencyKey stores the response to a request made with an idempotency key. The request has
+// succeeded by now, so a failure is logged rather than reported to the client.
+func (r *Server) completeIdempotencyKey(organisationID uuid.UUID, key string, response acme.IdempotentResponse) {
+	if key == "" {
+		return
+	}
+	if err := r.idempotency.Complete(organisationID, key, response); err != nil {
+		log.Printf("failed to store the response for idempotency key %q: %s", key, err)
+	}
+}
+
+// releaseIdempotencyKey frees the idempotency key of a failed request so that the client can retry it
+func (r *Server) releaseIdempotencyKey(organisationID uuid.UUID, key string) {
+	if key == "" {
+		return
+	}
+	if err := r.idempotency.Release(organisationID, key); err != nil {
+		log.Printf("failed to release idempotency key %q: %s", key, err)
+	}
+}
+
+// fingerprint identifies the content of a request. It is taken over the decoded request rather than
+// the raw body so that retries which only differ in formatting are the same request.
+func fingerprint(request interface{}) (string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func writeResponse(ctx *gin.Context, response acme.IdempotentResponse) {
+	for name, value := range response.Header {
+		ctx.Header(name, value)
+	}
+	if len(response.Body) == 0 {
+		ctx.AbortWithStatus(response.StatusCode)
+		return
+	}
+	ctx.Data(response.StatusCode, "application/json; charset=utf-8", response.Body)
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/steinfletcher/payments"
+	"github.com/steinfletcher/payments/iso20022"
+	"github.com/steinfletcher/payments/mt103"
+)
+
+// imported lists the outcome of importing each transaction of a message, in the order of the message
+type imported struct {
+	Data []importResult `json:"data"`
+}
+
+// importResult is the ID of the payment created for a transaction, or the error that prevented it
+type importResult struct {
+	TransactionID     string      `json:"transaction_id,omitempty"`
+	EndToEndReference string      `json:"end_to_end_reference,omitempty"`
+	ID                *uuid.UUID  `json:"id,omitempty"`
+	Error             *acme.Error `json:"error,omitempty"`
+}
+
+// importPacs008 creates a payment of the organisation for each credit transfer transaction of a pacs.008
+// message. Each payment is validated and created like the body of POST /v1/payment, so a transaction
+// that fails does not prevent the others from being created. A message sent with an Idempotency-Key is
+// imported once, and a retry is answered with the results of the first import.
+func (r *Server) importPacs008(ctx *gin.Context) {
+	if ctx.ContentType() != xmlContentType {
+		err := acme.UnsupportedMediaType
+		err.Detail = "Content-Type must be " + xmlContentType
+		ctx.Error(err)
+		return
+	}
+
+	body, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.Error(acme.InvalidRequestBody)
+		return
+	}
+	message, err := iso20022.ParsePacs008(body)
+	if err != nil {
+		ctx.Error(invalidMessage(err))
+		return
+	}
+
+	organisationID := organisation(ctx)
+	key := ctx.GetHeader(idempotencyKeyHeader)
+	if key != "" {
+		replayed, err := r.reserveIdempotencyKey(ctx, organisationID, key, message)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		if replayed {
+			return
+		}
+	}
+
+	results := imported{Data: []importResult{}}
+	for _, transaction := range message.Transfer.Transactions {
+		result := importResult{
+			TransactionID:     transaction.PaymentID.TransactionID,
+			EndToEndReference: transaction.PaymentID.EndToEndID,
+		}
+		id, err := r.importPayment(ctx, acme.Payment{
+			OrganisationID: organisationID,
+			Attributes:     transaction.Attributes(message.Transfer.GroupHeader),
+		})
+		if err != nil {
+			result.Error = importError(err)
+		} else {
+			result.ID = &id
+		}
+		results.Data = append(results.Data, result)
+	}
+
+	body, err = json.Marshal(results)
+	if err != nil {
+		r.releaseIdempotencyKey(organisationID, key)
+		ctx.Error(acme.ServerError)
+		return
+	}
+	response := acme.IdempotentResponse{StatusCode: http.StatusOK, Body: body}
+	r.completeIdempotencyKey(organisationID, key, response)
+	writeResponse(ctx, response)
+}
+
+// importMT103 creates a payment of the organisation from an MT103 single customer credit transfer. The
+// payment is validated and created like the body of POST /v1/payment.
+func (r *Server) importMT103(ctx *gin.Context) {
+	if ctx.ContentType() != textContentType {
+		err := acme.UnsupportedMediaType
+		err.Detail = "Content-Type must be " + textContentType
+		ctx.Error(err)
+		return
+	}
+
+	body, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.Error(acme.InvalidRequestBody)
+		return
+	}
+	message, err := mt103.Parse(string(body))
+	if err != nil {
+		ctx.Error(invalidMessage(err))
+		return
+	}
+	attributes, err := message.Attributes()
+	if err != nil {
+		ctx.Error(invalidMessage(err))
+		return
+	}
+
+	id, err := r.importPayment(ctx, acme.Payment{OrganisationID: organisation(ctx), Attributes: attributes})
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.Header("Location", id.String())
+	ctx.AbortWithStatus(http.StatusCreated)
+}
+
+// importPayment validates and creates a payment of the organisation of the request
+func (r *Server) importPayment(ctx *gin.Context, payment acme.Payment) (uuid.UUID, error) {
+	attributes, err := json.Marshal(payment.Attributes)
+	if err != nil {
+		return uuid.Nil, acme.ServerError
+	}
+	err = r.validatePayment(payment, attributes)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	payment.RecordedBy = principal(ctx).Subject
+	return r.service.Create(organisation(ctx), payment)
+}
+
+// importError is the application error of a transaction that could not be imported. Any other error is
+// reported as a server error, like errorHandler does.
+func importError(err error) *acme.Error {
+	e, ok := errors.Cause(err).(acme.Error)
+	if !ok {
+		e = acme.ServerError
+	}
+	return &e
+}
+
+func invalidMessage(err error) error {
+	e := acme.InvalidMessage
+	e.Detail = err.Error()
+	return e
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"github.com/steinfletcher/payments"
+)
+
+// validateParties checks the structure of the account number and bank ID of each party, keyed on their
+// codes: IBANs when account_number_code is IBAN, sort codes when bank_id_code is GBDSC, UK account
+// numbers when the BBAN of a party is held at a sort code, and BICs when bank_id_code is SWBIC
+func validateParties(a acme.Attributes) []acme.FieldError {
+	var errs []acme.FieldError
+	check := func(party, field, code string, err error) {
+		if err != nil {
+			errs = append(errs, acme.FieldError{
+				Pointer: attributePointer(party, field),
+				Code:    code,
+				Message: err.Error(),
+			})
+		}
+	}
+
+	parties := []struct {
+		name  string
+		party *acme.Party
+	}{
+		{"debtor_party", a.DebtorParty},
+		{"beneficiary_party", a.BeneficiaryParty},
+		{"sponsor_party", a.SponsorParty},
+	}
+	for _, p := range parties {
+		if p.party == nil {
+			continue
+		}
+		switch p.party.AccountNumberCode {
+		case "IBAN":
+			check(p.name, "account_number", "INVALID_IBAN", acme.CheckIBAN(p.party.AccountNumber))
+		case "BBAN":
+			if p.party.BankIDCode == "GBDSC" {
+				check(p.name, "account_number", "INVALID_ACCOUNT_NUMBER",
+					acme.CheckUKAccountNumber(p.party.AccountNumber))
+			}
+		}
+		switch p.party.BankIDCode {
+		case "GBDSC":
+			check(p.name, "bank_id", "INVALID_SORT_CODE", acme.CheckSortCode(p.party.BankID))
+		case "SWBIC":
+			check(p.name, "bank_id", "INVALID_BIC", acme.CheckBIC(p.party.BankID))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	acme "github.com/steinfletcher/payments"
+	"github.com/steinfletcher/payments/jsonpatch"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// patchPayment applies a JSON Merge Patch or a JSON Patch to the latest version of a payment and stores
+// the result as a new version. The patch applies to the payment as it is returned by getPayment.
+func (r *Server) patchPayment(ctx *gin.Context) {
+	externalID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(acme.InvalidID)
+		return
+	}
+
+	var applyPatch func(doc, patch []byte) ([]byte, error)
+	switch ctx.ContentType() {
+	case mergePatchContentType:
+		applyPatch = jsonpatch.MergePatch
+	case jsonPatchContentType:
+		applyPatch = jsonpatch.Apply
+	default:
+		err := acme.UnsupportedMediaType
+		err.Detail = "Content-Type must be " + mergePatchContentType + " or " + jsonPatchContentType
+		ctx.Error(err)
+		return
+	}
+
+	patch, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.Error(acme.InvalidRequestBody)
+		return
+	}
+
+	expected, ok, err := ifMatch(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	checkVersion := ok && expected != acme.AnyVersion
+
+	current, err := r.service.Get(organisation(ctx), externalID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	if checkVersion && expected != current.Version {
+		ctx.Error(acme.PreconditionFailed)
+		return
+	}
+
+	payment, attributes, err := patchedPayment(current, patch, applyPatch)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	err = r.validatePayment(payment, attributes)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	err = checkOrganisation(ctx, payment)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	// the update fails if the payment has changed since it was read
+	payment.RecordedBy = principal(ctx).Subject
+	version, err := r.service.Update(organisation(ctx), externalID, payment)
+	if err != nil {
+		ctx.Error(preconditionError(err, checkVersion))
+		return
+	}
+
+	ctx.Header("ETag", etag(version))
+	ctx.AbortWithStatus(http.StatusOK)
+}
+
+// patchedPayment applies a patch to a payment and returns the patched payment with its attributes as they
+// are in the patched document. Only the organisation and the attributes can be changed, the status
+// changes by the transition endpoints.
+func patchedPayment(current acme.Payment, patch []byte,
+	applyPatch func(doc, patch []byte) ([]byte, error)) (acme.Payment, json.RawMessage, error) {
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return acme.Payment{}, nil, acme.ServerError
+	}
+
+	patched, err := applyPatch(doc, patch)
+	if err != nil {
+		return acme.Payment{}, nil, invalidPatch(err.Error())
+	}
+
+	var payment acme.Payment
+	err = json.Unmarshal(patched, &payment)
+	if err != nil {
+		return acme.Payment{}, nil, invalidPatch("the patched payment is not valid: " + err.Error())
+	}
+	if payment.ID != current.ID || payment.Version != current.Version || payment.Status != current.Status ||
+		!payment.RecordedAt.Equal(current.RecordedAt) || payment.RecordedBy != current.RecordedBy {
+		return acme.Payment{}, nil, invalidPatch("id, version, status, recorded_at and recorded_by cannot be changed")
+	}
+	var sent sentPayment
+	err = json.Unmarshal(patched, &sent)
+	if err != nil {
+		return acme.Payment{}, nil, acme.ServerError
+	}
+	return payment, sent.Attributes, nil
+}
+
+func invalidPatch(detail string) error {
+	err := acme.InvalidPatch
+	err.Detail = detail
+	return err
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/steinfletcher/payments"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	dateFormat      = "2006-01-02"
+)
+
+// paymentQuery reads the page size, position and filters from the query parameters
+func paymentQuery(ctx *gin.Context) (acme.PaymentQuery, error) {
+	query := acme.PaymentQuery{Limit: defaultPageSize}
+
+	if limit := ctx.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxPageSize {
+			return query, invalidQueryParameter("limit must be a number between 1 and %d", maxPageSize)
+		}
+		query.Limit = n
+	}
+
+	if cursor := ctx.Query("cursor"); cursor != "" {
+		c, err := acme.ParseCursor(cursor)
+		if err != nil {
+			return query, invalidQueryParameter("cursor is not valid")
+		}
+		query.Cursor = c
+	}
+
+	filter, err := paymentFilter(ctx)
+	if err != nil {
+		return query, err
+	}
+	query.Filter = filter
+
+	query.AsOf, err = asOfParameter(ctx)
+	return query, err
+}
+
+func paymentFilter(ctx *gin.Context) (acme.PaymentFilter, error) {
+	filter := acme.PaymentFilter{
+		PaymentScheme: ctx.Query("payment_scheme"),
+		PaymentType:   ctx.Query("payment_type"),
+		Reference:     ctx.Query("reference"),
+	}
+
+	if id := ctx.Query("organisation_id"); id != "" {
+		organisationID, err := uuid.Parse(id)
+		if err != nil {
+			return filter, invalidQueryParameter("organisation_id is not a valid ID")
+		}
+		filter.OrganisationID = organisationID
+	}
+
+	if status := acme.Status(ctx.Query("status")); status != "" {
+		if !status.Valid() {
+			return filter, invalidQueryParameter("status must be one of %s", statusNames())
+		}
+		filter.Status = status
+	}
+
+	if currency := ctx.Query("currency"); currency != "" {
+		if len(currency) != 3 || strings.ToUpper(currency) != currency {
+			return filter, invalidQueryParameter("currency must be a three letter currency code")
+		}
+		filter.Currency = currency
+	}
+
+	var err error
+	filter.ProcessedFrom, err = dateParameter(ctx, "processing_date_from")
+	if err != nil {
+		return filter, err
+	}
+	filter.ProcessedTo, err = dateParameter(ctx, "processing_date_to")
+	if err != nil {
+		return filter, err
+	}
+	if !filter.ProcessedFrom.IsZero() && !filter.ProcessedTo.IsZero() && filter.ProcessedFrom.After(filter.ProcessedTo) {
+		return filter, invalidQueryParameter("processing_date_from must not be after processing_date_to")
+	}
+
+	filter.AmountMin, err = decimalParameter(ctx, "amount_min")
+	if err != nil {
+		return filter, err
+	}
+	filter.AmountMax, err = decimalParameter(ctx, "amount_max")
+	if err != nil {
+		return filter, err
+	}
+	if filter.AmountMin != nil && filter.AmountMax != nil && filter.AmountMin.Cmp(*filter.AmountMax) > 0 {
+		return filter, invalidQueryParameter("amount_min must not be greater than amount_max")
+	}
+	return filter, nil
+}
+
+func dateParameter(ctx *gin.Context, name string) (time.Time, error) {
+	value := ctx.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse(dateFormat, value)
+	if err != nil {
+		return time.Time{}, invalidQueryParameter("%s must be a date formatted as YYYY-MM-DD", name)
+	}
+	return date, nil
+}
+
+// asOfParameter reads the point in time to read payments at. The zero time means now.
+func asOfParameter(ctx *gin.Context) (time.Time, error) {
+	value := ctx.Query("as_of")
+	if value == "" {
+		return time.Time{}, nil
+	}
+	asOf, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, invalidQueryParameter("as_of must be a timestamp formatted as RFC 3339")
+	}
+	return asOf, nil
+}
+
+func decimalParameter(ctx *gin.Context, name string) (*acme.Decimal, error) {
+	value := ctx.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	d, err := acme.ParseDecimal(value)
+	if err != nil {
+		return nil, invalidQueryParameter("%s must be a decimal number", name)
+	}
+	return &d, nil
+}
+
+func boolParameter(ctx *gin.Context, name string) (bool, error) {
+	value := ctx.Query(name)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, invalidQueryParameter("%s must be true or false", name)
+	}
+	return b, nil
+}
+
+func invalidQueryParameter(format string, args ...interface{}) error {
+	err := acme.InvalidQueryParameter
+	err.Detail = fmt.Sprintf(format, args...)
+	return err
+}
+
+// pageLinks creates links to the adjacent pages, keeping all other query parameters of the request
+func pageLinks(requestURL *url.URL, page acme.PaymentPage) *acme.Links {
+	if page.Next == nil && page.Prev == nil {
+		return nil
+	}
+
+	link := func(cursor acme.Cursor) string {
+		query := requestURL.Query()
+		query.Set("cursor", cursor.String())
+		return (&url.URL{Path: requestURL.Path, RawQuery: query.Encode()}).String()
+	}
+
+	links := &acme.Links{}
+	if page.Next != nil {
+		links.Next = link(*page.Next)
+	}
+	if page.Prev != nil {
+		links.Prev = link(*page.Prev)
+	}
+	return links
+}
+
+// statusNames lists the statuses of the payment lifecycle for an error message
+func statusNames() string {
+	var names []string
+	for _, status := range acme.Statuses {
+		names = append(names, string(status))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/steinfletcher/payments"
+)
+
+// getPaymentVersions lists every stored version of a payment, oldest first
+func (r *Server) getPaymentVersions(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(acme.InvalidID)
+		return
+	}
+
+	versions, err := r.service.GetVersions(organisation(ctx), id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, acme.Payments{Data: versions})
+}
+
+func (r *Server) getPaymentVersion(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(acme.InvalidID)
+		return
+	}
+
+	version, err := versionParam(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	payment, err := r.service.GetVersion(organisation(ctx), id, version)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, payment)
+}
+
+// diffPaymentVersion lists the changes made by a version of a payment. By default the version is compared
+// with the version before it, or with the version given by the from query parameter.
+func (r *Server) diffPaymentVersion(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(acme.InvalidID)
+		return
+	}
+
+	version, err := versionParam(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	from := version - 1
+	if value := ctx.Query("from"); value != "" {
+		from, err = strconv.Atoi(value)
+		if err != nil || from < 0 {
+			ctx.Error(invalidQueryParameter("from must be a version number"))
+			return
+		}
+	}
+	if from < 0 {
+		ctx.Error(invalidQueryParameter("from must be provided for the first version of a payment"))
+		return
+	}
+
+	changes, err := r.service.Diff(organisation(ctx), id, from, version)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, changes)
+}
+
+func versionParam(ctx *gin.Context) (int, error) {
+	version, err := strconv.Atoi(ctx.Param("version"))
+	if err != nil || version < 0 {
+		return 0, acme.InvalidVersion
+	}
+	return version, nil
+}
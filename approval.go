@@ -0,0 +1,75 @@
+package acme
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+//go:generate pegomock generate --use-experimental-model-gen --output-dir mocks ApprovalService
+
+// ApprovalService enforces the four-eyes approval of payments on top of the payment lifecycle. A pending
+// payment is approved once it has the quorum of approvals it needs. A payment whose amount is above the
+// threshold of its organisation for its currency needs the approvals of the threshold. Any other payment
+// needs a single approval. No approval can be given by a client that recorded a version of the payment.
+// Each decision is kept in an audit trail.
+type ApprovalService interface {
+	// Approve records the approval of the current version of a pending payment, if it is the given
+	// version or AnyVersion, and returns the payment, which is approved once the quorum is reached
+	Approve(organisationID, id uuid.UUID, version int, approver string) (Payment, error)
+	// Reject records the rejection of a pending payment and rejects it. A payment that has already been
+	// approved is rejected without recording a decision, e.g. when the payment scheme rejects it.
+	Reject(organisationID, id uuid.UUID, version int, approver string) (Payment, error)
+	// GetApprovals lists the decisions recorded for a payment in the order they were made
+	GetApprovals(organisationID, id uuid.UUID) ([]Approval, error)
+	// GetThresholds lists the thresholds of the organisation by currency
+	GetThresholds(organisationID uuid.UUID) ([]Threshold, error)
+	// SetThreshold creates or replaces the threshold of the organisation for a currency
+	SetThreshold(organisationID uuid.UUID, threshold Threshold) error
+	// RemoveThreshold removes the threshold of the organisation for a currency, after which its payments
+	// in that currency need a single approval
+	RemoveThreshold(organisationID uuid.UUID, currency string) error
+}
+
+// Decision is what an approver decided about a payment
+type Decision string
+
+const (
+	DecisionApproved Decision = "approved"
+	DecisionRejected Decision = "rejected"
+)
+
+// Approval is the decision of an approver about a version of a payment
+type Approval struct {
+	PaymentID      uuid.UUID `json:"payment_id"`
+	PaymentVersion int       `json:"payment_version"`
+	Approver       string    `json:"approver"`
+	Decision       Decision  `json:"decision"`
+	RecordedAt     time.Time `json:"recorded_at"`
+}
+
+// Threshold is the amount in a currency above which payments need Approvals approvals
+type Threshold struct {
+	Currency  string  `json:"currency"`
+	Amount    Decimal `json:"amount"`
+	Approvals int     `json:"approvals"`
+}
+
+// Quorum is the number of approvals a payment needs. A nil threshold, or one for another currency, needs
+// a single approval. A payment whose amount cannot be read is treated as above the threshold.
+func (t *Threshold) Quorum(attributes Attributes) int {
+	if t == nil || attributes.Currency != t.Currency {
+		return 1
+	}
+	amount, err := ParseDecimal(attributes.Amount)
+	if err == nil && amount.Cmp(t.Amount) <= 0 {
+		return 1
+	}
+	return t.Approvals
+}
+
+// Valid reports whether the threshold has an ISO 4217 currency code, an amount that is not negative and
+// at least one approval
+func (t Threshold) Valid() bool {
+	return CheckCurrency(t.Currency) == nil && t.Amount.Sign() >= 0 && t.Approvals >= 1
+}
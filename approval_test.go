@@ -0,0 +1,40 @@
+package acme_test
+
+import (
+	"testing"
+
+	"github.com/steinfletcher/payments"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThreshold_Quorum(t *testing.T) {
+	threshold := &acme.Threshold{Currency: "GBP", Amount: acme.NewDecimal(1000000, 2), Approvals: 2}
+	tests := map[string]struct {
+		threshold *acme.Threshold
+		amount    string
+		currency  string
+		approvals int
+	}{
+		"no threshold":      {nil, "50000.00", "GBP", 1},
+		"below":             {threshold, "9999.99", "GBP", 1},
+		"at the threshold":  {threshold, "10000.00", "GBP", 1},
+		"above":             {threshold, "10000.01", "GBP", 2},
+		"other currency":    {threshold, "50000.00", "EUR", 1},
+		"unreadable amount": {threshold, "lots", "GBP", 2},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			approvals := tt.threshold.Quorum(acme.Attributes{Amount: tt.amount, Currency: tt.currency})
+
+			assert.Equal(t, tt.approvals, approvals)
+		})
+	}
+}
+
+func TestThreshold_Valid(t *testing.T) {
+	assert.True(t, acme.Threshold{Currency: "GBP", Amount: acme.NewDecimal(0, 0), Approvals: 1}.Valid())
+	assert.False(t, acme.Threshold{Currency: "", Amount: acme.NewDecimal(100, 0), Approvals: 2}.Valid())
+	assert.False(t, acme.Threshold{Currency: "XYZ", Amount: acme.NewDecimal(100, 0), Approvals: 2}.Valid())
+	assert.False(t, acme.Threshold{Currency: "GBP", Amount: acme.NewDecimal(-100, 0), Approvals: 2}.Valid())
+	assert.False(t, acme.Threshold{Currency: "GBP", Amount: acme.NewDecimal(100, 0), Approvals: 0}.Valid())
+}
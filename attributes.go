@@ -0,0 +1,217 @@
+package acme
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Attributes is the domain model of a payment's attributes as described by AttributesSchema.
+// Fields that are not part of the model are kept in Extra, here and in the nested objects, so that
+// they survive a round trip through the API and the database.
+type Attributes struct {
+	Amount               string              `json:"amount,omitempty"`
+	BeneficiaryParty     *Party              `json:"beneficiary_party,omitempty"`
+	ChargesInformation   *ChargesInformation `json:"charges_information,omitempty"`
+	Currency             string              `json:"currency,omitempty"`
+	DebtorParty          *Party              `json:"debtor_party,omitempty"`
+	EndToEndReference    string              `json:"end_to_end_reference,omitempty"`
+	FX                   *FX                 `json:"fx,omitempty"`
+	NumericReference     string              `json:"numeric_reference,omitempty"`
+	PaymentID            string              `json:"payment_id,omitempty"`
+	PaymentPurpose       string              `json:"payment_purpose,omitempty"`
+	PaymentScheme        string              `json:"payment_scheme,omitempty"`
+	PaymentType          string              `json:"payment_type,omitempty"`
+	ProcessingDate       string              `json:"processing_date,omitempty"`
+	Reference            string              `json:"reference,omitempty"`
+	SchemePaymentSubType string              `json:"scheme_payment_sub_type,omitempty"`
+	SchemePaymentType    string              `json:"scheme_payment_type,omitempty"`
+	SponsorParty         *Party              `json:"sponsor_party,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Party is a debtor, beneficiary or sponsor of a payment
+type Party struct {
+	AccountName       string `json:"account_name,omitempty"`
+	AccountNumber     string `json:"account_number,omitempty"`
+	AccountNumberCode string `json:"account_number_code,omitempty"`
+	AccountType       *int   `json:"account_type,omitempty"`
+	Address           string `json:"address,omitempty"`
+	BankID            string `json:"bank_id,omitempty"`
+	BankIDCode        string `json:"bank_id_code,omitempty"`
+	Name              string `json:"name,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// ChargesInformation is who bears the charges of a payment and the charges of the sender and the receiver
+type ChargesInformation struct {
+	BearerCode              string   `json:"bearer_code,omitempty"`
+	SenderCharges           []Charge `json:"sender_charges"`
+	ReceiverChargesAmount   string   `json:"receiver_charges_amount,omitempty"`
+	ReceiverChargesCurrency string   `json:"receiver_charges_currency,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Charge is an amount charged by the sender of a payment
+type Charge struct {
+	Amount   string `json:"amount,omitempty"`
+	Currency string `json:"currency,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// FX is the foreign exchange of a payment from its original amount to its amount at the exchange rate
+type FX struct {
+	ContractReference string `json:"contract_reference,omitempty"`
+	ExchangeRate      string `json:"exchange_rate,omitempty"`
+	OriginalAmount    string `json:"original_amount,omitempty"`
+	OriginalCurrency  string `json:"original_currency,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// attributes, party, chargesInformation, charge and fx have the same fields as the types they are named
+// after without the custom JSON methods
+type (
+	attributes         Attributes
+	party              Party
+	chargesInformation ChargesInformation
+	charge             Charge
+	fx                 FX
+)
+
+// the JSON names of the modelled fields of each type
+var (
+	attributeFields          = jsonFieldNames(reflect.TypeOf(Attributes{}))
+	partyFields              = jsonFieldNames(reflect.TypeOf(Party{}))
+	chargesInformationFields = jsonFieldNames(reflect.TypeOf(ChargesInformation{}))
+	chargeFields             = jsonFieldNames(reflect.TypeOf(Charge{}))
+	fxFields                 = jsonFieldNames(reflect.TypeOf(FX{}))
+)
+
+func (a Attributes) MarshalJSON() ([]byte, error) {
+	return marshalWithExtra(attributes(a), a.Extra)
+}
+
+func (a *Attributes) UnmarshalJSON(data []byte) error {
+	var known attributes
+	err := json.Unmarshal(data, &known)
+	if err != nil {
+		return err
+	}
+	known.Extra, err = extraFields(data, attributeFields)
+	*a = Attributes(known)
+	return err
+}
+
+func (p Party) MarshalJSON() ([]byte, error) {
+	return marshalWithExtra(party(p), p.Extra)
+}
+
+func (p *Party) UnmarshalJSON(data []byte) error {
+	var known party
+	err := json.Unmarshal(data, &known)
+	if err != nil {
+		return err
+	}
+	known.Extra, err = extraFields(data, partyFields)
+	*p = Party(known)
+	return err
+}
+
+func (c ChargesInformation) MarshalJSON() ([]byte, error) {
+	return marshalWithExtra(chargesInformation(c), c.Extra)
+}
+
+func (c *ChargesInformation) UnmarshalJSON(data []byte) error {
+	var known chargesInformation
+	err := json.Unmarshal(data, &known)
+	if err != nil {
+		return err
+	}
+	known.Extra, err = extraFields(data, chargesInformationFields)
+	*c = ChargesInformation(known)
+	return err
+}
+
+func (c Charge) MarshalJSON() ([]byte, error) {
+	return marshalWithExtra(charge(c), c.Extra)
+}
+
+func (c *Charge) UnmarshalJSON(data []byte) error {
+	var known charge
+	err := json.Unmarshal(data, &known)
+	if err != nil {
+		return err
+	}
+	known.Extra, err = extraFields(data, chargeFields)
+	*c = Charge(known)
+	return err
+}
+
+func (f FX) MarshalJSON() ([]byte, error) {
+	return marshalWithExtra(fx(f), f.Extra)
+}
+
+func (f *FX) UnmarshalJSON(data []byte) error {
+	var known fx
+	err := json.Unmarshal(data, &known)
+	if err != nil {
+		return err
+	}
+	known.Extra, err = extraFields(data, fxFields)
+	*f = FX(known)
+	return err
+}
+
+// marshalWithExtra marshals the modelled fields of an object and adds the extra fields that are not
+// modelled. A modelled field takes precedence over an extra field of the same name.
+func marshalWithExtra(known interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(known)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range extra {
+		if _, ok := fields[name]; !ok {
+			fields[name] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// extraFields returns the fields of an object that are not modelled, or nil if every field is modelled
+func extraFields(data []byte, modelled []string) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range modelled {
+		delete(fields, name)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+func jsonFieldNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
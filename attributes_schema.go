@@ -34,7 +34,7 @@ const AttributesSchema = `{
       "examples": [
         "100.21"
       ],
-      "pattern": "^(.*)$"
+      "pattern": "^[0-9]+(\\.[0-9]+)?$"
     },
     "beneficiary_party": {
       "$id": "#/properties/beneficiary_party",
@@ -174,7 +174,7 @@ const AttributesSchema = `{
                 "examples": [
                   "5.00"
                 ],
-                "pattern": "^(.*)$"
+                "pattern": "^[0-9]+(\\.[0-9]+)?$"
               },
               "currency": {
                 "$id": "#/properties/charges_information/properties/sender_charges/items/properties/currency",
@@ -197,7 +197,7 @@ const AttributesSchema = `{
           "examples": [
             "1.00"
           ],
-          "pattern": "^(.*)$"
+          "pattern": "^[0-9]+(\\.[0-9]+)?$"
         },
         "receiver_charges_currency": {
           "$id": "#/properties/charges_information/properties/receiver_charges_currency",
@@ -251,7 +251,7 @@ const AttributesSchema = `{
           "title": "The Account_number Schema",
           "default": "",
           "examples": [
-            "GB29XABC10161234567801"
+            "GB83XABC10161234567801"
           ],
           "pattern": "^(.*)$"
         },
@@ -346,7 +346,7 @@ const AttributesSchema = `{
           "examples": [
             "2.00000"
           ],
-          "pattern": "^(.*)$"
+          "pattern": "^[0-9]+(\\.[0-9]+)?$"
         },
         "original_amount": {
           "$id": "#/properties/fx/properties/original_amount",
@@ -356,7 +356,7 @@ const AttributesSchema = `{
           "examples": [
             "200.42"
           ],
-          "pattern": "^(.*)$"
+          "pattern": "^[0-9]+(\\.[0-9]+)?$"
         },
         "original_currency": {
           "$id": "#/properties/fx/properties/original_currency",
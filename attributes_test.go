@@ -0,0 +1,75 @@
+package acme_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/steinfletcher/payments"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttributes_UnmarshalJSON(t *testing.T) {
+	var attributes acme.Attributes
+
+	err := json.Unmarshal([]byte(`{
+		"amount": "100.21",
+		"currency": "GBP",
+		"beneficiary_party": {"name": "W Owens", "account_type": 0},
+		"fx": {"exchange_rate": "2.00000"}
+	}`), &attributes)
+
+	assert.NoError(t, err)
+	accountType := 0
+	assert.Equal(t, acme.Attributes{
+		Amount:           "100.21",
+		Currency:         "GBP",
+		BeneficiaryParty: &acme.Party{Name: "W Owens", AccountType: &accountType},
+		FX:               &acme.FX{ExchangeRate: "2.00000"},
+	}, attributes)
+}
+
+func TestAttributes_PreservesUnknownFields(t *testing.T) {
+	data := `{"amount":"100.21","legacy":{"key":"value"},"tags":["a","b"]}`
+	var attributes acme.Attributes
+
+	err := json.Unmarshal([]byte(data), &attributes)
+	assert.NoError(t, err)
+	assert.Equal(t, "100.21", attributes.Amount)
+	assert.Len(t, attributes.Extra, 2)
+
+	marshalled, err := json.Marshal(attributes)
+	assert.NoError(t, err)
+	assert.JSONEq(t, data, string(marshalled))
+}
+
+func TestAttributes_ModelledFieldsTakePrecedenceOverExtra(t *testing.T) {
+	attributes := acme.Attributes{
+		Currency: "GBP",
+		Extra:    map[string]json.RawMessage{"currency": json.RawMessage(`"USD"`)},
+	}
+
+	marshalled, err := json.Marshal(attributes)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"currency":"GBP"}`, string(marshalled))
+}
+
+func TestAttributes_PreservesUnknownNestedFields(t *testing.T) {
+	data := `{
+		"beneficiary_party": {"name": "W Owens", "legacy": "party"},
+		"charges_information": {"sender_charges": [{"amount": "5.00", "legacy": "charge"}], "legacy": "charges"},
+		"fx": {"exchange_rate": "2.00000", "legacy": "fx"}
+	}`
+	var attributes acme.Attributes
+
+	err := json.Unmarshal([]byte(data), &attributes)
+	assert.NoError(t, err)
+	assert.Equal(t, "W Owens", attributes.BeneficiaryParty.Name)
+	assert.Equal(t, json.RawMessage(`"party"`), attributes.BeneficiaryParty.Extra["legacy"])
+	assert.Equal(t, json.RawMessage(`"charge"`), attributes.ChargesInformation.SenderCharges[0].Extra["legacy"])
+	assert.Nil(t, attributes.Extra)
+
+	marshalled, err := json.Marshal(attributes)
+	assert.NoError(t, err)
+	assert.JSONEq(t, data, string(marshalled))
+}
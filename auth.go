@@ -0,0 +1,64 @@
+package acme
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+//go:generate pegomock generate --use-experimental-model-gen --output-dir mocks Authenticator
+
+// Scopes grant a principal access to the operations on payments
+const (
+	ScopeRead   = "payments:read"
+	ScopeWrite  = "payments:write"
+	ScopeDelete = "payments:delete"
+)
+
+// Principal is the authenticated client a request is made by. Every principal acts on behalf of a
+// single organisation.
+type Principal struct {
+	// Subject identifies the client, e.g. the ID of an API key or the subject of a token
+	Subject        string
+	OrganisationID uuid.UUID
+	Scopes         []string
+}
+
+// HasAnyScope reports whether the principal has been granted at least one of the scopes
+func (p Principal) HasAnyScope(scopes ...string) bool {
+	for _, granted := range p.Scopes {
+		for _, scope := range scopes {
+			if granted == scope {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Authenticator identifies the principal that holds a credential, e.g. an API key or a bearer token.
+// A credential that is not valid is Unauthorized.
+type Authenticator interface {
+	Authenticate(credential string) (Principal, error)
+}
+
+// APIKeyService manages the API keys that clients authenticate with. Only a hash of each key is
+// stored, so a key cannot be read back once it has been created.
+type APIKeyService interface {
+	Authenticator
+	// Create creates an API key for the organisation with the given scopes and returns it along with
+	// the secret key, which is the credential given to the client
+	Create(organisationID uuid.UUID, name string, scopes []string) (APIKey, string, error)
+	// Revoke stops an API key of the organisation from authenticating
+	Revoke(organisationID, id uuid.UUID) error
+}
+
+// APIKey describes an API key without its secret
+type APIKey struct {
+	ID             uuid.UUID  `json:"id"`
+	OrganisationID uuid.UUID  `json:"organisation_id"`
+	Name           string     `json:"name"`
+	Scopes         []string   `json:"scopes"`
+	CreatedAt      time.Time  `json:"created_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+}
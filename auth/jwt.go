@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/steinfletcher/payments"
+
+	// register the hash functions used by the supported algorithms
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// clockSkew is how far the clocks of the token issuer and the API may differ
+const clockSkew = time.Minute
+
+// organisationClaim is the claim that holds the ID of the organisation the client acts for
+const organisationClaim = "organisation_id"
+
+type algorithm struct {
+	hash crypto.Hash
+	// curveBits is the size of the curve of an ECDSA algorithm, zero for RSA
+	curveBits int
+}
+
+var algorithms = map[string]algorithm{
+	"RS256": {crypto.SHA256, 0},
+	"RS384": {crypto.SHA384, 0},
+	"RS512": {crypto.SHA512, 0},
+	"ES256": {crypto.SHA256, 256},
+	"ES384": {crypto.SHA384, 384},
+	"ES512": {crypto.SHA512, 521},
+}
+
+type tokenAuthenticator struct {
+	keys     *KeySet
+	issuer   string
+	audience string
+	now      func() time.Time
+}
+
+type header struct {
+	Alg  string   `json:"alg"`
+	Kid  string   `json:"kid"`
+	Crit []string `json:"crit"`
+}
+
+type claims struct {
+	Subject        string   `json:"sub"`
+	Issuer         string   `json:"iss"`
+	Audience       audience `json:"aud"`
+	ExpiresAt      *int64   `json:"exp"`
+	NotBefore      *int64   `json:"nbf"`
+	OrganisationID string   `json:"organisation_id"`
+	// Scope is the space separated list of scopes granted to the client
+	Scope string `json:"scope"`
+}
+
+// audience is the aud claim, which is either a single string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	err := json.Unmarshal(data, &multiple)
+	*a = multiple
+	return err
+}
+
+func (a audience) contains(value string) bool {
+	for _, v := range a {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// NewTokenAuthenticator authenticates clients with JSON Web Tokens (RFC 7519) signed by one of the keys.
+// A token must expire and must carry the organisation the client acts for in the organisation_id claim.
+// The scopes of the client are read from the scope claim.
+// The issuer and audience are only checked when they are set. now is the current time.
+func NewTokenAuthenticator(keys *KeySet, issuer, audience string, now func() time.Time) acme.Authenticator {
+	return &tokenAuthenticator{keys: keys, issuer: issuer, audience: audience, now: now}
+}
+
+func (a *tokenAuthenticator) Authenticate(token string) (acme.Principal, error) {
+	c, reason := a.verify(token)
+	if reason != "" {
+		err := acme.Unauthorized
+		err.Detail = "The token is not valid: " + reason
+		return acme.Principal{}, err
+	}
+	organisationID, err := uuid.Parse(c.OrganisationID)
+	if err != nil || organisationID == uuid.Nil {
+		err := acme.Unauthorized
+		err.Detail = "The token is not valid: the " + organisationClaim + " claim must be the ID of an organisation"
+		return acme.Principal{}, err
+	}
+	return acme.Principal{Subject: c.Subject, OrganisationID: organisationID, Scopes: strings.Fields(c.Scope)}, nil
+}
+
+// verify checks the signature and the time and audience restrictions of a token. The reason a token is
+// rejected is empty when it is valid.
+func (a *tokenAuthenticator) verify(token string) (claims, string) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims{}, "it is not a signed JSON Web Token"
+	}
+
+	var h header
+	if !decodeSegment(parts[0], &h) {
+		return claims{}, "the header is malformed"
+	}
+	if len(h.Crit) > 0 {
+		return claims{}, "critical header parameters are not supported"
+	}
+	alg, ok := algorithms[h.Alg]
+	if !ok {
+		return claims{}, "the algorithm is not supported"
+	}
+	k, ok := a.keys.find(h.Kid)
+	if !ok {
+		return claims{}, "the signing key is not known"
+	}
+	if k.algorithm != "" && k.algorithm != h.Alg {
+		return claims{}, "the algorithm does not match the signing key"
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !verifySignature(k.public, alg, parts[0]+"."+parts[1], signature) {
+		return claims{}, "the signature is not valid"
+	}
+
+	var c claims
+	if !decodeSegment(parts[1], &c) {
+		return claims{}, "the claims are malformed"
+	}
+	now := a.now()
+	if c.ExpiresAt == nil {
+		return claims{}, "the token does not expire"
+	}
+	if !now.Before(time.Unix(*c.ExpiresAt, 0).Add(clockSkew)) {
+		return claims{}, "the token has expired"
+	}
+	if c.NotBefore != nil && now.Add(clockSkew).Before(time.Unix(*c.NotBefore, 0)) {
+		return claims{}, "the token is not valid yet"
+	}
+	if a.issuer != "" && c.Issuer != a.issuer {
+		return claims{}, "the issuer is not trusted"
+	}
+	if a.audience != "" && !c.Audience.contains(a.audience) {
+		return claims{}, "the token is meant for another audience"
+	}
+	if c.Subject == "" {
+		return claims{}, "the token does not have a subject"
+	}
+	return c, ""
+}
+
+func verifySignature(public crypto.PublicKey, alg algorithm, signed string, signature []byte) bool {
+	h := alg.hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		if alg.curveBits != 0 {
+			return false
+		}
+		return rsa.VerifyPKCS1v15(public, alg.hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		if alg.curveBits == 0 || public.Curve.Params().BitSize != alg.curveBits {
+			return false
+		}
+		// the signature is the concatenation of r and s, each padded to the size of the curve
+		size := (alg.curveBits + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(public, digest, r, s)
+	default:
+		return false
+	}
+}
+
+func decodeSegment(segment string, v interface{}) bool {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
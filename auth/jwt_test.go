@@ -0,0 +1,204 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/steinfletcher/payments"
+	"github.com/steinfletcher/payments/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	rsaKey, _      = rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _       = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	organisationID = uuid.MustParse("743d5b63-8e6f-432e-a8fa-c5d8d2ee5fcb")
+	now            = time.Date(2019, 5, 27, 12, 0, 0, 0, time.UTC)
+)
+
+func TestAuthenticate_RSA(t *testing.T) {
+	token := rsaToken("rsa-1", validClaims())
+
+	principal, err := authenticator(t).Authenticate(token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, acme.Principal{
+		Subject:        "client-1",
+		OrganisationID: organisationID,
+		Scopes:         []string{acme.ScopeRead, acme.ScopeWrite},
+	}, principal)
+}
+
+func TestAuthenticate_ECDSA(t *testing.T) {
+	token := ecToken("ec-1", validClaims())
+
+	principal, err := authenticator(t).Authenticate(token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, acme.Principal{
+		Subject:        "client-1",
+		OrganisationID: organisationID,
+		Scopes:         []string{acme.ScopeRead, acme.ScopeWrite},
+	}, principal)
+}
+
+func TestAuthenticate_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		token  string
+		reason string
+	}{
+		"not a token":     {"abc", "it is not a signed JSON Web Token"},
+		"unsigned":        {sign(map[string]interface{}{"alg": "none"}, validClaims(), nil), "the algorithm is not supported"},
+		"unknown key":     {rsaToken("rsa-2", validClaims()), "the signing key is not known"},
+		"wrong key type":  {sign(map[string]interface{}{"alg": "RS256", "kid": "ec-1"}, validClaims(), rsaSigner), "the signature is not valid"},
+		"tampered":        {tampered(rsaToken("rsa-1", validClaims())), "the signature is not valid"},
+		"expired":         {rsaToken("rsa-1", with("exp", now.Add(-2*time.Minute).Unix())), "the token has expired"},
+		"does not expire": {rsaToken("rsa-1", with("exp", nil)), "the token does not expire"},
+		"not valid yet":   {rsaToken("rsa-1", with("nbf", now.Add(2*time.Minute).Unix())), "the token is not valid yet"},
+		"other issuer":    {rsaToken("rsa-1", with("iss", "https://other.example.com")), "the issuer is not trusted"},
+		"other audience":  {rsaToken("rsa-1", with("aud", []string{"other"})), "the token is meant for another audience"},
+		"no subject":      {rsaToken("rsa-1", with("sub", "")), "the token does not have a subject"},
+		"no organisation": {rsaToken("rsa-1", with("organisation_id", nil)), "the organisation_id claim must be the ID of an organisation"},
+		"wrong algorithm": {sign(map[string]interface{}{"alg": "RS384", "kid": "rsa-1"}, validClaims(), rsaSigner), "the algorithm does not match the signing key"},
+		"critical":        {sign(map[string]interface{}{"alg": "RS256", "kid": "rsa-1", "crit": []string{"exp"}}, validClaims(), rsaSigner), "critical header parameters are not supported"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := authenticator(t).Authenticate(test.token)
+
+			assert.Equal(t, acme.Unauthorized.Code, err.(acme.Error).Code)
+			assert.Equal(t, "The token is not valid: "+test.reason, err.(acme.Error).Detail)
+		})
+	}
+}
+
+func TestAuthenticate_ClockSkew(t *testing.T) {
+	token := rsaToken("rsa-1", with("exp", now.Add(-30*time.Second).Unix()))
+
+	_, err := authenticator(t).Authenticate(token)
+
+	assert.NoError(t, err)
+}
+
+func TestParseKeySet_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"malformed":        `{"keys":`,
+		"no signing keys":  `{"keys":[{"kty":"RSA","use":"enc","n":"AQAB","e":"AQAB"}]}`,
+		"unsupported type": `{"keys":[{"kty":"oct","k":"c2VjcmV0"}]}`,
+		"point off curve":  `{"keys":[{"kty":"EC","crv":"P-256","x":"AQ","y":"AQ"}]}`,
+	}
+	for name, keySet := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := auth.ParseKeySet([]byte(keySet))
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func authenticator(t *testing.T) acme.Authenticator {
+	keys, err := auth.ParseKeySet([]byte(fmt.Sprintf(`{"keys": [
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "alg": "RS256", "n": "%s", "e": "%s"},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": "%s", "y": "%s"},
+		{"kty": "RSA", "kid": "rsa-enc", "use": "enc", "n": "%s", "e": "%s"}
+	]}`, encodeInt(rsaKey.N), encodeInt(big.NewInt(int64(rsaKey.E))), encodeInt(ecKey.X), encodeInt(ecKey.Y),
+		encodeInt(rsaKey.N), encodeInt(big.NewInt(int64(rsaKey.E))))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth.NewTokenAuthenticator(keys, "https://issuer.example.com", "payments", func() time.Time {
+		return now
+	})
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":             "client-1",
+		"iss":             "https://issuer.example.com",
+		"aud":             "payments",
+		"exp":             now.Add(time.Hour).Unix(),
+		"nbf":             now.Add(-time.Hour).Unix(),
+		"organisation_id": organisationID.String(),
+		"scope":           "payments:read payments:write",
+	}
+}
+
+// with returns the valid claims with one claim changed. A nil value removes the claim.
+func with(name string, value interface{}) map[string]interface{} {
+	claims := validClaims()
+	if value == nil {
+		delete(claims, name)
+	} else {
+		claims[name] = value
+	}
+	return claims
+}
+
+func rsaToken(kid string, claims map[string]interface{}) string {
+	return sign(map[string]interface{}{"alg": "RS256", "kid": kid}, claims, rsaSigner)
+}
+
+func ecToken(kid string, claims map[string]interface{}) string {
+	return sign(map[string]interface{}{"alg": "ES256", "kid": kid}, claims, ecSigner)
+}
+
+func sign(header, claims map[string]interface{}, signer func(digest []byte) []byte) string {
+	signed := encodeSegment(header) + "." + encodeSegment(claims)
+	if signer == nil {
+		return signed + "."
+	}
+	digest := sha256.Sum256([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signer(digest[:]))
+}
+
+func rsaSigner(digest []byte) []byte {
+	signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest)
+	if err != nil {
+		panic(err)
+	}
+	return signature
+}
+
+func ecSigner(digest []byte) []byte {
+	r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest)
+	if err != nil {
+		panic(err)
+	}
+	// r and s are padded to the size of the curve
+	signature := make([]byte, 64)
+	rBytes, sBytes := r.Bytes(), s.Bytes()
+	copy(signature[32-len(rBytes):32], rBytes)
+	copy(signature[64-len(sBytes):], sBytes)
+	return signature
+}
+
+// tampered changes the subject of a token without signing it again
+func tampered(token string) string {
+	parts := strings.Split(token, ".")
+	parts[1] = encodeSegment(with("sub", "client-2"))
+	return strings.Join(parts, ".")
+}
+
+func encodeSegment(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func encodeInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
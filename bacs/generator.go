@@ -0,0 +1,67 @@
+package bacs
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/steinfletcher/payments"
+)
+
+// PaymentScheme is the payment scheme of the payments that are submitted in Standard 18 files
+const PaymentScheme = "BACS"
+
+// Generator builds the Standard 18 files of the payments of an organisation
+type Generator struct {
+	service acme.PaymentService
+	// pageSize is the number of payments read from the service at a time
+	pageSize int
+}
+
+func NewGenerator(service acme.PaymentService) *Generator {
+	return &Generator{service: service, pageSize: 100}
+}
+
+// Eligible reads the payments of the organisation that are submitted on a processing date, which are the
+// approved BACS credits with that processing date
+func (g *Generator) Eligible(organisationID uuid.UUID, processingDate time.Time) ([]acme.Payment, error) {
+	query := acme.PaymentQuery{Limit: g.pageSize, Filter: acme.PaymentFilter{
+		PaymentScheme: PaymentScheme,
+		PaymentType:   "Credit",
+		Status:        acme.StatusApproved,
+		ProcessedFrom: processingDate,
+		ProcessedTo:   processingDate,
+	}}
+
+	var payments []acme.Payment
+	for {
+		page, err := g.service.List(organisationID, query)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, page.Payments...)
+		if page.Next == nil {
+			return payments, nil
+		}
+		query.Cursor = *page.Next
+	}
+}
+
+// Submit moves the payments to submitted, so that they are not in the file of another processing run. A
+// payment that has changed since it was read, for example because it has been cancelled, is left out. On
+// any other error Submit stops and returns the payments it has submitted with the error, as they are only
+// paid by a file of them.
+func (g *Generator) Submit(organisationID uuid.UUID, payments []acme.Payment, recordedBy string) ([]acme.Payment, error) {
+	var submitted []acme.Payment
+	for _, p := range payments {
+		payment, err := g.service.Transition(organisationID, p.ID, acme.ActionSubmit, p.Version, recordedBy)
+		if e, ok := errors.Cause(err).(acme.Error); ok && e.Kind == acme.KindConflict {
+			continue
+		}
+		if err != nil {
+			return submitted, err
+		}
+		submitted = append(submitted, payment)
+	}
+	return submitted, nil
+}
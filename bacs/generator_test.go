@@ -0,0 +1,76 @@
+package bacs_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	m "github.com/petergtz/pegomock"
+	"github.com/steinfletcher/payments"
+	"github.com/steinfletcher/payments/bacs"
+	"github.com/steinfletcher/payments/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerator_Eligible(t *testing.T) {
+	organisationID := uuid.MustParse("743d5b63-8e6f-432e-a8fa-c5d8d2ee5fcb")
+	payments := readPayments(t)
+	date := time.Date(2019, 6, 20, 0, 0, 0, 0, time.UTC)
+	query := acme.PaymentQuery{Limit: 100, Filter: acme.PaymentFilter{
+		PaymentScheme: "BACS",
+		PaymentType:   "Credit",
+		Status:        acme.StatusApproved,
+		ProcessedFrom: date,
+		ProcessedTo:   date,
+	}}
+	service := mocks.NewMockPaymentService()
+	m.When(service.List(organisationID, query)).ThenReturn(acme.PaymentPage{
+		Payments: payments[:2],
+		Next:     acme.CursorAfter(payments[1].ID),
+	}, nil)
+	query.Cursor = *acme.CursorAfter(payments[1].ID)
+	m.When(service.List(organisationID, query)).ThenReturn(acme.PaymentPage{Payments: payments[2:]}, nil)
+
+	eligible, err := bacs.NewGenerator(service).Eligible(organisationID, date)
+	assert.NoError(t, err)
+	assert.Equal(t, payments, eligible)
+}
+
+func TestGenerator_Submit(t *testing.T) {
+	organisationID := uuid.MustParse("743d5b63-8e6f-432e-a8fa-c5d8d2ee5fcb")
+	payments := readPayments(t)
+	service := mocks.NewMockPaymentService()
+	var submitted []acme.Payment
+	for i, p := range payments {
+		s := p
+		s.Status = acme.StatusSubmitted
+		if i == 1 {
+			m.When(service.Transition(organisationID, p.ID, acme.ActionSubmit, p.Version, "bacs")).
+				ThenReturn(acme.Payment{}, acme.VersionConflict)
+			continue
+		}
+		m.When(service.Transition(organisationID, p.ID, acme.ActionSubmit, p.Version, "bacs")).ThenReturn(s, nil)
+		submitted = append(submitted, s)
+	}
+
+	result, err := bacs.NewGenerator(service).Submit(organisationID, payments, "bacs")
+	assert.NoError(t, err)
+	assert.Equal(t, submitted, result)
+}
+
+func TestGenerator_SubmitStopsAtError(t *testing.T) {
+	organisationID := uuid.MustParse("743d5b63-8e6f-432e-a8fa-c5d8d2ee5fcb")
+	payments := readPayments(t)
+	service := mocks.NewMockPaymentService()
+	m.When(service.Transition(organisationID, payments[0].ID, acme.ActionSubmit, payments[0].Version, "bacs")).
+		ThenReturn(payments[0], nil)
+	m.When(service.Transition(organisationID, payments[1].ID, acme.ActionSubmit, payments[1].Version, "bacs")).
+		ThenReturn(acme.Payment{}, errors.New("connection reset"))
+
+	result, err := bacs.NewGenerator(service).Submit(organisationID, payments, "bacs")
+	assert.EqualError(t, err, "connection reset")
+	assert.Equal(t, payments[:1], result)
+	service.VerifyWasCalled(m.Never()).
+		Transition(organisationID, payments[2].ID, acme.ActionSubmit, payments[2].Version, "bacs")
+}
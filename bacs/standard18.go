@@ -0,0 +1,244 @@
+// Package bacs builds the Standard 18 files that BACS payments are submitted in. A file has the VOL1,
+// HDR1, HDR2 and UHL1 labels, a detail record for each payment followed by a contra record for each
+// originating account, and the EOF1, EOF2 and UTL1 labels. Labels are 80 characters and data records 100.
+package bacs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/steinfletcher/payments"
+)
+
+const (
+	labelLength  = 80
+	recordLength = 100
+	// creditCode and contraCode are the transaction codes of a credit and of the debit that balances the
+	// credits from an originating account
+	creditCode = "99"
+	contraCode = "17"
+	// maxAmount is the largest amount in pence that fits the 11 digits of the amount field
+	maxAmount = 99999999999
+)
+
+var (
+	serviceUserNumberPattern = regexp.MustCompile(`^[0-9]{6}$`)
+	// disallowed matches the characters that are not allowed in the text fields of a record
+	disallowed = regexp.MustCompile(`[^A-Z0-9./&\- ]`)
+)
+
+// Header identifies the service user that submits a file and the day its payments are processed
+type Header struct {
+	// ServiceUserNumber is the 6 digit number that BACS knows the service user by
+	ServiceUserNumber string
+	// SerialNumber is the 6 character volume serial number that identifies the submission
+	SerialNumber   string
+	ProcessingDate time.Time
+	Created        time.Time
+}
+
+// NewHeader creates a header with a serial number from the time of day it is created at
+func NewHeader(serviceUserNumber string, processingDate, created time.Time) Header {
+	return Header{
+		ServiceUserNumber: serviceUserNumber,
+		SerialNumber:      created.UTC().Format("150405"),
+		ProcessingDate:    processingDate,
+		Created:           created,
+	}
+}
+
+// CheckServiceUserNumber checks that a service user number is 6 digits
+func CheckServiceUserNumber(number string) error {
+	if !serviceUserNumberPattern.MatchString(number) {
+		return fmt.Errorf("the service user number must be 6 digits")
+	}
+	return nil
+}
+
+// Record is a detail or a contra record. Amounts are in pence.
+type Record struct {
+	DestinationSortCode      string
+	DestinationAccountNumber string
+	TransactionCode          string
+	OriginatingSortCode      string
+	OriginatingAccountNumber string
+	Amount                   int64
+	// UserName is the name of the originator that the beneficiary sees, which is blank on a contra
+	UserName        string
+	UserReference   string
+	DestinationName string
+}
+
+// File is a Standard 18 file with the records in the order they are written
+type File struct {
+	Header  Header
+	Records []Record
+}
+
+// NewFile maps payments to a file with a detail record for each payment. The credits from the same
+// originating account are followed by a contra record that debits their total from it.
+func NewFile(header Header, payments []acme.Payment) (File, error) {
+	if err := CheckServiceUserNumber(header.ServiceUserNumber); err != nil {
+		return File{}, err
+	}
+	if len(payments) == 0 {
+		return File{}, fmt.Errorf("there are no payments to submit")
+	}
+
+	var originators []string
+	credits := map[string][]Record{}
+	for _, p := range payments {
+		credit, err := detail(p)
+		if err != nil {
+			return File{}, fmt.Errorf("payment %s: %s", p.ID, err)
+		}
+		originator := credit.OriginatingSortCode + credit.OriginatingAccountNumber
+		if _, ok := credits[originator]; !ok {
+			originators = append(originators, originator)
+		}
+		credits[originator] = append(credits[originator], credit)
+	}
+
+	file := File{Header: header}
+	for _, originator := range originators {
+		contra := Record{TransactionCode: contraCode, UserReference: "CONTRA"}
+		for _, credit := range credits[originator] {
+			contra.Amount += credit.Amount
+			contra.OriginatingSortCode, contra.OriginatingAccountNumber = credit.OriginatingSortCode,
+				credit.OriginatingAccountNumber
+			contra.DestinationName = credit.UserName
+		}
+		if contra.Amount > maxAmount {
+			return File{}, fmt.Errorf("the credits from %s total more than the amount field fits", originator)
+		}
+		contra.DestinationSortCode, contra.DestinationAccountNumber = contra.OriginatingSortCode,
+			contra.OriginatingAccountNumber
+		file.Records = append(append(file.Records, credits[originator]...), contra)
+	}
+	return file, nil
+}
+
+// detail maps a payment to the record of a credit from the account of the debtor party to the account of
+// the beneficiary party
+func detail(p acme.Payment) (Record, error) {
+	a := p.Attributes
+	if a.DebtorParty == nil || a.BeneficiaryParty == nil {
+		return Record{}, fmt.Errorf("debtor_party and beneficiary_party are required")
+	}
+	for _, field := range []string{"debtor_party", "beneficiary_party"} {
+		party := a.DebtorParty
+		if field == "beneficiary_party" {
+			party = a.BeneficiaryParty
+		}
+		if party.BankIDCode != "GBDSC" || acme.CheckSortCode(party.BankID) != nil {
+			return Record{}, fmt.Errorf("%s.bank_id must be a sort code", field)
+		}
+		if err := acme.CheckUKAccountNumber(party.AccountNumber); err != nil {
+			return Record{}, fmt.Errorf("%s.account_number: %s", field, err)
+		}
+	}
+
+	money, err := a.Money()
+	if err != nil {
+		return Record{}, fmt.Errorf("amount is not valid: %s", err)
+	}
+	if money.Currency != "GBP" {
+		return Record{}, fmt.Errorf("currency must be GBP")
+	}
+	pence, err := money.MinorAmount()
+	if err != nil || pence <= 0 || pence > maxAmount {
+		return Record{}, fmt.Errorf("amount must be more than 0 and fit 11 digits of pence")
+	}
+
+	return Record{
+		DestinationSortCode:      a.BeneficiaryParty.BankID,
+		DestinationAccountNumber: a.BeneficiaryParty.AccountNumber,
+		TransactionCode:          creditCode,
+		OriginatingSortCode:      a.DebtorParty.BankID,
+		OriginatingAccountNumber: a.DebtorParty.AccountNumber,
+		Amount:                   pence,
+		UserName:                 a.DebtorParty.AccountName,
+		UserReference:            a.Reference,
+		DestinationName:          a.BeneficiaryParty.AccountName,
+	}, nil
+}
+
+// Marshal renders the file as lines ending in CRLF
+func (f File) Marshal() []byte {
+	var credits, debits, creditCount, debitCount int64
+	for _, r := range f.Records {
+		if r.TransactionCode == contraCode {
+			debits, debitCount = debits+r.Amount, debitCount+1
+		} else {
+			credits, creditCount = credits+r.Amount, creditCount+1
+		}
+	}
+
+	lines := []string{f.volume(), f.fileLabel("HDR1"), f.recordLabel("HDR2"), f.userHeader()}
+	for _, r := range f.Records {
+		lines = append(lines, r.String())
+	}
+	lines = append(lines, f.fileLabel("EOF1"), f.recordLabel("EOF2"),
+		label("UTL1", numeric(debits, 13), numeric(credits, 13), numeric(debitCount, 7), numeric(creditCount, 7)))
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// String renders the record with its fields padded to their lengths. Text is upper case, with spaces in
+// place of the characters that records do not allow, and truncated to the length of its field.
+func (r Record) String() string {
+	return pad(r.DestinationSortCode+r.DestinationAccountNumber+"0"+r.TransactionCode+r.OriginatingSortCode+
+		r.OriginatingAccountNumber+"    "+numeric(r.Amount, 11)+alpha(r.UserName, 18)+alpha(r.UserReference, 18)+
+		alpha(r.DestinationName, 18), recordLength)
+}
+
+// volume is the VOL1 label, which names the service user as the owner of the volume
+func (f File) volume() string {
+	return label("VOL1", alpha(f.Header.SerialNumber, 6), " ", alpha("", 20), alpha("", 6),
+		alpha("", 4), f.Header.ServiceUserNumber, alpha("", 4), alpha("", 28), "1")
+}
+
+// fileLabel is the HDR1 or EOF1 label, which identifies the file and the day it was created
+func (f File) fileLabel(name string) string {
+	sun := f.Header.ServiceUserNumber
+	created := julian(f.Header.Created)
+	return label(name, "A"+sun+"S  1"+sun, alpha(f.Header.SerialNumber, 6), "0001", "0001", alpha("", 6),
+		created, created, " ", "000000")
+}
+
+// recordLabel is the HDR2 or EOF2 label, which describes the fixed length records of the file
+func (f File) recordLabel(name string) string {
+	return label(name, "F", numeric(recordLength*20, 5), numeric(recordLength, 5), alpha("", 35), "00")
+}
+
+// userHeader is the UHL1 label, which holds the day the payments are processed in sterling
+func (f File) userHeader() string {
+	return label("UHL1", julian(f.Header.ProcessingDate), "999999    ", "00", "000000", "1 DAILY  ", "001")
+}
+
+// label joins the fields of a label and pads it to the length of a label
+func label(name string, fields ...string) string {
+	return pad(name+strings.Join(fields, ""), labelLength)
+}
+
+// julian formats a date as a space, the year and the day of the year, e.g. " 19171" for 20 June 2019
+func julian(date time.Time) string {
+	return fmt.Sprintf(" %02d%03d", date.Year()%100, date.YearDay())
+}
+
+func alpha(value string, length int) string {
+	value = disallowed.ReplaceAllString(strings.ToUpper(value), " ")
+	if len(value) > length {
+		value = value[:length]
+	}
+	return pad(value, length)
+}
+
+func numeric(value int64, length int) string {
+	return fmt.Sprintf("%0*d", length, value)
+}
+
+func pad(value string, length int) string {
+	return value + strings.Repeat(" ", length-len(value))
+}
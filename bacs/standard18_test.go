@@ -0,0 +1,111 @@
+package bacs_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/steinfletcher/payments"
+	"github.com/steinfletcher/payments/bacs"
+	"github.com/stretchr/testify/assert"
+)
+
+var header = bacs.Header{
+	ServiceUserNumber: "123456",
+	SerialNumber:      "100000",
+	ProcessingDate:    time.Date(2019, 6, 20, 0, 0, 0, 0, time.UTC),
+	Created:           time.Date(2019, 6, 18, 10, 0, 0, 0, time.UTC),
+}
+
+func TestFile(t *testing.T) {
+	file, err := bacs.NewFile(header, readPayments(t))
+	assert.NoError(t, err)
+	assert.Equal(t, readFile(t, "testdata/standard18.txt"), string(file.Marshal()))
+}
+
+func TestFile_FieldLengths(t *testing.T) {
+	file, err := bacs.NewFile(header, readPayments(t))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(file.Marshal()), "\r\n"), "\r\n")
+	assert.Len(t, lines, 12)
+	for i, line := range lines {
+		if i >= 4 && i < 9 {
+			assert.Len(t, line, 100, line)
+		} else {
+			assert.Len(t, line, 80, line)
+		}
+	}
+}
+
+func TestFile_Contras(t *testing.T) {
+	file, err := bacs.NewFile(header, readPayments(t))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Len(t, file.Records, 5)
+	assert.Equal(t, bacs.Record{
+		DestinationSortCode:      "203301",
+		DestinationAccountNumber: "12345678",
+		TransactionCode:          "17",
+		OriginatingSortCode:      "203301",
+		OriginatingAccountNumber: "12345678",
+		Amount:                   135021,
+		UserReference:            "CONTRA",
+		DestinationName:          "EJ Brown Black",
+	}, file.Records[2])
+	assert.Equal(t, int64(7550), file.Records[4].Amount)
+	assert.Equal(t, "401276", file.Records[4].DestinationSortCode)
+}
+
+func TestFile_RejectsPaymentsThatDoNotFit(t *testing.T) {
+	tests := map[string]func(a *acme.Attributes){
+		"debtor_party and beneficiary_party are required":       func(a *acme.Attributes) { a.DebtorParty = nil },
+		"debtor_party.bank_id must be a sort code":              func(a *acme.Attributes) { a.DebtorParty.BankIDCode = "SWBIC" },
+		"beneficiary_party.bank_id must be a sort code":         func(a *acme.Attributes) { a.BeneficiaryParty.BankID = "40-30-00" },
+		"currency must be GBP":                                  func(a *acme.Attributes) { a.Currency = "EUR" },
+		"amount must be more than 0 and fit 11 digits of pence": func(a *acme.Attributes) { a.Amount = "0.00" },
+		"beneficiary_party.account_number: UK account numbers must be 8 digits": func(a *acme.Attributes) {
+			a.BeneficiaryParty.AccountNumber = "GB83XABC10161234567801"
+		},
+	}
+	for message, change := range tests {
+		payments := readPayments(t)
+		change(&payments[0].Attributes)
+
+		_, err := bacs.NewFile(header, payments)
+		assert.EqualError(t, err, "payment 4ee3a8d8-ca7b-4290-a52c-dd5b6165ec43: "+message)
+	}
+}
+
+func TestFile_RequiresServiceUserNumberAndPayments(t *testing.T) {
+	invalid := header
+	invalid.ServiceUserNumber = "12345"
+	_, err := bacs.NewFile(invalid, readPayments(t))
+	assert.EqualError(t, err, "the service user number must be 6 digits")
+
+	_, err = bacs.NewFile(header, nil)
+	assert.EqualError(t, err, "there are no payments to submit")
+}
+
+func readPayments(t *testing.T) []acme.Payment {
+	var payments []acme.Payment
+	err := json.Unmarshal([]byte(readFile(t, "testdata/payments.json")), &payments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payments
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -1,13 +1,25 @@
 package main
 
 import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/caarlos0/env"
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/pressly/goose"
+	"github.com/steinfletcher/payments"
 	"github.com/steinfletcher/payments/api"
+	"github.com/steinfletcher/payments/auth"
+	"github.com/steinfletcher/payments/bacs"
 	"github.com/steinfletcher/payments/postgres"
+	"github.com/steinfletcher/payments/scheme"
 
 	_ "github.com/lib/pq"
 	_ "github.com/steinfletcher/payments/migrations"
@@ -16,6 +28,19 @@ import (
 type config struct {
 	Port   string `env:"PORT" envDefault:"8080"`
 	DBAddr string `env:"DB_ADDR"`
+	// JWKSFile is a JSON Web Key Set of the keys that bearer tokens are signed with.
+	// Bearer tokens are not accepted when it is not set.
+	JWKSFile    string `env:"JWKS_FILE"`
+	JWTIssuer   string `env:"JWT_ISSUER"`
+	JWTAudience string `env:"JWT_AUDIENCE"`
+	// ReadScopes, WriteScopes and DeleteScopes are the scopes that allow a client to read payments, to
+	// create and update payments and to delete payments. A client needs one of the listed scopes.
+	ReadScopes   []string `env:"READ_SCOPES" envSeparator:"," envDefault:"payments:read"`
+	WriteScopes  []string `env:"WRITE_SCOPES" envSeparator:"," envDefault:"payments:write"`
+	DeleteScopes []string `env:"DELETE_SCOPES" envSeparator:"," envDefault:"payments:delete"`
+	// IdempotencyKeyTimeout is how long a request with an idempotency key may take before its key can be
+	// reserved by a retry
+	IdempotencyKeyTimeout time.Duration `env:"IDEMPOTENCY_KEY_TIMEOUT" envDefault:"1m"`
 }
 
 func main() {
@@ -39,12 +64,142 @@ func main() {
 		log.Fatalf("failed to run migrations: %s", err)
 	}
 
+	if len(os.Args) > 1 {
+		runCommand(db, conf, os.Args[1], os.Args[2:])
+		return
+	}
+
 	// wire dependencies
 	sqlxDB := sqlx.NewDb(db, conf.DBAddr)
 	paymentsService := postgres.NewPaymentRepository(sqlxDB)
+	approvalService := postgres.NewApprovalRepository(sqlxDB)
+	idempotencyService := postgres.NewIdempotencyRepository(sqlxDB, conf.IdempotencyKeyTimeout)
+	authenticators := api.Authenticators{"ApiKey": postgres.NewAPIKeyRepository(sqlxDB)}
+	if conf.JWKSFile != "" {
+		keys, err := auth.ReadKeySet(conf.JWKSFile)
+		if err != nil {
+			log.Fatalf("failed to read the JWKS file: %s", err)
+		}
+		authenticators["Bearer"] = auth.NewTokenAuthenticator(keys, conf.JWTIssuer, conf.JWTAudience, time.Now)
+	}
+
+	schemes, err := scheme.NewDefaultRegistry(time.Now)
+	if err != nil {
+		log.Fatalf("failed to load the payment scheme rules: %s", err)
+	}
 
 	// start server
-	server := api.NewServer(paymentsService)
+	policy := api.Policy{Read: conf.ReadScopes, Write: conf.WriteScopes, Delete: conf.DeleteScopes}
+	server := api.NewServer(paymentsService, approvalService, idempotencyService, schemes, authenticators, policy)
 	log.Printf("Running server on :%s\n", conf.Port)
 	server.Start(conf.Port)
 }
+
+// runCommand runs a management command instead of the server
+func runCommand(db *sql.DB, conf *config, command string, args []string) {
+	sqlxDB := sqlx.NewDb(db, conf.DBAddr)
+	apiKeys := postgres.NewAPIKeyRepository(sqlxDB)
+	approvals := postgres.NewApprovalRepository(sqlxDB)
+	payments := postgres.NewPaymentRepository(sqlxDB)
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	organisation := flags.String("organisation", "", "ID of the organisation")
+	switch command {
+	case "create-api-key":
+		name := flags.String("name", "", "name of the API key")
+		scopes := flags.String("scopes", acme.ScopeRead, "comma separated scopes granted to the API key")
+		flags.Parse(args)
+		apiKey, key, err := apiKeys.Create(parseID(*organisation, "organisation"), *name,
+			strings.Split(*scopes, ","))
+		if err != nil {
+			log.Fatalf("failed to create the API key: %s", err)
+		}
+		fmt.Printf("API key %s: %s\n", apiKey.ID, key)
+	case "revoke-api-key":
+		id := flags.String("id", "", "ID of the API key")
+		flags.Parse(args)
+		err := apiKeys.Revoke(parseID(*organisation, "organisation"), parseID(*id, "id"))
+		if err != nil {
+			log.Fatalf("failed to revoke the API key: %s", err)
+		}
+	case "set-approval-threshold":
+		currency := flags.String("currency", "", "currency of the payments the threshold applies to")
+		amount := flags.String("amount", "", "amount above which payments need four-eyes approval")
+		count := flags.Int("approvals", 2, "number of approvals the payments need")
+		flags.Parse(args)
+		threshold, err := acme.ParseDecimal(*amount)
+		if err != nil {
+			log.Fatalf("-amount must be a decimal number")
+		}
+		err = approvals.SetThreshold(parseID(*organisation, "organisation"),
+			acme.Threshold{Currency: *currency, Amount: threshold, Approvals: *count})
+		if err != nil {
+			log.Fatalf("failed to set the approval threshold: %s", err)
+		}
+	case "remove-approval-threshold":
+		currency := flags.String("currency", "", "currency of the threshold")
+		flags.Parse(args)
+		err := approvals.RemoveThreshold(parseID(*organisation, "organisation"), *currency)
+		if err != nil {
+			log.Fatalf("failed to remove the approval threshold: %s", err)
+		}
+	case "list-approval-thresholds":
+		flags.Parse(args)
+		thresholds, err := approvals.GetThresholds(parseID(*organisation, "organisation"))
+		if err != nil {
+			log.Fatalf("failed to list the approval thresholds: %s", err)
+		}
+		for _, threshold := range thresholds {
+			fmt.Printf("%s %s %d\n", threshold.Currency, threshold.Amount, threshold.Approvals)
+		}
+	case "bacs-file":
+		date := flags.String("date", "", "processing date of the payments, formatted as YYYY-MM-DD")
+		serviceUserNumber := flags.String("service-user-number", "", "BACS service user number of the organisation")
+		output := flags.String("output", "", "file to write to instead of standard output")
+		recordedBy := flags.String("recorded-by", "bacs-file", "who the payments in the file are submitted by")
+		flags.Parse(args)
+		processingDate, err := time.Parse("2006-01-02", *date)
+		if err != nil {
+			log.Fatalf("-date must be a date formatted as YYYY-MM-DD")
+		}
+		organisationID := parseID(*organisation, "organisation")
+		generator := bacs.NewGenerator(payments)
+		eligible, err := generator.Eligible(organisationID, processingDate)
+		if err != nil {
+			log.Fatalf("failed to list the BACS payments: %s", err)
+		}
+		header := bacs.NewHeader(*serviceUserNumber, processingDate, time.Now())
+		_, err = bacs.NewFile(header, eligible)
+		if err != nil {
+			log.Fatalf("failed to generate the BACS file: %s", err)
+		}
+		submitted, err := generator.Submit(organisationID, eligible, *recordedBy)
+		if err != nil {
+			log.Printf("failed to submit the BACS payments, %d of %d are submitted: %s", len(submitted),
+				len(eligible), err)
+		}
+		file, err := bacs.NewFile(header, submitted)
+		if err != nil {
+			log.Fatalf("failed to generate the BACS file: %s", err)
+		}
+		if *output == "" {
+			os.Stdout.Write(file.Marshal())
+			return
+		}
+		err = ioutil.WriteFile(*output, file.Marshal(), 0644)
+		if err != nil {
+			log.Fatalf("failed to write the BACS file: %s", err)
+		}
+	default:
+		log.Fatalf("unknown command %q, expected create-api-key, revoke-api-key, set-approval-threshold, "+
+			"remove-approval-threshold, list-approval-thresholds or bacs-file", command)
+	}
+}
+
+func parseID(value, name string) uuid.UUID {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		log.Fatalf("-%s must be a valid ID", name)
+	}
+	return id
+}
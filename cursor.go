@@ -0,0 +1,54 @@
+package acme
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Cursor is a position in the list of payments. Payments are listed in ID order and a cursor
+// selects the payments after, or before, the payment with the given ID.
+// The zero Cursor selects the first page.
+type Cursor struct {
+	ID     uuid.UUID
+	Before bool
+}
+
+// CursorAfter creates a cursor for the payments following id
+func CursorAfter(id uuid.UUID) *Cursor {
+	return &Cursor{ID: id}
+}
+
+// CursorBefore creates a cursor for the payments preceding id
+func CursorBefore(id uuid.UUID) *Cursor {
+	return &Cursor{ID: id, Before: true}
+}
+
+// String encodes the cursor as an opaque URL safe token
+func (c Cursor) String() string {
+	direction := "after"
+	if c.Before {
+		direction = "before"
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(direction + ":" + c.ID.String()))
+}
+
+// ParseCursor decodes a token created by Cursor.String
+func ParseCursor(token string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, errors.New("malformed cursor")
+	}
+
+	parts := strings.SplitN(string(data), ":", 2)
+	if len(parts) != 2 || (parts[0] != "after" && parts[0] != "before") {
+		return Cursor{}, errors.New("malformed cursor")
+	}
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return Cursor{}, errors.New("malformed cursor")
+	}
+	return Cursor{ID: id, Before: parts[0] == "before"}, nil
+}
@@ -0,0 +1,139 @@
+package acme
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Change is a difference between two versions of a payment. Path is a JSON pointer into the payment
+// and Op is one of the JSON Patch operations "add", "remove" or "replace". Old is not set on an add
+// and New is not set on a remove.
+type Change struct {
+	Op   string          `json:"op"`
+	Path string          `json:"path"`
+	Old  json.RawMessage `json:"old,omitempty"`
+	New  json.RawMessage `json:"new,omitempty"`
+}
+
+// Changes lists the changes made between two versions of a payment
+type Changes struct {
+	From int      `json:"from"`
+	To   int      `json:"to"`
+	Data []Change `json:"data"`
+}
+
+// diffable holds the fields of a payment that may change between versions
+type diffable struct {
+	OrganisationID string     `json:"organisation_id"`
+	Attributes     Attributes `json:"attributes"`
+	Status         Status     `json:"status"`
+	Deleted        bool       `json:"deleted"`
+}
+
+// Diff lists the changes that turn the from version of a payment into the to version.
+// The ID, version number and when and by whom the versions were recorded are not compared.
+func Diff(from, to Payment) ([]Change, error) {
+	a, err := toTree(diffable{from.OrganisationID.String(), from.Attributes, from.Status, from.Deleted})
+	if err != nil {
+		return nil, err
+	}
+	b, err := toTree(diffable{to.OrganisationID.String(), to.Attributes, to.Status, to.Deleted})
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []Change{}
+	diffValues("", a, b, &changes)
+	return changes, nil
+}
+
+func diffValues(path string, a, b interface{}, changes *[]Change) {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		if b, ok := b.(map[string]interface{}); ok {
+			diffObjects(path, a, b, changes)
+			return
+		}
+	case []interface{}:
+		if b, ok := b.([]interface{}); ok {
+			diffArrays(path, a, b, changes)
+			return
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, Change{Op: "replace", Path: path, Old: toJSON(a), New: toJSON(b)})
+	}
+}
+
+func diffObjects(path string, a, b map[string]interface{}, changes *[]Change) {
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		child := path + "/" + EscapePointer(k)
+		oldValue, inA := a[k]
+		newValue, inB := b[k]
+		switch {
+		case !inA:
+			*changes = append(*changes, Change{Op: "add", Path: child, New: toJSON(newValue)})
+		case !inB:
+			*changes = append(*changes, Change{Op: "remove", Path: child, Old: toJSON(oldValue)})
+		default:
+			diffValues(child, oldValue, newValue, changes)
+		}
+	}
+}
+
+func diffArrays(path string, a, b []interface{}, changes *[]Change) {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		diffValues(path+"/"+strconv.Itoa(i), a[i], b[i], changes)
+	}
+	for i := len(a); i < len(b); i++ {
+		*changes = append(*changes, Change{Op: "add", Path: path + "/" + strconv.Itoa(i), New: toJSON(b[i])})
+	}
+	// remove from the end so that each path is valid when the changes are applied in order
+	for i := len(a) - 1; i >= len(b); i-- {
+		*changes = append(*changes, Change{Op: "remove", Path: path + "/" + strconv.Itoa(i), Old: toJSON(a[i])})
+	}
+}
+
+// toTree converts v into the generic JSON representation of maps, slices and scalars
+func toTree(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var tree interface{}
+	err = decoder.Decode(&tree)
+	return tree, err
+}
+
+func toJSON(v interface{}) json.RawMessage {
+	// values come from toTree so they always marshal
+	data, _ := json.Marshal(v)
+	return data
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// EscapePointer escapes a reference token of a JSON pointer, so that a ~ or a / in the name of a field is
+// not read as the start of an escape or of the next token
+func EscapePointer(token string) string {
+	return pointerEscaper.Replace(token)
+}
@@ -0,0 +1,84 @@
+package acme_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/steinfletcher/payments"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	from := acme.Payment{
+		ID:             uuid.New(),
+		OrganisationID: uuid.MustParse("743d5b63-8e6f-432e-a8fa-c5d8d2ee5fcb"),
+		Attributes: acme.Attributes{
+			Amount:       "100.21",
+			Reference:    "Payment for Em's piano lessons",
+			SponsorParty: &acme.Party{BankID: "123123"},
+			ChargesInformation: &acme.ChargesInformation{
+				SenderCharges: []acme.Charge{{Amount: "5.00", Currency: "GBP"}, {Amount: "10.00", Currency: "USD"}},
+			},
+		},
+	}
+	to := acme.Payment{
+		ID:             from.ID,
+		Version:        1,
+		OrganisationID: uuid.MustParse("efa9c7a2-5bc7-461b-a977-20853c7221cd"),
+		Attributes: acme.Attributes{
+			Amount:       "200.00",
+			Currency:     "GBP",
+			SponsorParty: &acme.Party{BankID: "123123"},
+			ChargesInformation: &acme.ChargesInformation{
+				SenderCharges: []acme.Charge{{Amount: "6.00", Currency: "GBP"}},
+			},
+		},
+		Deleted: true,
+	}
+
+	changes, err := acme.Diff(from, to)
+
+	assert.NoError(t, err)
+	data, _ := json.Marshal(changes)
+	assert.JSONEq(t, `[
+		{"op": "replace", "path": "/attributes/amount", "old": "100.21", "new": "200.00"},
+		{"op": "replace", "path": "/attributes/charges_information/sender_charges/0/amount", "old": "5.00", "new": "6.00"},
+		{"op": "remove", "path": "/attributes/charges_information/sender_charges/1", "old": {"amount": "10.00", "currency": "USD"}},
+		{"op": "add", "path": "/attributes/currency", "new": "GBP"},
+		{"op": "remove", "path": "/attributes/reference", "old": "Payment for Em's piano lessons"},
+		{"op": "replace", "path": "/deleted", "old": false, "new": true},
+		{"op": "replace", "path": "/organisation_id", "old": "743d5b63-8e6f-432e-a8fa-c5d8d2ee5fcb", "new": "efa9c7a2-5bc7-461b-a977-20853c7221cd"}
+	]`, string(data))
+}
+
+func TestDiff_NoChanges(t *testing.T) {
+	payment := acme.Payment{ID: uuid.New(), Attributes: acme.Attributes{Amount: "100.21"}}
+	next := payment
+	next.Version = 1
+
+	changes, err := acme.Diff(payment, next)
+
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestDiff_EscapesPointers(t *testing.T) {
+	from := acme.Payment{Attributes: acme.Attributes{Extra: map[string]json.RawMessage{"a/b~c": json.RawMessage(`1`)}}}
+	to := acme.Payment{Attributes: acme.Attributes{Extra: map[string]json.RawMessage{"a/b~c": json.RawMessage(`2`)}}}
+
+	changes, err := acme.Diff(from, to)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []acme.Change{{
+		Op:   "replace",
+		Path: "/attributes/a~1b~0c",
+		Old:  json.RawMessage(`1`),
+		New:  json.RawMessage(`2`),
+	}}, changes)
+}
+
+func TestEscapePointer(t *testing.T) {
+	assert.Equal(t, "a~1b~0c", acme.EscapePointer("a/b~c"))
+	assert.Equal(t, "~01", acme.EscapePointer("~1"))
+}
@@ -3,34 +3,210 @@ package acme
 var InvalidID = Error{
 	Code:   "INVALID_PAYMENT_ID",
 	Detail: "The provided ID is not valid",
+	Kind:   KindInvalid,
 }
 
 var ServerError = Error{
 	Code:   "SERVER_ERROR",
 	Detail: "Sorry, something went wrong",
+	Kind:   KindInternal,
 }
 
 var PaymentNotFound = Error{
 	Code:   "PAYMENT_NOT_FOUND",
 	Detail: "We could not find a payment with the given ID",
+	Kind:   KindNotFound,
+}
+
+var InvalidVersion = Error{
+	Code:   "INVALID_VERSION",
+	Detail: "The provided version is not valid",
+	Kind:   KindInvalid,
+}
+
+var PaymentVersionNotFound = Error{
+	Code:   "PAYMENT_VERSION_NOT_FOUND",
+	Detail: "We could not find the given version of the payment",
+	Kind:   KindNotFound,
+}
+
+var VersionConflict = Error{
+	Code:   "VERSION_CONFLICT",
+	Detail: "The payment has been changed since the given version",
+	Kind:   KindConflict,
+}
+
+var PreconditionFailed = Error{
+	Code:   "PRECONDITION_FAILED",
+	Detail: "The If-Match header does not match the current version of the payment",
+	Kind:   KindFailedPrecondition,
+}
+
+var InvalidIdempotencyKey = Error{
+	Code:   "INVALID_IDEMPOTENCY_KEY",
+	Detail: "The Idempotency-Key header must be at most 255 characters",
+	Kind:   KindInvalid,
+}
+
+var IdempotencyKeyReused = Error{
+	Code:   "IDEMPOTENCY_KEY_REUSED",
+	Detail: "The idempotency key has already been used for a different request",
+	Kind:   KindUnprocessable,
+}
+
+var IdempotencyKeyInProgress = Error{
+	Code:   "IDEMPOTENCY_KEY_IN_PROGRESS",
+	Detail: "A request with the idempotency key is still in progress",
+	Kind:   KindConflict,
+}
+
+var UnsupportedMediaType = Error{
+	Code:   "UNSUPPORTED_MEDIA_TYPE",
+	Detail: "The content type of the request is not supported",
+	Kind:   KindUnsupportedMediaType,
+}
+
+var InvalidPatch = Error{
+	Code:   "INVALID_PATCH",
+	Detail: "The patch could not be applied to the payment",
+	Kind:   KindUnprocessable,
+}
+
+var InvalidTransition = Error{
+	Code:   "INVALID_TRANSITION",
+	Detail: "The payment cannot make this transition from its current status",
+	Kind:   KindConflict,
+}
+
+var PaymentNotPending = Error{
+	Code:   "PAYMENT_NOT_PENDING",
+	Detail: "Only pending payments can be changed",
+	Kind:   KindConflict,
+}
+
+var SelfApproval = Error{
+	Code:   "SELF_APPROVAL",
+	Detail: "The payment must be approved by a client other than the ones that recorded it",
+	Kind:   KindForbidden,
+}
+
+var DuplicateApproval = Error{
+	Code:   "DUPLICATE_APPROVAL",
+	Detail: "The client has already approved this version of the payment",
+	Kind:   KindConflict,
+}
+
+var ApprovalRequired = Error{
+	Code:   "APPROVAL_REQUIRED",
+	Detail: "The payment has not been approved by enough approvers",
+	Kind:   KindConflict,
+}
+
+var ThresholdNotFound = Error{
+	Code:   "THRESHOLD_NOT_FOUND",
+	Detail: "The organisation has no approval threshold for the currency",
+	Kind:   KindNotFound,
+}
+
+var InvalidThreshold = Error{
+	Code:   "INVALID_THRESHOLD",
+	Detail: "The threshold must have a currency, an amount that is not negative and at least one approval",
+	Kind:   KindInvalid,
+}
+
+var PaymentNotExportable = Error{
+	Code:   "PAYMENT_NOT_EXPORTABLE",
+	Detail: "The payment cannot be represented in the requested format",
+	Kind:   KindUnprocessable,
+}
+
+var PaymentNotApproved = Error{
+	Code:   "PAYMENT_NOT_APPROVED",
+	Detail: "Only approved payments can be exported",
+	Kind:   KindConflict,
+}
+
+var NothingToExport = Error{
+	Code:   "NOTHING_TO_EXPORT",
+	Detail: "No payments match the filters of the export",
+	Kind:   KindNotFound,
+}
+
+var InvalidMessage = Error{
+	Code:   "INVALID_MESSAGE",
+	Detail: "The message could not be parsed",
+	Kind:   KindInvalid,
 }
 
 var InvalidField = Error{
 	Code:   "INVALID_FIELD",
 	Detail: "The request body is not valid",
+	Kind:   KindInvalid,
+}
+
+var InvalidQueryParameter = Error{
+	Code:   "INVALID_QUERY_PARAMETER",
+	Detail: "A query parameter is not valid",
+	Kind:   KindInvalid,
 }
 
 var InvalidRequestBody = Error{
 	Code:   "INVALID_REQUEST_BODY",
 	Detail: "The request body is not valid",
+	Kind:   KindInvalid,
 }
 
+var Unauthorized = Error{
+	Code:   "UNAUTHORIZED",
+	Detail: "The request is not authenticated",
+	Kind:   KindUnauthorized,
+}
+
+var Forbidden = Error{
+	Code:   "FORBIDDEN",
+	Detail: "The client is not allowed to perform this operation",
+	Kind:   KindForbidden,
+}
+
+var APIKeyNotFound = Error{
+	Code:   "API_KEY_NOT_FOUND",
+	Detail: "We could not find an API key with the given ID",
+	Kind:   KindNotFound,
+}
+
+var OrganisationMismatch = Error{
+	Code:   "ORGANISATION_MISMATCH",
+	Detail: "The payment does not belong to the organisation of the request",
+	Kind:   KindInvalid,
+}
+
+// Error is an application error. Meta holds more information about the error,
+// e.g. the FieldErrors of an InvalidField error.
 type Error struct {
 	Code   string      `json:"code"`
 	Detail string      `json:"detail"`
 	Meta   interface{} `json:"meta,omitempty"`
+	Kind   Kind        `json:"-"`
+}
+
+// FieldError describes why a field of a request is not valid. Pointer is a JSON pointer to the field
+// in the request body.
+type FieldError struct {
+	Pointer string `json:"pointer"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 func (r Error) Error() string {
 	return r.Code
 }
+
+// HTTPStatus is the HTTP status code of the error
+func (r Error) HTTPStatus() int {
+	return r.Kind.HTTPStatus()
+}
+
+// GRPCCode is the gRPC status code of the error
+func (r Error) GRPCCode() uint32 {
+	return r.Kind.GRPCCode()
+}
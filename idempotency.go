@@ -0,0 +1,28 @@
+package acme
+
+import (
+	"github.com/google/uuid"
+)
+
+//go:generate pegomock generate --use-experimental-model-gen --output-dir mocks IdempotencyService
+
+// IdempotencyService remembers the response to a request made with an idempotency key, so that a client
+// can safely retry the request. Keys are scoped to an organisation.
+type IdempotencyService interface {
+	// Reserve claims the key for a request with the given fingerprint. It returns the stored response
+	// if the request has already completed, IdempotencyKeyReused if the key was used for a different
+	// request and IdempotencyKeyInProgress if the original request has not completed yet. A key that has
+	// been in progress for too long is reserved again, as its request has failed without releasing it.
+	Reserve(organisationID uuid.UUID, key, fingerprint string) (*IdempotentResponse, error)
+	// Complete stores the response to the request that reserved the key
+	Complete(organisationID uuid.UUID, key string, response IdempotentResponse) error
+	// Release frees the key of a request that failed, so that it can be retried
+	Release(organisationID uuid.UUID, key string) error
+}
+
+// IdempotentResponse is the response replayed to a retried request
+type IdempotentResponse struct {
+	StatusCode int
+	Header     map[string]string
+	Body       []byte
+}
@@ -6,12 +6,17 @@ import (
 	"net/http"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	"github.com/steinfletcher/apitest"
+	jsonpath "github.com/steinfletcher/apitest-jsonpath"
+	"github.com/steinfletcher/payments"
 	"github.com/steinfletcher/payments/api"
 	"github.com/steinfletcher/payments/postgres"
+	"github.com/steinfletcher/payments/scheme"
 	"github.com/steinfletcher/payments/test"
 )
 
@@ -22,8 +27,9 @@ func TestGetAllPayments(t *testing.T) {
 
 	apiTest().
 		Get("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
 		Expect(t).
-		Body(fmt.Sprintf(readFile("testdata/expected_payments_response.json"), paymentID)).
+		Body(fmt.Sprintf(readFile("testdata/expected_payments_response.json"), paymentID, recordedAt(t, paymentID), apiKeyID)).
 		Status(http.StatusOK).
 		End()
 }
@@ -35,8 +41,9 @@ func TestGetPayment(t *testing.T) {
 
 	apiTest().
 		Get(fmt.Sprintf("/v1/payment/%s", paymentID)).
+		Header("Authorization", "ApiKey "+apiKey).
 		Expect(t).
-		Body(fmt.Sprintf(readFile("testdata/expected_payment_response.json"), paymentID)).
+		Body(fmt.Sprintf(readFile("testdata/expected_payment_response.json"), paymentID, recordedAt(t, paymentID), apiKeyID)).
 		Status(http.StatusOK).
 		End()
 }
@@ -46,6 +53,7 @@ func TestCreatePayment(t *testing.T) {
 
 	apiTest().
 		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
 		JSON(readFile("testdata/create_payment.json")).
 		Expect(t).
 		Status(http.StatusCreated).
@@ -53,6 +61,30 @@ func TestCreatePayment(t *testing.T) {
 		End()
 }
 
+func TestCreatePayment_RetriedWithIdempotencyKey(t *testing.T) {
+	setup(t)
+
+	first := apiTest().
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Idempotency-Key", "create-payment-1").
+		JSON(readFile("testdata/create_payment.json")).
+		Expect(t).
+		Status(http.StatusCreated).
+		End()
+
+	apiTest().
+		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
+		Header("Idempotency-Key", "create-payment-1").
+		JSON(readFile("testdata/create_payment.json")).
+		Expect(t).
+		Status(http.StatusCreated).
+		Header("Location", first.Response.Header.Get("Location")).
+		Header("Idempotent-Replayed", "true").
+		End()
+}
+
 func TestDeletePayment(t *testing.T) {
 	setup(t)
 
@@ -60,21 +92,125 @@ func TestDeletePayment(t *testing.T) {
 
 	apiTest().
 		Delete(fmt.Sprintf("/v1/payment/%s", paymentID)).
+		Header("Authorization", "ApiKey "+apiKey).
 		Expect(t).
 		Status(http.StatusOK).
 		End()
 
 	apiTest().
 		Get(fmt.Sprintf("/v1/payment/%s", paymentID)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusNotFound).
+		Header("Content-Type", "application/problem+json").
+		Body(fmt.Sprintf(`{
+			"type": "/problems/payment-not-found",
+			"title": "Payment not found",
+			"status": 404,
+			"detail": "We could not find a payment with the given ID",
+			"instance": "/v1/payment/%s",
+			"code": "PAYMENT_NOT_FOUND"
+		}`, paymentID)).
+		End()
+}
+
+func TestPaymentLifecycle(t *testing.T) {
+	setup(t)
+
+	// the payment cannot be approved by the client that created it
+	_, approverKey, err := postgres.NewAPIKeyRepository(test.DBConnect()).Create(organisationID, "approver",
+		[]string{acme.ScopeRead, acme.ScopeWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paymentID := withPayment(t)
+
+	for _, step := range []struct{ action, key string }{{"approve", approverKey}, {"submit", apiKey}, {"settle", apiKey}} {
+		apiTest().
+			Post(fmt.Sprintf("/v1/payment/%s/%s", paymentID, step.action)).
+			Header("Authorization", "ApiKey "+step.key).
+			Expect(t).
+			Status(http.StatusOK).
+			End()
+	}
+
+	apiTest().
+		Post(fmt.Sprintf("/v1/payment/%s/cancel", paymentID)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusConflict).
+		Assert(jsonpath.Equal("$.code", acme.InvalidTransition.Code)).
+		End()
+
+	apiTest().
+		Get(fmt.Sprintf("/v1/payment/%s/versions", paymentID)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.data", 4)).
+		Assert(jsonpath.Equal("$.data[3].status", "settled")).
+		Assert(jsonpath.Equal("$.data[3].recorded_by", apiKeyID.String())).
+		End()
+}
+
+func TestFourEyesApproval(t *testing.T) {
+	setup(t)
+	db := test.DBConnect()
+	err := postgres.NewApprovalRepository(db).SetThreshold(organisationID, acme.Threshold{
+		Currency:  "GBP",
+		Amount:    acme.NewDecimal(100, 0),
+		Approvals: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, approverKey, err := postgres.NewAPIKeyRepository(db).Create(organisationID, "approver",
+		[]string{acme.ScopeRead, acme.ScopeWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paymentID := withPayment(t)
+
+	apiTest().
+		Post(fmt.Sprintf("/v1/payment/%s/approve", paymentID)).
+		Header("Authorization", "ApiKey "+apiKey).
 		Expect(t).
-		Status(http.StatusBadRequest).
-		Body(`{"code":"PAYMENT_NOT_FOUND", "detail":"We could not find a payment with the given ID"}`).
+		Status(http.StatusForbidden).
+		Assert(jsonpath.Equal("$.code", acme.SelfApproval.Code)).
+		End()
+
+	apiTest().
+		Post(fmt.Sprintf("/v1/payment/%s/approve", paymentID)).
+		Header("Authorization", "ApiKey "+approverKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.status", "approved")).
+		End()
+
+	apiTest().
+		Get(fmt.Sprintf("/v1/payment/%s/approvals", paymentID)).
+		Header("Authorization", "ApiKey "+apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.data", 1)).
 		End()
 }
 
+// organisationID is the organisation of the payment in testdata/create_payment.json
+var organisationID = uuid.MustParse("743d5b63-8e6f-432e-a8fa-c5d8d2ee5fcb")
+
+// apiKey authenticates requests on behalf of organisationID. It is created by setup. apiKeyID is the
+// subject the payments stored with it are recorded by.
+var (
+	apiKey   string
+	apiKeyID uuid.UUID
+)
+
 func withPayment(t *testing.T) string {
 	result := apiTest().
 		Post("/v1/payment").
+		Header("Authorization", "ApiKey "+apiKey).
 		JSON(readFile("testdata/create_payment.json")).
 		Expect(t).
 		Status(http.StatusCreated).
@@ -83,19 +219,42 @@ func withPayment(t *testing.T) string {
 	return result.Response.Header.Get("Location")
 }
 
+// recordedAt is the time the database recorded the payment at, formatted as it is in a response
+func recordedAt(t *testing.T, paymentID string) string {
+	payment, err := postgres.NewPaymentRepository(test.DBConnect()).Get(organisationID, uuid.MustParse(paymentID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payment.RecordedAt.Format(time.RFC3339Nano)
+}
+
 func setup(t *testing.T) {
 	if os.Getenv("DB_ADDR") == "" {
 		t.Skip()
 	}
 	// teardown
-	test.DBSetup(func(tx *sqlx.Tx) {})
+	db := test.DBSetup(func(tx *sqlx.Tx) {})
+
+	key, secret, err := postgres.NewAPIKeyRepository(db).Create(organisationID, "integration",
+		[]string{acme.ScopeRead, acme.ScopeWrite, acme.ScopeDelete})
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiKey, apiKeyID = secret, key.ID
 }
 
 func apiTest() *apitest.APITest {
-	service := postgres.NewPaymentRepository(test.DBConnect())
+	db := test.DBConnect()
+	schemes, err := scheme.NewDefaultRegistry(time.Now)
+	if err != nil {
+		panic(err)
+	}
+	server := api.NewServer(postgres.NewPaymentRepository(db), postgres.NewApprovalRepository(db),
+		postgres.NewIdempotencyRepository(db, time.Minute), schemes,
+		api.Authenticators{"ApiKey": postgres.NewAPIKeyRepository(db)}, api.DefaultPolicy)
 	return apitest.New().
 		Recorder(test.Recorder).
-		Handler(api.NewServer(service).Router).
+		Handler(server.Router).
 		Report(apitest.SequenceDiagram())
 }
 
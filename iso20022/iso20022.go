@@ -0,0 +1,252 @@
+// Package iso20022 converts payments to and from ISO 20022 XML messages. The message components shared by
+// the messages, such as parties, accounts and agents, are defined here and each message in its own file.
+package iso20022
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/steinfletcher/payments"
+)
+
+const (
+	dateFormat     = "2006-01-02"
+	dateTimeFormat = "2006-01-02T15:04:05"
+	// notProvided is the conventional end to end identification of a payment without one, and the value
+	// of the attributes of an imported payment that the message has no element for
+	notProvided = "NOTPROVIDED"
+	// addressLineLength and addressLines are the size and the maximum number of address lines
+	addressLineLength = 70
+	addressLines      = 7
+)
+
+// Header identifies a message and the party that sends it
+type Header struct {
+	MessageID string
+	Created   time.Time
+	// InitiatingParty is the name of the party that initiates the payments
+	InitiatingParty string
+}
+
+// NewHeader creates a header with a new message ID
+func NewHeader(created time.Time, initiatingParty string) Header {
+	return Header{
+		MessageID:       strings.Replace(uuid.New().String(), "-", "", -1),
+		Created:         created,
+		InitiatingParty: initiatingParty,
+	}
+}
+
+type PartyIdentification struct {
+	Name          string         `xml:"Nm,omitempty"`
+	PostalAddress *PostalAddress `xml:"PstlAdr"`
+}
+
+// PostalAddress is an address as lines or as structured fields. Addresses are exported as lines.
+type PostalAddress struct {
+	StreetName     string   `xml:"StrtNm,omitempty"`
+	BuildingNumber string   `xml:"BldgNb,omitempty"`
+	PostCode       string   `xml:"PstCd,omitempty"`
+	TownName       string   `xml:"TwnNm,omitempty"`
+	Country        string   `xml:"Ctry,omitempty"`
+	AddressLines   []string `xml:"AdrLine"`
+}
+
+type CashAccount struct {
+	ID   AccountIdentification `xml:"Id"`
+	Name string                `xml:"Nm,omitempty"`
+}
+
+// AccountIdentification is either an IBAN or another identification of an account
+type AccountIdentification struct {
+	IBAN  string                        `xml:"IBAN,omitempty"`
+	Other *GenericAccountIdentification `xml:"Othr"`
+}
+
+type GenericAccountIdentification struct {
+	ID         string             `xml:"Id"`
+	SchemeName *CodeOrProprietary `xml:"SchmeNm"`
+}
+
+type Agent struct {
+	FinancialInstitution FinancialInstitutionIdentification `xml:"FinInstnId"`
+}
+
+// FinancialInstitutionIdentification identifies an agent by its BIC or by its member ID in a clearing
+// system, such as a UK sort code
+type FinancialInstitutionIdentification struct {
+	BIC                  string                              `xml:"BICFI,omitempty"`
+	ClearingSystemMember *ClearingSystemMemberIdentification `xml:"ClrSysMmbId"`
+}
+
+type ClearingSystemMemberIdentification struct {
+	ClearingSystem CodeOrProprietary `xml:"ClrSysId"`
+	MemberID       string            `xml:"MmbId"`
+}
+
+// CodeOrProprietary is an external code list value or a proprietary value, e.g. of a clearing system
+type CodeOrProprietary struct {
+	Code        string `xml:"Cd,omitempty"`
+	Proprietary string `xml:"Prtry,omitempty"`
+}
+
+// Value is the code, or the proprietary value if there is no code
+func (c *CodeOrProprietary) Value() string {
+	if c == nil {
+		return ""
+	}
+	if c.Code != "" {
+		return c.Code
+	}
+	return c.Proprietary
+}
+
+type Amount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type RemittanceInformation struct {
+	Unstructured string                            `xml:"Ustrd,omitempty"`
+	Structured   []StructuredRemittanceInformation `xml:"Strd"`
+}
+
+type StructuredRemittanceInformation struct {
+	CreditorReference *CreditorReferenceInformation `xml:"CdtrRefInf"`
+}
+
+type CreditorReferenceInformation struct {
+	Reference string `xml:"Ref"`
+}
+
+// marshal renders a message as an XML document
+func marshal(document interface{}) ([]byte, error) {
+	data, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// mapping collects the first error found while mapping a payment to a message, so that the mapping
+// reads as a sequence of fields
+type mapping struct {
+	payment uuid.UUID
+	err     error
+}
+
+func (m *mapping) fail(format string, args ...interface{}) {
+	if m.err != nil {
+		return
+	}
+	m.err = fmt.Errorf(format, args...)
+	if m.payment != uuid.Nil {
+		m.err = fmt.Errorf("payment %s: %s", m.payment, m.err)
+	}
+}
+
+// text checks that a value fits the maximum length of its element
+func (m *mapping) text(field, value string, length int) string {
+	if utf8.RuneCountInString(value) > length {
+		m.fail("%s is longer than %d characters", field, length)
+	}
+	return value
+}
+
+func (m *mapping) date(field, value string) string {
+	if _, err := time.Parse(dateFormat, value); err != nil {
+		m.fail("%s must be a date formatted as YYYY-MM-DD", field)
+	}
+	return value
+}
+
+func (m *mapping) party(field string, p *acme.Party) PartyIdentification {
+	party := PartyIdentification{Name: m.text(field+".name", p.Name, 140)}
+	if lines := addressLinesOf(p.Address); len(lines) > 0 {
+		if len(lines) > addressLines {
+			m.fail("%s.address does not fit %d address lines", field, addressLines)
+		}
+		for _, line := range lines {
+			m.text(field+".address", line, addressLineLength)
+		}
+		party.PostalAddress = &PostalAddress{AddressLines: lines}
+	}
+	return party
+}
+
+func (m *mapping) account(field string, p *acme.Party) CashAccount {
+	account := CashAccount{Name: m.text(field+".account_name", p.AccountName, 70)}
+	number := m.text(field+".account_number", p.AccountNumber, 34)
+	switch {
+	case number == "":
+		m.fail("%s.account_number is required", field)
+	case p.AccountNumberCode == "IBAN":
+		account.ID.IBAN = number
+	default:
+		account.ID.Other = &GenericAccountIdentification{ID: number}
+		if len(p.AccountNumberCode) > 4 {
+			account.ID.Other.SchemeName = &CodeOrProprietary{
+				Proprietary: m.text(field+".account_number_code", p.AccountNumberCode, 35),
+			}
+		} else if p.AccountNumberCode != "" {
+			account.ID.Other.SchemeName = &CodeOrProprietary{Code: p.AccountNumberCode}
+		}
+	}
+	return account
+}
+
+// agent identifies the bank of a party by its BIC when bank_id_code is SWBIC, and otherwise by its member
+// ID in the clearing system named by bank_id_code
+func (m *mapping) agent(field string, p *acme.Party) Agent {
+	var agent Agent
+	switch {
+	case p.BankID == "":
+	case p.BankIDCode == "SWBIC":
+		agent.FinancialInstitution.BIC = p.BankID
+	default:
+		member := &ClearingSystemMemberIdentification{MemberID: m.text(field+".bank_id", p.BankID, 35)}
+		if len(p.BankIDCode) <= 5 {
+			member.ClearingSystem.Code = p.BankIDCode
+		} else {
+			member.ClearingSystem.Proprietary = m.text(field+".bank_id_code", p.BankIDCode, 35)
+		}
+		agent.FinancialInstitution.ClearingSystemMember = member
+	}
+	return agent
+}
+
+// addressLinesOf splits an address into lines at spaces, so that each line fits an address line element
+func addressLinesOf(address string) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(address) {
+		switch {
+		case line == "":
+			line = word
+		case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= addressLineLength:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// chargeBearers are the charge bearer codes of ISO 20022
+var chargeBearers = map[string]bool{"DEBT": true, "CRED": true, "SHAR": true, "SLEV": true}
+
+// chargeBearer is the charge bearer of the payment, if it is an ISO 20022 charge bearer code
+func chargeBearer(a acme.Attributes) string {
+	if a.ChargesInformation != nil && chargeBearers[a.ChargesInformation.BearerCode] {
+		return a.ChargesInformation.BearerCode
+	}
+	return ""
+}
@@ -0,0 +1,269 @@
+package iso20022
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/steinfletcher/payments"
+)
+
+// pacs008Namespace is the namespace of every version of pacs.008, followed by the version number
+const pacs008Namespace = "urn:iso:std:iso:20022:tech:xsd:pacs.008.001."
+
+// Pacs008 is a pacs.008 FI to FI customer credit transfer. Versions that identify agents by BICFI, such
+// as pacs.008.001.08, can be parsed.
+type Pacs008 struct {
+	XMLName  xml.Name
+	Transfer FIToFICustomerCreditTransfer `xml:"FIToFICstmrCdtTrf"`
+}
+
+type FIToFICustomerCreditTransfer struct {
+	GroupHeader  Pacs008GroupHeader   `xml:"GrpHdr"`
+	Transactions []Pacs008Transaction `xml:"CdtTrfTxInf"`
+}
+
+type Pacs008GroupHeader struct {
+	MessageID            string                `xml:"MsgId"`
+	Created              string                `xml:"CreDtTm"`
+	NumberOfTransactions string                `xml:"NbOfTxs"`
+	SettlementDate       string                `xml:"IntrBkSttlmDt"`
+	Settlement           SettlementInstruction `xml:"SttlmInf"`
+}
+
+type SettlementInstruction struct {
+	Method         string             `xml:"SttlmMtd"`
+	ClearingSystem *CodeOrProprietary `xml:"ClrSys"`
+}
+
+// Pacs008Transaction is a credit transfer between the debtor and the creditor, settled between their
+// agents
+type Pacs008Transaction struct {
+	PaymentID          TransactionIdentification `xml:"PmtId"`
+	PaymentType        *PaymentTypeInformation   `xml:"PmtTpInf"`
+	SettlementAmount   Amount                    `xml:"IntrBkSttlmAmt"`
+	SettlementDate     string                    `xml:"IntrBkSttlmDt"`
+	InstructedAmount   *Amount                   `xml:"InstdAmt"`
+	ExchangeRate       string                    `xml:"XchgRate"`
+	ChargeBearer       string                    `xml:"ChrgBr"`
+	Charges            []Charges                 `xml:"ChrgsInf"`
+	InstructingAgent   *Agent                    `xml:"InstgAgt"`
+	Debtor             PartyIdentification       `xml:"Dbtr"`
+	DebtorAccount      *CashAccount              `xml:"DbtrAcct"`
+	DebtorAgent        Agent                     `xml:"DbtrAgt"`
+	DebtorAgentAccount *CashAccount              `xml:"DbtrAgtAcct"`
+	CreditorAgent      Agent                     `xml:"CdtrAgt"`
+	Creditor           PartyIdentification       `xml:"Cdtr"`
+	CreditorAccount    *CashAccount              `xml:"CdtrAcct"`
+	Purpose            *CodeOrProprietary        `xml:"Purp"`
+	Remittance         *RemittanceInformation    `xml:"RmtInf"`
+}
+
+type TransactionIdentification struct {
+	InstructionID string `xml:"InstrId"`
+	EndToEndID    string `xml:"EndToEndId"`
+	TransactionID string `xml:"TxId"`
+}
+
+type PaymentTypeInformation struct {
+	ServiceLevel    []CodeOrProprietary `xml:"SvcLvl"`
+	LocalInstrument *CodeOrProprietary  `xml:"LclInstrm"`
+	CategoryPurpose *CodeOrProprietary  `xml:"CtgyPurp"`
+}
+
+// Charges is an amount charged by an agent for the transfer
+type Charges struct {
+	Amount Amount `xml:"Amt"`
+	Agent  Agent  `xml:"Agt"`
+}
+
+// ParsePacs008 parses a pacs.008 message
+func ParsePacs008(data []byte) (Pacs008, error) {
+	var message Pacs008
+	err := xml.Unmarshal(data, &message)
+	if err != nil {
+		return Pacs008{}, fmt.Errorf("the message is not valid XML: %s", err)
+	}
+	if message.XMLName.Local != "Document" || !strings.HasPrefix(message.XMLName.Space, pacs008Namespace) {
+		return Pacs008{}, fmt.Errorf("the message is not a pacs.008 document")
+	}
+	if len(message.Transfer.Transactions) == 0 {
+		return Pacs008{}, fmt.Errorf("the message has no credit transfer transactions")
+	}
+	return message, nil
+}
+
+// Attributes maps the transaction to the attributes of a payment. The debtor, the creditor and the
+// instructing agent are the debtor, beneficiary and sponsor parties, and the debtor agent is the sponsor
+// when there is no instructing agent. A transaction without a settlement date is settled on the day the
+// message was created. Attributes that the message has no element for are NOTPROVIDED, so that a
+// payment mapped from a transaction with only the mandatory elements is valid.
+func (t Pacs008Transaction) Attributes(header Pacs008GroupHeader) acme.Attributes {
+	settlementDate := t.SettlementDate
+	if settlementDate == "" {
+		settlementDate = header.SettlementDate
+	}
+	if settlementDate == "" && len(header.Created) >= len(dateFormat) {
+		settlementDate = header.Created[:len(dateFormat)]
+	}
+
+	a := acme.Attributes{
+		Amount:               amountOf(t.SettlementAmount),
+		Currency:             t.SettlementAmount.Currency,
+		EndToEndReference:    orNotProvided(t.PaymentID.EndToEndID),
+		PaymentID:            orNotProvided(t.PaymentID.TransactionID),
+		PaymentType:          "Credit",
+		PaymentPurpose:       orNotProvided(t.Purpose.Value()),
+		PaymentScheme:        orNotProvided(paymentScheme(header, t.PaymentType)),
+		ProcessingDate:       settlementDate,
+		Reference:            notProvided,
+		NumericReference:     notProvided,
+		SchemePaymentType:    notProvided,
+		SchemePaymentSubType: notProvided,
+		DebtorParty:          partyOf(t.Debtor, t.DebtorAccount, t.DebtorAgent),
+		BeneficiaryParty:     partyOf(t.Creditor, t.CreditorAccount, t.CreditorAgent),
+		ChargesInformation: &acme.ChargesInformation{
+			BearerCode:              orNotProvided(t.ChargeBearer),
+			SenderCharges:           []acme.Charge{},
+			ReceiverChargesAmount:   zeroAmount(t.SettlementAmount.Currency),
+			ReceiverChargesCurrency: t.SettlementAmount.Currency,
+		},
+		FX: fxOf(t),
+	}
+	if t.PaymentType != nil {
+		a.SchemePaymentType = orNotProvided(t.PaymentType.LocalInstrument.Value())
+		a.SchemePaymentSubType = orNotProvided(t.PaymentType.CategoryPurpose.Value())
+	}
+	if t.Remittance != nil {
+		a.Reference = orNotProvided(t.Remittance.Unstructured)
+		for _, structured := range t.Remittance.Structured {
+			if structured.CreditorReference != nil {
+				a.NumericReference = orNotProvided(structured.CreditorReference.Reference)
+			}
+		}
+	}
+	for _, charge := range t.Charges {
+		a.ChargesInformation.SenderCharges = append(a.ChargesInformation.SenderCharges, acme.Charge{
+			Amount:   amountOf(charge.Amount),
+			Currency: charge.Amount.Currency,
+		})
+	}
+	instructingAgent := t.DebtorAgent
+	if t.InstructingAgent != nil {
+		instructingAgent = *t.InstructingAgent
+	}
+	sponsor := partyOf(PartyIdentification{}, t.DebtorAgentAccount, instructingAgent)
+	a.SponsorParty = &acme.Party{
+		AccountNumber: sponsor.AccountNumber,
+		BankID:        sponsor.BankID,
+		BankIDCode:    sponsor.BankIDCode,
+	}
+	return a
+}
+
+// paymentScheme is SEPA for a transaction with the SEPA service level, and otherwise the clearing system
+// that settles the message
+func paymentScheme(header Pacs008GroupHeader, paymentType *PaymentTypeInformation) string {
+	if paymentType != nil {
+		for _, level := range paymentType.ServiceLevel {
+			if level.Code == "SEPA" {
+				return "SEPA"
+			}
+		}
+	}
+	return header.Settlement.ClearingSystem.Value()
+}
+
+// fxOf is the conversion from the instructed amount to the settlement amount. A transaction without an
+// instructed amount is settled in the amount that was instructed.
+func fxOf(t Pacs008Transaction) *acme.FX {
+	fx := &acme.FX{
+		ContractReference: notProvided,
+		ExchangeRate:      t.ExchangeRate,
+		OriginalAmount:    amountOf(t.SettlementAmount),
+		OriginalCurrency:  t.SettlementAmount.Currency,
+	}
+	if t.InstructedAmount != nil {
+		fx.OriginalAmount = amountOf(*t.InstructedAmount)
+		fx.OriginalCurrency = t.InstructedAmount.Currency
+	}
+	if fx.ExchangeRate == "" && fx.OriginalCurrency == t.SettlementAmount.Currency {
+		fx.ExchangeRate = "1"
+	}
+	return fx
+}
+
+// partyOf maps a party, its account and its agent to a payment party
+func partyOf(p PartyIdentification, account *CashAccount, agent Agent) *acme.Party {
+	accountType := 0
+	party := &acme.Party{
+		Name:        p.Name,
+		AccountName: p.Name,
+		AccountType: &accountType,
+		Address:     addressOf(p.PostalAddress),
+	}
+	if account != nil {
+		if account.Name != "" {
+			party.AccountName = account.Name
+		}
+		if account.ID.IBAN != "" {
+			party.AccountNumber, party.AccountNumberCode = account.ID.IBAN, "IBAN"
+		} else if account.ID.Other != nil {
+			party.AccountNumber, party.AccountNumberCode = account.ID.Other.ID, account.ID.Other.SchemeName.Value()
+		}
+	}
+	institution := agent.FinancialInstitution
+	if institution.BIC != "" {
+		party.BankID, party.BankIDCode = institution.BIC, "SWBIC"
+	} else if member := institution.ClearingSystemMember; member != nil {
+		party.BankID, party.BankIDCode = member.MemberID, member.ClearingSystem.Value()
+	}
+	for _, field := range []*string{&party.Name, &party.AccountName, &party.Address, &party.AccountNumber,
+		&party.AccountNumberCode, &party.BankID, &party.BankIDCode} {
+		*field = orNotProvided(*field)
+	}
+	return party
+}
+
+// orNotProvided is the value of an element, or NOTPROVIDED when the message does not have it
+func orNotProvided(value string) string {
+	if value == "" {
+		return notProvided
+	}
+	return value
+}
+
+// addressOf joins the address lines, or the structured fields of an address, into a single line
+func addressOf(address *PostalAddress) string {
+	if address == nil {
+		return ""
+	}
+	if len(address.AddressLines) > 0 {
+		return strings.Join(address.AddressLines, " ")
+	}
+	var fields []string
+	for _, field := range []string{address.BuildingNumber, address.StreetName, address.TownName, address.PostCode,
+		address.Country} {
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// amountOf formats an amount with the number of decimal places of its currency, as XML decimals can have
+// fewer. An amount that is more precise than its currency is kept as it is, to fail validation.
+func amountOf(a Amount) string {
+	amount, err := acme.ParseDecimal(strings.TrimSpace(a.Value))
+	if err != nil {
+		return a.Value
+	}
+	if places := acme.MinorUnits(a.Currency); amount.Scale() <= places {
+		return amount.StringFixed(places)
+	}
+	return amount.String()
+}
+
+func zeroAmount(currency string) string {
+	return acme.NewDecimal(0, 0).StringFixed(acme.MinorUnits(currency))
+}
@@ -0,0 +1,147 @@
+package iso20022_test
+
+import (
+	"testing"
+
+	"github.com/steinfletcher/payments"
+	"github.com/steinfletcher/payments/iso20022"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPacs008_Attributes(t *testing.T) {
+	message, err := iso20022.ParsePacs008([]byte(readFile(t, "testdata/pacs008.xml")))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, message.Transfer.Transactions, 2)
+
+	accountType := 0
+	assert.Equal(t, acme.Attributes{
+		Amount: "100.20",
+		BeneficiaryParty: &acme.Party{
+			AccountName:       "W Owens",
+			AccountNumber:     "31926819",
+			AccountNumberCode: "BBAN",
+			AccountType:       &accountType,
+			Address:           "1 The Beneficiary Localtown SE2",
+			BankID:            "403000",
+			BankIDCode:        "GBDSC",
+			Name:              "Wilfred Jeremiah Owens",
+		},
+		ChargesInformation: &acme.ChargesInformation{
+			BearerCode:              "SHAR",
+			SenderCharges:           []acme.Charge{{Amount: "5.00", Currency: "GBP"}},
+			ReceiverChargesAmount:   "0.00",
+			ReceiverChargesCurrency: "GBP",
+		},
+		Currency: "GBP",
+		DebtorParty: &acme.Party{
+			AccountName:       "EJ Brown Black",
+			AccountNumber:     "GB83XABC10161234567801",
+			AccountNumberCode: "IBAN",
+			AccountType:       &accountType,
+			Address:           "10 Debtor Crescent Sourcetown NE1",
+			BankID:            "203301",
+			BankIDCode:        "GBDSC",
+			Name:              "Emelia Jane Brown",
+		},
+		EndToEndReference: "Wil piano Jan",
+		FX: &acme.FX{
+			ContractReference: "NOTPROVIDED",
+			ExchangeRate:      "2.00000",
+			OriginalAmount:    "200.42",
+			OriginalCurrency:  "USD",
+		},
+		NumericReference:     "1002001",
+		PaymentID:            "123456789012345678",
+		PaymentPurpose:       "Paying for goods/services",
+		PaymentScheme:        "FPS",
+		PaymentType:          "Credit",
+		ProcessingDate:       "2019-06-19",
+		Reference:            "Em's piano lessons",
+		SchemePaymentSubType: "InternetBanking",
+		SchemePaymentType:    "ImmediatePayment",
+		SponsorParty:         &acme.Party{AccountNumber: "56781234", BankID: "123123", BankIDCode: "GBDSC"},
+	}, message.Transfer.Transactions[0].Attributes(message.Transfer.GroupHeader))
+}
+
+func TestPacs008_AttributesWithoutOptionalElements(t *testing.T) {
+	message, err := iso20022.ParsePacs008([]byte(readFile(t, "testdata/pacs008.xml")))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	a := message.Transfer.Transactions[1].Attributes(message.Transfer.GroupHeader)
+	assert.Equal(t, "SEPA", a.PaymentScheme)
+	assert.Equal(t, "2019-06-20", a.ProcessingDate)
+	assert.Equal(t, "NOTPROVIDED", a.NumericReference)
+	assert.Equal(t, "Jonas Becker", a.DebtorParty.AccountName)
+	assert.Equal(t, "NOTPROVIDED", a.DebtorParty.Address)
+	assert.Equal(t, &acme.FX{ContractReference: "NOTPROVIDED", ExchangeRate: "1", OriginalAmount: "250.00",
+		OriginalCurrency: "EUR"}, a.FX)
+	assert.Equal(t, []acme.Charge{}, a.ChargesInformation.SenderCharges)
+	assert.Equal(t, &acme.Party{AccountNumber: "NOTPROVIDED", BankID: "COBADEFFXXX", BankIDCode: "SWBIC"},
+		a.SponsorParty)
+}
+
+func TestPacs008_AttributesOfMandatoryElements(t *testing.T) {
+	message, err := iso20022.ParsePacs008([]byte(readFile(t, "testdata/pacs008_mandatory.xml")))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	accountType := 0
+	party := func(bic string) *acme.Party {
+		return &acme.Party{
+			AccountName:       "NOTPROVIDED",
+			AccountNumber:     "NOTPROVIDED",
+			AccountNumberCode: "NOTPROVIDED",
+			AccountType:       &accountType,
+			Address:           "NOTPROVIDED",
+			BankID:            bic,
+			BankIDCode:        "SWBIC",
+			Name:              "NOTPROVIDED",
+		}
+	}
+	assert.Equal(t, acme.Attributes{
+		Amount:           "10.00",
+		BeneficiaryParty: party("BARCGB22"),
+		ChargesInformation: &acme.ChargesInformation{
+			BearerCode:              "SHAR",
+			SenderCharges:           []acme.Charge{},
+			ReceiverChargesAmount:   "0.00",
+			ReceiverChargesCurrency: "GBP",
+		},
+		Currency:          "GBP",
+		DebtorParty:       party("NWBKGB2L"),
+		EndToEndReference: "INV-2019-0043",
+		FX: &acme.FX{
+			ContractReference: "NOTPROVIDED",
+			ExchangeRate:      "1",
+			OriginalAmount:    "10.00",
+			OriginalCurrency:  "GBP",
+		},
+		NumericReference:     "NOTPROVIDED",
+		PaymentID:            "NOTPROVIDED",
+		PaymentPurpose:       "NOTPROVIDED",
+		PaymentScheme:        "NOTPROVIDED",
+		PaymentType:          "Credit",
+		ProcessingDate:       "2019-06-19",
+		Reference:            "NOTPROVIDED",
+		SchemePaymentSubType: "NOTPROVIDED",
+		SchemePaymentType:    "NOTPROVIDED",
+		SponsorParty:         &acme.Party{AccountNumber: "NOTPROVIDED", BankID: "NWBKGB2L", BankIDCode: "SWBIC"},
+	}, message.Transfer.Transactions[0].Attributes(message.Transfer.GroupHeader))
+}
+
+func TestParsePacs008_RejectsOtherDocuments(t *testing.T) {
+	tests := map[string]string{
+		`<Document`: "the message is not valid XML: XML syntax error on line 1: unexpected EOF",
+		`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.09"></Document>`: "the message is not a pacs.008 document",
+		`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pacs.008.001.08"></Document>`: "the message has no credit transfer transactions",
+	}
+	for message, expected := range tests {
+		_, err := iso20022.ParsePacs008([]byte(message))
+		assert.EqualError(t, err, expected, message)
+	}
+}
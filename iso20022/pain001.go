@@ -0,0 +1,183 @@
+package iso20022
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/steinfletcher/payments"
+)
+
+// Pain001 is a pain.001.001.09 customer credit transfer initiation
+type Pain001 struct {
+	XMLName    xml.Name                         `xml:"urn:iso:std:iso:20022:tech:xsd:pain.001.001.09 Document"`
+	Initiation CustomerCreditTransferInitiation `xml:"CstmrCdtTrfInitn"`
+}
+
+type CustomerCreditTransferInitiation struct {
+	GroupHeader         GroupHeader          `xml:"GrpHdr"`
+	PaymentInstructions []PaymentInstruction `xml:"PmtInf"`
+}
+
+type GroupHeader struct {
+	MessageID            string              `xml:"MsgId"`
+	Created              string              `xml:"CreDtTm"`
+	NumberOfTransactions string              `xml:"NbOfTxs"`
+	ControlSum           string              `xml:"CtrlSum,omitempty"`
+	InitiatingParty      PartyIdentification `xml:"InitgPty"`
+}
+
+// PaymentInstruction is a set of credit transfers from the same debtor account on the same date
+type PaymentInstruction struct {
+	ID                   string                      `xml:"PmtInfId"`
+	PaymentMethod        string                      `xml:"PmtMtd"`
+	NumberOfTransactions string                      `xml:"NbOfTxs"`
+	ControlSum           string                      `xml:"CtrlSum,omitempty"`
+	PaymentType          *PaymentTypeInformation     `xml:"PmtTpInf"`
+	RequestedExecution   DateChoice                  `xml:"ReqdExctnDt"`
+	Debtor               PartyIdentification         `xml:"Dbtr"`
+	DebtorAccount        CashAccount                 `xml:"DbtrAcct"`
+	DebtorAgent          Agent                       `xml:"DbtrAgt"`
+	ChargeBearer         string                      `xml:"ChrgBr,omitempty"`
+	Transactions         []CreditTransferTransaction `xml:"CdtTrfTxInf"`
+}
+
+type DateChoice struct {
+	Date string `xml:"Dt"`
+}
+
+type CreditTransferTransaction struct {
+	PaymentID       PaymentIdentification  `xml:"PmtId"`
+	Amount          InstructedAmount       `xml:"Amt"`
+	ChargeBearer    string                 `xml:"ChrgBr,omitempty"`
+	CreditorAgent   *Agent                 `xml:"CdtrAgt"`
+	Creditor        PartyIdentification    `xml:"Cdtr"`
+	CreditorAccount CashAccount            `xml:"CdtrAcct"`
+	Remittance      *RemittanceInformation `xml:"RmtInf"`
+}
+
+type PaymentIdentification struct {
+	InstructionID string `xml:"InstrId,omitempty"`
+	EndToEndID    string `xml:"EndToEndId"`
+}
+
+type InstructedAmount struct {
+	Instructed Amount `xml:"InstdAmt"`
+}
+
+// NewPain001 maps payments to a customer credit transfer initiation with a payment instruction for each
+// payment. The debtor party of a payment is the debtor of its instruction and the beneficiary party the
+// creditor of its transaction. The payments must be in the same currency, so that the control sum of the
+// document is a total.
+func NewPain001(header Header, payments []acme.Payment) (Pain001, error) {
+	var instructions []PaymentInstruction
+	for _, p := range payments {
+		m := mapping{payment: p.ID}
+		instruction := m.instruction(strings.Replace(p.ID.String(), "-", "", -1), p.Attributes)
+		instruction.Transactions = []CreditTransferTransaction{m.transaction(p.Attributes)}
+		if m.err != nil {
+			return Pain001{}, m.err
+		}
+		if currency := payments[0].Attributes.Currency; p.Attributes.Currency != currency {
+			return Pain001{}, fmt.Errorf("the payments are in %s and %s, a document holds payments in one currency",
+				currency, p.Attributes.Currency)
+		}
+		instructions = append(instructions, instruction)
+	}
+	return newPain001(header, instructions)
+}
+
+func newPain001(header Header, instructions []PaymentInstruction) (Pain001, error) {
+	m := mapping{}
+	var transactions []CreditTransferTransaction
+	for i := range instructions {
+		count, sum := controlSum(instructions[i].Transactions)
+		instructions[i].NumberOfTransactions, instructions[i].ControlSum = count, sum
+		transactions = append(transactions, instructions[i].Transactions...)
+	}
+	if len(transactions) == 0 {
+		m.fail("there are no payments to initiate")
+		return Pain001{}, m.err
+	}
+	count, sum := controlSum(transactions)
+	return Pain001{Initiation: CustomerCreditTransferInitiation{
+		GroupHeader: GroupHeader{
+			MessageID:            m.text("message ID", header.MessageID, 35),
+			Created:              header.Created.UTC().Format(dateTimeFormat),
+			NumberOfTransactions: count,
+			ControlSum:           sum,
+			InitiatingParty:      PartyIdentification{Name: m.text("initiating party", header.InitiatingParty, 140)},
+		},
+		PaymentInstructions: instructions,
+	}}, m.err
+}
+
+// Marshal renders the initiation as an XML document
+func (p Pain001) Marshal() ([]byte, error) {
+	return marshal(p)
+}
+
+// instruction maps the debtor and the processing date of a payment to a payment instruction
+func (m *mapping) instruction(id string, a acme.Attributes) PaymentInstruction {
+	instruction := PaymentInstruction{
+		ID:                 m.text("payment information ID", id, 35),
+		PaymentMethod:      "TRF",
+		RequestedExecution: DateChoice{Date: m.date("processing_date", a.ProcessingDate)},
+	}
+	if a.DebtorParty == nil {
+		m.fail("debtor_party is required")
+		return instruction
+	}
+	instruction.Debtor = m.party("debtor_party", a.DebtorParty)
+	instruction.DebtorAccount = m.account("debtor_party", a.DebtorParty)
+	instruction.DebtorAgent = m.agent("debtor_party", a.DebtorParty)
+	return instruction
+}
+
+// transaction maps the amount, the references and the beneficiary of a payment to a credit transfer
+func (m *mapping) transaction(a acme.Attributes) CreditTransferTransaction {
+	endToEnd := a.EndToEndReference
+	if endToEnd == "" {
+		endToEnd = notProvided
+	}
+	if _, err := acme.ParseMoney(a.Amount, a.Currency); err != nil {
+		m.fail("amount is not valid: %s", err)
+	}
+	transaction := CreditTransferTransaction{
+		PaymentID: PaymentIdentification{
+			InstructionID: m.text("payment_id", a.PaymentID, 35),
+			EndToEndID:    m.text("end_to_end_reference", endToEnd, 35),
+		},
+		Amount:       InstructedAmount{Instructed: Amount{Currency: a.Currency, Value: a.Amount}},
+		ChargeBearer: chargeBearer(a),
+	}
+	if a.Reference != "" {
+		transaction.Remittance = &RemittanceInformation{Unstructured: m.text("reference", a.Reference, 140)}
+	}
+	if a.BeneficiaryParty == nil {
+		m.fail("beneficiary_party is required")
+		return transaction
+	}
+	if agent := m.agent("beneficiary_party", a.BeneficiaryParty); agent != (Agent{}) {
+		transaction.CreditorAgent = &agent
+	}
+	transaction.Creditor = m.party("beneficiary_party", a.BeneficiaryParty)
+	transaction.CreditorAccount = m.account("beneficiary_party", a.BeneficiaryParty)
+	return transaction
+}
+
+// controlSum is the number of transactions and the sum of their amounts, which are in the same currency
+func controlSum(transactions []CreditTransferTransaction) (string, string) {
+	sum := acme.NewDecimal(0, 0)
+	for _, t := range transactions {
+		amount, err := acme.ParseDecimal(t.Amount.Instructed.Value)
+		if err == nil {
+			sum, err = sum.Add(amount)
+		}
+		if err != nil {
+			return strconv.Itoa(len(transactions)), ""
+		}
+	}
+	return strconv.Itoa(len(transactions)), sum.String()
+}
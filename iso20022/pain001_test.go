@@ -0,0 +1,96 @@
+package iso20022_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/steinfletcher/payments"
+	"github.com/steinfletcher/payments/iso20022"
+	"github.com/stretchr/testify/assert"
+)
+
+var header = iso20022.Header{
+	MessageID:       "5b1f7e0c2d8a4e3f9a6b7c8d9e0f1a2b",
+	Created:         time.Date(2019, 6, 19, 10, 0, 0, 0, time.UTC),
+	InitiatingParty: "743d5b63-8e6f-432e-a8fa-c5d8d2ee5fcb",
+}
+
+func TestPain001(t *testing.T) {
+	document, err := iso20022.NewPain001(header, readPayments(t))
+	assert.NoError(t, err)
+
+	data, err := document.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, readFile(t, "testdata/pain001.xml"), string(data))
+}
+
+// TestPain001_SchemaValid validates the expected documents against the pain.001.001.09 XSD at the
+// PAIN001_XSD path with xmllint. The XSD is published by ISO 20022 and is not part of the repository.
+func TestPain001_SchemaValid(t *testing.T) {
+	xsd := os.Getenv("PAIN001_XSD")
+	if xsd == "" {
+		t.Skip("PAIN001_XSD is not set")
+	}
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		t.Skip("xmllint is not installed")
+	}
+	for _, path := range []string{"testdata/pain001.xml", "testdata/sepa.xml"} {
+		output, err := exec.Command(xmllint, "--noout", "--schema", xsd, path).CombinedOutput()
+		assert.NoError(t, err, string(output))
+	}
+}
+
+func TestPain001_RejectsPaymentsThatDoNotFit(t *testing.T) {
+	tests := map[string]func(a *acme.Attributes){
+		"debtor_party is required":                               func(a *acme.Attributes) { a.DebtorParty = nil },
+		"beneficiary_party is required":                          func(a *acme.Attributes) { a.BeneficiaryParty = nil },
+		"processing_date must be a date formatted as YYYY-MM-DD": func(a *acme.Attributes) { a.ProcessingDate = "18/01/2017" },
+		"end_to_end_reference is longer than 35 characters": func(a *acme.Attributes) {
+			a.EndToEndReference = "Wilfred Owens piano lessons January 2017"
+		},
+		"debtor_party.account_number is required":                   func(a *acme.Attributes) { a.DebtorParty.AccountNumber = "" },
+		"amount is not valid: XYZ is not an ISO 4217 currency code": func(a *acme.Attributes) { a.Currency = "XYZ" },
+	}
+	for message, change := range tests {
+		payments := readPayments(t)
+		change(&payments[0].Attributes)
+
+		_, err := iso20022.NewPain001(header, payments)
+		assert.EqualError(t, err, "payment 4ee3a8d8-ca7b-4290-a52c-dd5b6165ec43: "+message)
+	}
+}
+
+func TestPain001_RejectsMixedCurrencies(t *testing.T) {
+	payments := readPayments(t)
+	payments[1].Attributes.Currency = "EUR"
+
+	_, err := iso20022.NewPain001(header, payments)
+	assert.EqualError(t, err, "the payments are in GBP and EUR, a document holds payments in one currency")
+}
+
+func TestPain001_RequiresPayments(t *testing.T) {
+	_, err := iso20022.NewPain001(header, nil)
+	assert.EqualError(t, err, "there are no payments to initiate")
+}
+
+func readPayments(t *testing.T) []acme.Payment {
+	var payments []acme.Payment
+	err := json.Unmarshal([]byte(readFile(t, "testdata/payments.json")), &payments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payments
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
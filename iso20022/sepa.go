@@ -0,0 +1,260 @@
+package iso20022
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/steinfletcher/payments"
+)
+
+const (
+	sepaCurrency = "EUR"
+	// sepaNameLength and sepaAddressLines are the limits of the names and postal addresses of SEPA parties
+	sepaNameLength   = 70
+	sepaAddressLines = 2
+)
+
+// sepaCharacters is the Latin character set that SEPA messages are written in
+const sepaCharacters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789/-?:().,'+ "
+
+// transliterations are the characters of the SEPA character set that other characters are written as,
+// following the conversion table of the EPC
+var transliterations = func() map[rune]string {
+	t := map[rune]string{
+		'ß': "ss", 'Æ': "AE", 'æ': "ae", 'Œ': "OE", 'œ': "oe", 'Þ': "TH", 'þ': "th", 'Ĳ': "IJ", 'ĳ': "ij",
+		'&': "+", '‘': "'", '’': "'", '‚': "'", '“': "'", '”': "'", '„': "'", '"': "'", '`': "'",
+		'–': "-", '—': "-", '_': "-", '\t': " ",
+	}
+	for latin, accented := range map[string]string{
+		"A": "ÀÁÂÃÄÅĀĂĄ", "a": "àáâãäåāăą", "C": "ÇĆĈĊČ", "c": "çćĉċč", "D": "ÐĎĐ", "d": "ðďđ",
+		"E": "ÈÉÊËĒĔĖĘĚ", "e": "èéêëēĕėęě", "G": "ĜĞĠĢ", "g": "ĝğġģ", "H": "ĤĦ", "h": "ĥħ",
+		"I": "ÌÍÎÏĨĪĬĮİ", "i": "ìíîïĩīĭįı", "J": "Ĵ", "j": "ĵ", "K": "Ķ", "k": "ķĸ", "L": "ĹĻĽĿŁ",
+		"l": "ĺļľŀł", "N": "ÑŃŅŇŊ", "n": "ñńņňŉŋ", "O": "ÒÓÔÕÖØŌŎŐ", "o": "òóôõöøōŏő", "R": "ŔŖŘ",
+		"r": "ŕŗř", "S": "ŚŜŞŠ", "s": "śŝşšſ", "T": "ŢŤŦ", "t": "ţťŧ", "U": "ÙÚÛÜŨŪŬŮŰŲ",
+		"u": "ùúûüũūŭůűų", "W": "Ŵ", "w": "ŵ", "Y": "ÝŶŸ", "y": "ýÿŷ", "Z": "ŹŻŽ", "z": "źżž",
+	} {
+		for _, r := range accented {
+			t[r] = latin
+		}
+	}
+	return t
+}()
+
+// Transliterate writes text in the SEPA character set, replacing the characters it does not have by the
+// characters they are conventionally written as, e.g. é by e and ß by ss. It fails for a character that
+// has no transliteration.
+func Transliterate(text string) (string, error) {
+	var b strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune(sepaCharacters, r) {
+			b.WriteRune(r)
+		} else if t, ok := transliterations[r]; ok {
+			b.WriteString(t)
+		} else {
+			return "", fmt.Errorf("the character %q is not in the SEPA character set", r)
+		}
+	}
+	return b.String(), nil
+}
+
+// CheckSEPA reports why a payment cannot be a SEPA credit transfer, or nil if it can
+func CheckSEPA(p acme.Payment) error {
+	_, err := sepaAttributes(p.Attributes)
+	return err
+}
+
+// NewSEPAPain001 maps EUR payments to a SEPA credit transfer initiation. The payments from the same debtor
+// account on the same processing date are the transactions of one payment instruction, and names and
+// references are transliterated to the SEPA character set.
+func NewSEPAPain001(header Header, payments []acme.Payment) (Pain001, error) {
+	var instructions []PaymentInstruction
+	groups := map[string]int{}
+	for _, p := range payments {
+		a, err := sepaAttributes(p.Attributes)
+		if err != nil {
+			return Pain001{}, fmt.Errorf("payment %s: %s", p.ID, err)
+		}
+
+		m := mapping{payment: p.ID}
+		key := a.DebtorParty.AccountNumber + " " + a.ProcessingDate
+		i, ok := groups[key]
+		if !ok {
+			i = len(instructions)
+			groups[key] = i
+			instruction := m.instruction(fmt.Sprintf("%.28s-%d", header.MessageID, i+1), a)
+			instruction.PaymentType = &PaymentTypeInformation{ServiceLevel: []CodeOrProprietary{{Code: "SEPA"}}}
+			instruction.ChargeBearer = "SLEV"
+			instructions = append(instructions, instruction)
+		}
+		transaction := m.transaction(a)
+		transaction.ChargeBearer = ""
+		if m.err != nil {
+			return Pain001{}, m.err
+		}
+		instructions[i].Transactions = append(instructions[i].Transactions, transaction)
+	}
+	return newPain001(header, instructions)
+}
+
+// sepaAttributes checks that the attributes of a payment fit a SEPA credit transfer and transliterates
+// its names and references
+func sepaAttributes(a acme.Attributes) (acme.Attributes, error) {
+	if a.Currency != sepaCurrency {
+		return a, fmt.Errorf("currency must be %s", sepaCurrency)
+	}
+	money, err := a.Money()
+	if err != nil {
+		return a, fmt.Errorf("amount is not valid: %s", err)
+	}
+	if money.Amount.Sign() <= 0 || money.Amount.Cmp(acme.NewDecimal(99999999999, 2)) > 0 {
+		return a, fmt.Errorf("amount must be between 0.01 and 999999999.99")
+	}
+	if _, err := time.Parse(dateFormat, a.ProcessingDate); err != nil {
+		return a, fmt.Errorf("processing_date must be a date formatted as YYYY-MM-DD")
+	}
+	if a.DebtorParty == nil || a.BeneficiaryParty == nil {
+		return a, fmt.Errorf("debtor_party and beneficiary_party are required")
+	}
+
+	t := transliteration{}
+	debtor, beneficiary := *a.DebtorParty, *a.BeneficiaryParty
+	a.DebtorParty, a.BeneficiaryParty = t.party("debtor_party", &debtor), t.party("beneficiary_party", &beneficiary)
+	a.Reference = t.text("reference", a.Reference, 140)
+	a.EndToEndReference = t.identifier("end_to_end_reference", a.EndToEndReference)
+	a.PaymentID = t.identifier("payment_id", a.PaymentID)
+	return a, t.err
+}
+
+// transliteration collects the first error found while transliterating the fields of a payment
+type transliteration struct {
+	err error
+}
+
+func (t *transliteration) fail(format string, args ...interface{}) {
+	if t.err == nil {
+		t.err = fmt.Errorf(format, args...)
+	}
+}
+
+// text transliterates a field and checks that it fits the maximum length of its element
+func (t *transliteration) text(field, value string, length int) string {
+	transliterated, err := Transliterate(value)
+	if err != nil {
+		t.fail("%s: %s", field, err)
+		return value
+	}
+	if utf8.RuneCountInString(transliterated) > length {
+		t.fail("%s is longer than %d characters", field, length)
+	}
+	return transliterated
+}
+
+// identifier transliterates a reference that identifies a payment, which must not start or end with a
+// slash or contain two slashes in SEPA messages
+func (t *transliteration) identifier(field, value string) string {
+	value = t.text(field, value, 35)
+	if strings.HasPrefix(value, "/") || strings.HasSuffix(value, "/") || strings.Contains(value, "//") {
+		t.fail("%s must not start or end with / or contain //", field)
+	}
+	return value
+}
+
+// party transliterates the names and the address of a party, whose account must be an IBAN at a bank
+// identified by its BIC
+func (t *transliteration) party(field string, p *acme.Party) *acme.Party {
+	if p.AccountNumberCode != "IBAN" || acme.CheckIBAN(p.AccountNumber) != nil {
+		t.fail("%s.account_number must be a valid IBAN", field)
+	}
+	if p.BankIDCode != "SWBIC" || acme.CheckBIC(p.BankID) != nil {
+		t.fail("%s.bank_id must be a BIC", field)
+	}
+	p.Name = t.text(field+".name", p.Name, sepaNameLength)
+	p.AccountName = t.text(field+".account_name", p.AccountName, sepaNameLength)
+	p.Address = t.text(field+".address", p.Address, sepaAddressLines*addressLineLength)
+	if len(addressLinesOf(p.Address)) > sepaAddressLines {
+		t.fail("%s.address does not fit %d address lines", field, sepaAddressLines)
+	}
+	return p
+}
+
+// Exclusion is a payment that is left out of an export, and the reason why
+type Exclusion struct {
+	PaymentID uuid.UUID
+	Reason    string
+}
+
+// SEPAExport is the outcome of an export of SEPA credit transfers. Document is nil for a dry run and when
+// every payment is excluded.
+type SEPAExport struct {
+	Included []uuid.UUID
+	Excluded []Exclusion
+	Document *Pain001
+}
+
+// SEPAExporter exports the approved EUR payments of an organisation as SEPA credit transfers
+type SEPAExporter struct {
+	service acme.PaymentService
+	// pageSize is the number of payments read from the service at a time
+	pageSize int
+	// maxSize is the most payments an export reads, so that a document is built from a bounded number
+	// of payments
+	maxSize int
+}
+
+func NewSEPAExporter(service acme.PaymentService, maxSize int) *SEPAExporter {
+	return &SEPAExporter{service: service, pageSize: 100, maxSize: maxSize}
+}
+
+// Export reads the approved EUR payments of the organisation that match the filter and maps the ones that
+// can be SEPA credit transfers to a pain.001 document. The other payments are excluded with the reason. A
+// dry run reports which payments would be included and excluded without building the document. It fails
+// with acme.NothingToExport if no payment matches the filter, and with acme.PaymentNotExportable if more
+// than the maximum size of an export do.
+func (e *SEPAExporter) Export(organisationID uuid.UUID, filter acme.PaymentFilter, header Header,
+	dryRun bool) (SEPAExport, error) {
+	filter.Currency, filter.Status = sepaCurrency, acme.StatusApproved
+	query := acme.PaymentQuery{Limit: e.pageSize, Filter: filter}
+
+	var export SEPAExport
+	var included []acme.Payment
+	for {
+		page, err := e.service.List(organisationID, query)
+		if err != nil {
+			return SEPAExport{}, err
+		}
+		if len(export.Included)+len(export.Excluded)+len(page.Payments) > e.maxSize {
+			err := acme.PaymentNotExportable
+			err.Detail = fmt.Sprintf("more than %d payments match the filters, narrow them down e.g. with "+
+				"processing_date_from and processing_date_to", e.maxSize)
+			return SEPAExport{}, err
+		}
+		for _, p := range page.Payments {
+			if err := CheckSEPA(p); err != nil {
+				export.Excluded = append(export.Excluded, Exclusion{PaymentID: p.ID, Reason: err.Error()})
+				continue
+			}
+			export.Included = append(export.Included, p.ID)
+			included = append(included, p)
+		}
+		if page.Next == nil {
+			break
+		}
+		query.Cursor = *page.Next
+	}
+
+	if len(export.Included) == 0 && len(export.Excluded) == 0 {
+		return SEPAExport{}, acme.NothingToExport
+	}
+	if dryRun || len(included) == 0 {
+		return export, nil
+	}
+	document, err := NewSEPAPain001(header, included)
+	if err != nil {
+		return SEPAExport{}, err
+	}
+	export.Document = &document
+	return export, nil
+}
@@ -0,0 +1,167 @@
+package iso20022_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	m "github.com/petergtz/pegomock"
+	"github.com/steinfletcher/payments"
+	"github.com/steinfletcher/payments/iso20022"
+	"github.com/steinfletcher/payments/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransliterate(t *testing.T) {
+	tests := map[string]string{
+		"Invoice 2019/0042 (paid)": "Invoice 2019/0042 (paid)",
+		"Jürgen Weiß":              "Jurgen Weiss",
+		"Café Crème & Co":          "Cafe Creme + Co",
+		"Iñigo Núñez":              "Inigo Nunez",
+		"Łukasz Żółć":              "Lukasz Zolc",
+		"“Œuvre” — Ærø":            "'OEuvre' - AEro",
+	}
+	for text, expected := range tests {
+		transliterated, err := iso20022.Transliterate(text)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, transliterated)
+	}
+}
+
+func TestTransliterate_RejectsCharactersWithoutTransliteration(t *testing.T) {
+	_, err := iso20022.Transliterate("Invoice €42")
+	assert.EqualError(t, err, "the character '€' is not in the SEPA character set")
+}
+
+func TestSEPAPain001(t *testing.T) {
+	document, err := iso20022.NewSEPAPain001(header, sepaPayments(t)[:3])
+	assert.NoError(t, err)
+
+	data, err := document.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, readFile(t, "testdata/sepa.xml"), string(data))
+}
+
+func TestSEPAPain001_GroupsByDebtorAccountAndDate(t *testing.T) {
+	document, err := iso20022.NewSEPAPain001(header, sepaPayments(t)[:3])
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	instructions := document.Initiation.PaymentInstructions
+	assert.Len(t, instructions, 2)
+	assert.Equal(t, "2019-06-20", instructions[0].RequestedExecution.Date)
+	assert.Equal(t, "2", instructions[0].NumberOfTransactions)
+	assert.Equal(t, "349.95", instructions[0].ControlSum)
+	assert.Equal(t, "2019-06-21", instructions[1].RequestedExecution.Date)
+	assert.Equal(t, "SLEV", instructions[1].ChargeBearer)
+	assert.Equal(t, "SEPA", instructions[1].PaymentType.ServiceLevel[0].Code)
+	assert.Equal(t, "Jurgen Weiss", instructions[1].Debtor.Name)
+}
+
+func TestSEPAPain001_RejectsPaymentsThatAreNotSEPA(t *testing.T) {
+	tests := map[string]func(a *acme.Attributes){
+		"currency must be EUR":                                   func(a *acme.Attributes) { a.Currency = "GBP" },
+		"amount must be between 0.01 and 999999999.99":           func(a *acme.Attributes) { a.Amount = "1000000000.00" },
+		"processing_date must be a date formatted as YYYY-MM-DD": func(a *acme.Attributes) { a.ProcessingDate = "20/06/2019" },
+		"debtor_party and beneficiary_party are required":        func(a *acme.Attributes) { a.BeneficiaryParty = nil },
+		"debtor_party.account_number must be a valid IBAN": func(a *acme.Attributes) {
+			a.DebtorParty.AccountNumber = "DE00370400440532013000"
+		},
+		"beneficiary_party.bank_id must be a BIC": func(a *acme.Attributes) {
+			a.BeneficiaryParty.BankID, a.BeneficiaryParty.BankIDCode = "20041", "FRRIB"
+		},
+		"beneficiary_party.name: the character '€' is not in the SEPA character set": func(a *acme.Attributes) {
+			a.BeneficiaryParty.Name = "€uro Café"
+		},
+		"reference is longer than 140 characters": func(a *acme.Attributes) {
+			a.Reference = strings.Repeat("a", 141)
+		},
+		"end_to_end_reference must not start or end with / or contain //": func(a *acme.Attributes) {
+			a.EndToEndReference = "INV//2019"
+		},
+	}
+	for message, change := range tests {
+		payments := sepaPayments(t)
+		change(&payments[0].Attributes)
+
+		_, err := iso20022.NewSEPAPain001(header, payments[:1])
+		assert.EqualError(t, err, "payment 8a4c5e1f-3b5d-4a8e-9f77-2d1c0b9e6a10: "+message, message)
+	}
+}
+
+func TestSEPAExporter_Export(t *testing.T) {
+	payments := sepaPayments(t)
+	service := mocks.NewMockPaymentService()
+	query := acme.PaymentQuery{Limit: 100, Filter: acme.PaymentFilter{Currency: "EUR", Status: acme.StatusApproved}}
+	m.When(service.List(organisationID, query)).ThenReturn(acme.PaymentPage{
+		Payments: payments[:3],
+		Next:     acme.CursorAfter(payments[2].ID),
+	}, nil)
+	query.Cursor = *acme.CursorAfter(payments[2].ID)
+	m.When(service.List(organisationID, query)).ThenReturn(acme.PaymentPage{Payments: payments[3:]}, nil)
+
+	export, err := iso20022.NewSEPAExporter(service, 1000).Export(organisationID,
+		acme.PaymentFilter{Status: acme.StatusApproved}, header, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []uuid.UUID{payments[0].ID, payments[1].ID, payments[2].ID}, export.Included)
+	assert.Equal(t, []iso20022.Exclusion{
+		{PaymentID: payments[3].ID, Reason: "reference: the character '€' is not in the SEPA character set"},
+		{PaymentID: payments[4].ID, Reason: "beneficiary_party.account_number must be a valid IBAN"},
+	}, export.Excluded)
+	if assert.NotNil(t, export.Document) {
+		assert.Equal(t, "3", export.Document.Initiation.GroupHeader.NumberOfTransactions)
+	}
+}
+
+func TestSEPAExporter_DryRun(t *testing.T) {
+	payments := sepaPayments(t)
+	service := mocks.NewMockPaymentService()
+	m.When(service.List(organisationID, acme.PaymentQuery{Limit: 100, Filter: acme.PaymentFilter{Currency: "EUR", Status: acme.StatusApproved}})).
+		ThenReturn(acme.PaymentPage{Payments: payments}, nil)
+
+	export, err := iso20022.NewSEPAExporter(service, 1000).Export(organisationID, acme.PaymentFilter{}, header, true)
+	assert.NoError(t, err)
+	assert.Len(t, export.Included, 3)
+	assert.Len(t, export.Excluded, 2)
+	assert.Nil(t, export.Document)
+}
+
+func TestSEPAExporter_TooManyPayments(t *testing.T) {
+	payments := sepaPayments(t)
+	service := mocks.NewMockPaymentService()
+	query := acme.PaymentQuery{Limit: 100, Filter: acme.PaymentFilter{Currency: "EUR", Status: acme.StatusApproved}}
+	m.When(service.List(organisationID, query)).ThenReturn(acme.PaymentPage{Payments: payments}, nil)
+
+	_, err := iso20022.NewSEPAExporter(service, 4).Export(organisationID, acme.PaymentFilter{}, header, true)
+	expected := acme.PaymentNotExportable
+	expected.Detail = "more than 4 payments match the filters, narrow them down e.g. with processing_date_from " +
+		"and processing_date_to"
+	assert.Equal(t, expected, err)
+}
+
+func TestSEPAExporter_NothingToExport(t *testing.T) {
+	service := mocks.NewMockPaymentService()
+	m.When(service.List(organisationID, acme.PaymentQuery{Limit: 100, Filter: acme.PaymentFilter{Currency: "EUR", Status: acme.StatusApproved}})).
+		ThenReturn(acme.PaymentPage{}, nil)
+
+	_, err := iso20022.NewSEPAExporter(service, 1000).Export(organisationID, acme.PaymentFilter{}, header, false)
+	assert.Equal(t, acme.NothingToExport, err)
+}
+
+var organisationID = uuid.MustParse("743d5b63-8e6f-432e-a8fa-c5d8d2ee5fcb")
+
+// sepaPayments are three payments that are SEPA credit transfers, two of them on the same date, and two
+// payments that are not
+func sepaPayments(t *testing.T) []acme.Payment {
+	var payments []acme.Payment
+	err := json.Unmarshal([]byte(readFile(t, "testdata/sepa_payments.json")), &payments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payments
+}
@@ -0,0 +1,109 @@
+package jsonpatch_test
+
+import (
+	"testing"
+
+	"github.com/steinfletcher/payments/jsonpatch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		patch    string
+		expected string
+	}{
+		{"replace member", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"add member", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"remove member", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"replace array", `{"a":["b"]}`, `{"a":["c","d"]}`, `{"a":["c","d"]}`},
+		{"merge nested object", `{"a":{"b":"c","d":"e"}}`, `{"a":{"b":"f","d":null}}`, `{"a":{"b":"f"}}`},
+		{"replace scalar with object", `{"a":"b"}`, `{"a":{"c":null,"d":"e"}}`, `{"a":{"d":"e"}}`},
+		{"non object patch replaces document", `{"a":"b"}`, `["c"]`, `["c"]`},
+		{"keeps numbers exact", `{"a":1}`, `{"b":12345678901234567890.123}`, `{"a":1,"b":12345678901234567890.123}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			patched, err := jsonpatch.MergePatch([]byte(test.doc), []byte(test.patch))
+
+			assert.NoError(t, err)
+			assert.JSONEq(t, test.expected, string(patched))
+		})
+	}
+}
+
+func TestMergePatch_InvalidPatch(t *testing.T) {
+	_, err := jsonpatch.MergePatch([]byte(`{}`), []byte(`{"a":`))
+
+	assert.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		patch    string
+		expected string
+	}{
+		{"add member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{"add array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"append array element", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{"add null value", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":null}]`, `{"baz":null,"foo":"bar"}`},
+		{"remove member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"remove array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"replace member", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"replace document", `{"foo":"bar"}`, `[{"op":"replace","path":"","value":{"baz":"qux"}}]`, `{"baz":"qux"}`},
+		{"move member", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"move array element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			`{"foo":["all","cows","eat","grass"]}`},
+		{"copy member", `{"foo":{"bar":"baz"}}`, `[{"op":"copy","from":"/foo","path":"/qux"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"bar":"baz"}}`},
+		{"test passes", `{"baz":"qux","foo":["a",2,"c"]}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`},
+		{"escaped path", `{"a/b":{"m~n":1}}`, `[{"op":"replace","path":"/a~1b/m~0n","value":2}]`, `{"a/b":{"m~n":2}}`},
+		{"nested arrays", `{"a":[[1,2],[3]]}`, `[{"op":"add","path":"/a/1/0","value":0}]`, `{"a":[[1,2],[0,3]]}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			patched, err := jsonpatch.Apply([]byte(test.doc), []byte(test.patch))
+
+			assert.NoError(t, err)
+			assert.JSONEq(t, test.expected, string(patched))
+		})
+	}
+}
+
+func TestApply_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		err   string
+	}{
+		{"not a list", `{}`, `{"op":"add"}`, "patch is not a list of operations"},
+		{"unknown operation", `{}`, `[{"op":"merge","path":"/a","value":1}]`, `operation 0: unknown operation "merge"`},
+		{"missing value", `{}`, `[{"op":"add","path":"/a"}]`, "operation 0: add requires a value"},
+		{"invalid path", `{}`, `[{"op":"add","path":"a","value":1}]`, `operation 0: path "a" must start with /`},
+		{"missing parent", `{}`, `[{"op":"add","path":"/a/b","value":1}]`, `operation 0: path "/a" does not exist`},
+		{"remove missing member", `{"a":1}`, `[{"op":"remove","path":"/b"}]`, `operation 0: path "/b" does not exist`},
+		{"replace missing member", `{"a":1}`, `[{"op":"replace","path":"/b","value":1}]`, `operation 0: path "/b" does not exist`},
+		{"index out of bounds", `{"a":[1]}`, `[{"op":"add","path":"/a/2","value":1}]`, `operation 0: path "/a/2": index 2 is out of bounds`},
+		{"leading zero index", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/01"}]`, `operation 0: path "/a/01": "01" is not an array index`},
+		{"test fails", `{"a":"b"}`, `[{"op":"test","path":"/a","value":"c"}]`, `operation 0: test failed: the value at "/a" is different`},
+		{"move into child", `{"a":{"b":{}}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`, `operation 0: cannot move "/a" into one of its children`},
+		{"later operation fails", `{}`, `[{"op":"add","path":"/a","value":1},{"op":"remove","path":"/b"}]`, `operation 1: path "/b" does not exist`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := jsonpatch.Apply([]byte(test.doc), []byte(test.patch))
+
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package acme
+
+import "net/http"
+
+// Kind classifies application errors so that each transport can map an error to its own status
+// without knowing every error code. The zero value is KindInternal, so an error without a kind is
+// reported as a server error rather than blamed on the client.
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindInvalid
+	KindNotFound
+	KindConflict
+	KindFailedPrecondition
+	KindUnprocessable
+	KindUnsupportedMediaType
+	KindUnauthorized
+	KindForbidden
+	KindRateLimited
+	KindUnavailable
+)
+
+// gRPC status codes, as defined by google.golang.org/grpc/codes
+const (
+	grpcInvalidArgument    uint32 = 3
+	grpcNotFound           uint32 = 5
+	grpcPermissionDenied   uint32 = 7
+	grpcResourceExhausted  uint32 = 8
+	grpcFailedPrecondition uint32 = 9
+	grpcAborted            uint32 = 10
+	grpcInternal           uint32 = 13
+	grpcUnavailable        uint32 = 14
+	grpcUnauthenticated    uint32 = 16
+)
+
+type kindStatus struct {
+	http int
+	grpc uint32
+}
+
+var kindStatuses = map[Kind]kindStatus{
+	KindInternal:             {http.StatusInternalServerError, grpcInternal},
+	KindInvalid:              {http.StatusBadRequest, grpcInvalidArgument},
+	KindNotFound:             {http.StatusNotFound, grpcNotFound},
+	KindConflict:             {http.StatusConflict, grpcAborted},
+	KindFailedPrecondition:   {http.StatusPreconditionFailed, grpcFailedPrecondition},
+	KindUnprocessable:        {http.StatusUnprocessableEntity, grpcInvalidArgument},
+	KindUnsupportedMediaType: {http.StatusUnsupportedMediaType, grpcInvalidArgument},
+	KindUnauthorized:         {http.StatusUnauthorized, grpcUnauthenticated},
+	KindForbidden:            {http.StatusForbidden, grpcPermissionDenied},
+	KindRateLimited:          {http.StatusTooManyRequests, grpcResourceExhausted},
+	KindUnavailable:          {http.StatusServiceUnavailable, grpcUnavailable},
+}
+
+// HTTPStatus is the HTTP status code for errors of the kind. Unknown kinds are server errors.
+func (k Kind) HTTPStatus() int {
+	if s, ok := kindStatuses[k]; ok {
+		return s.http
+	}
+	return http.StatusInternalServerError
+}
+
+// GRPCCode is the gRPC status code for errors of the kind. Unknown kinds are internal errors.
+func (k Kind) GRPCCode() uint32 {
+	if s, ok := kindStatuses[k]; ok {
+		return s.grpc
+	}
+	return grpcInternal
+}
@@ -0,0 +1,37 @@
+package acme_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/steinfletcher/payments"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError_Status(t *testing.T) {
+	tests := map[string]struct {
+		err        acme.Error
+		httpStatus int
+		grpcCode   uint32
+	}{
+		"not found":              {acme.PaymentNotFound, http.StatusNotFound, 5},
+		"invalid":                {acme.InvalidID, http.StatusBadRequest, 3},
+		"conflict":               {acme.VersionConflict, http.StatusConflict, 10},
+		"failed precondition":    {acme.PreconditionFailed, http.StatusPreconditionFailed, 9},
+		"unprocessable":          {acme.InvalidPatch, http.StatusUnprocessableEntity, 3},
+		"server error":           {acme.ServerError, http.StatusInternalServerError, 13},
+		"without a kind":         {acme.Error{Code: "UNKNOWN"}, http.StatusInternalServerError, 13},
+		"with an unknown kind":   {acme.Error{Code: "UNKNOWN", Kind: acme.Kind(-1)}, http.StatusInternalServerError, 13},
+		"unauthorized":           {acme.Error{Kind: acme.KindUnauthorized}, http.StatusUnauthorized, 16},
+		"forbidden":              {acme.Error{Kind: acme.KindForbidden}, http.StatusForbidden, 7},
+		"rate limited":           {acme.Error{Kind: acme.KindRateLimited}, http.StatusTooManyRequests, 8},
+		"unavailable":            {acme.Error{Kind: acme.KindUnavailable}, http.StatusServiceUnavailable, 14},
+		"unsupported media type": {acme.UnsupportedMediaType, http.StatusUnsupportedMediaType, 3},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.httpStatus, test.err.HTTPStatus())
+			assert.Equal(t, test.grpcCode, test.err.GRPCCode())
+		})
+	}
+}
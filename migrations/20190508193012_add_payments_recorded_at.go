@@ -0,0 +1,21 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(Up20190508193012, Down20190508193012)
+}
+
+// Up20190508193012 records when each version of a payment was stored. Versions that already exist
+// cannot be dated so they take the time of the migration.
+func Up20190508193012(tx *sql.Tx) error {
+	return exec(`ALTER TABLE payments ADD COLUMN recorded_at TIMESTAMPTZ NOT NULL DEFAULT now();`, tx)
+}
+
+func Down20190508193012(tx *sql.Tx) error {
+	return exec("ALTER TABLE payments DROP COLUMN recorded_at;", tx)
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(Up20190515101544, Down20190515101544)
+}
+
+// Up20190515101544 stores the responses to requests made with an idempotency key.
+// The response columns are NULL while the original request is in progress.
+func Up20190515101544(tx *sql.Tx) error {
+	return exec(`CREATE TABLE idempotency_keys
+(
+    organisation_id TEXT        NOT NULL,
+    key             TEXT        NOT NULL,
+    fingerprint     TEXT        NOT NULL,
+    status_code     INT,
+    header          JSON,
+    body            BYTEA,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (organisation_id, key)
+);
+`, tx)
+}
+
+func Down20190515101544(tx *sql.Tx) error {
+	return exec("DROP TABLE idempotency_keys;", tx)
+}
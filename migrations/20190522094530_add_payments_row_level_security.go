@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(Up20190522094530, Down20190522094530)
+}
+
+// Up20190522094530 limits the payments a transaction can see to those of the organisation set in the
+// app.organisation_id setting. A transaction that has not set it sees no payments. Superusers and roles
+// with BYPASSRLS are not subject to the policy.
+func Up20190522094530(tx *sql.Tx) error {
+	return exec(`CREATE INDEX payments_organisation_id ON payments (organisation_id, external_id);
+
+ALTER TABLE payments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE payments FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY payments_organisation ON payments
+    USING (organisation_id = current_setting('app.organisation_id', TRUE));
+`, tx)
+}
+
+func Down20190522094530(tx *sql.Tx) error {
+	return exec(`DROP POLICY payments_organisation ON payments;
+ALTER TABLE payments NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE payments DISABLE ROW LEVEL SECURITY;
+DROP INDEX payments_organisation_id;
+`, tx)
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(Up20190527113208, Down20190527113208)
+}
+
+// Up20190527113208 stores the API keys that clients authenticate with. The secret of a key is only
+// stored as a SHA-256 hash.
+func Up20190527113208(tx *sql.Tx) error {
+	return exec(`CREATE TABLE api_keys
+(
+    id              TEXT PRIMARY KEY NOT NULL,
+    organisation_id TEXT             NOT NULL,
+    name            TEXT             NOT NULL,
+    hash            TEXT             NOT NULL,
+    created_at      TIMESTAMPTZ      NOT NULL DEFAULT now(),
+    revoked_at      TIMESTAMPTZ
+);
+`, tx)
+}
+
+func Down20190527113208(tx *sql.Tx) error {
+	return exec("DROP TABLE api_keys;", tx)
+}
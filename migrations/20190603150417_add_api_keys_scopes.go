@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(Up20190603150417, Down20190603150417)
+}
+
+// Up20190603150417 records the scopes granted to each API key. Keys that already exist keep the access
+// they had, which is every scope.
+func Up20190603150417(tx *sql.Tx) error {
+	return exec(`ALTER TABLE api_keys ADD COLUMN scopes TEXT[] NOT NULL DEFAULT '{}';
+UPDATE api_keys SET scopes = '{payments:read,payments:write,payments:delete}';
+`, tx)
+}
+
+func Down20190603150417(tx *sql.Tx) error {
+	return exec("ALTER TABLE api_keys DROP COLUMN scopes;", tx)
+}
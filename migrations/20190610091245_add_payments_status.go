@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(Up20190610091245, Down20190610091245)
+}
+
+// Up20190610091245 records the lifecycle status of each version of a payment and the client that
+// recorded the version. Payments that already exist are pending.
+func Up20190610091245(tx *sql.Tx) error {
+	return exec(`ALTER TABLE payments ADD COLUMN status TEXT NOT NULL DEFAULT 'pending';
+ALTER TABLE payments ADD COLUMN recorded_by TEXT NOT NULL DEFAULT '';
+`, tx)
+}
+
+func Down20190610091245(tx *sql.Tx) error {
+	return exec(`ALTER TABLE payments DROP COLUMN recorded_by;
+ALTER TABLE payments DROP COLUMN status;
+`, tx)
+}
@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(Up20190617142310, Down20190617142310)
+}
+
+// Up20190617142310 stores the approval thresholds of each organisation and the audit trail of the
+// decisions made by approvers. An approver decides about a version of a payment at most once. Both tables
+// have the same row level security policy as payments.
+func Up20190617142310(tx *sql.Tx) error {
+	return exec(`CREATE TABLE approval_thresholds
+(
+    organisation_id TEXT    NOT NULL,
+    currency        TEXT    NOT NULL,
+    amount          NUMERIC NOT NULL,
+    approvals       INTEGER NOT NULL,
+    PRIMARY KEY (organisation_id, currency)
+);
+
+CREATE TABLE approvals
+(
+    id              SERIAL PRIMARY KEY NOT NULL,
+    organisation_id TEXT               NOT NULL,
+    external_id     TEXT               NOT NULL,
+    payment_version INTEGER            NOT NULL,
+    approver        TEXT               NOT NULL,
+    decision        TEXT               NOT NULL,
+    recorded_at     TIMESTAMPTZ        NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX approvals_decision ON approvals (external_id, payment_version, approver);
+
+ALTER TABLE approval_thresholds ENABLE ROW LEVEL SECURITY;
+ALTER TABLE approval_thresholds FORCE ROW LEVEL SECURITY;
+CREATE POLICY approval_thresholds_organisation ON approval_thresholds
+    USING (organisation_id = current_setting('app.organisation_id', TRUE));
+
+ALTER TABLE approvals ENABLE ROW LEVEL SECURITY;
+ALTER TABLE approvals FORCE ROW LEVEL SECURITY;
+CREATE POLICY approvals_organisation ON approvals
+    USING (organisation_id = current_setting('app.organisation_id', TRUE));
+`, tx)
+}
+
+func Down20190617142310(tx *sql.Tx) error {
+	return exec(`DROP TABLE approvals;
+DROP TABLE approval_thresholds;
+`, tx)
+}
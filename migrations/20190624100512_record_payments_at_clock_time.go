@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(Up20190624100512, Down20190624100512)
+}
+
+// Up20190624100512 dates each version of a payment and each approval by the time it is inserted rather
+// than the time its transaction started, so a version stored after another is never dated before it.
+func Up20190624100512(tx *sql.Tx) error {
+	return exec(`ALTER TABLE payments ALTER COLUMN recorded_at SET DEFAULT clock_timestamp();
+ALTER TABLE approvals ALTER COLUMN recorded_at SET DEFAULT clock_timestamp();`, tx)
+}
+
+func Down20190624100512(tx *sql.Tx) error {
+	return exec(`ALTER TABLE payments ALTER COLUMN recorded_at SET DEFAULT now();
+ALTER TABLE approvals ALTER COLUMN recorded_at SET DEFAULT now();`, tx)
+}
@@ -26,11 +26,11 @@ func NewMockPaymentService(options ...pegomock.Option) *MockPaymentService {
 func (mock *MockPaymentService) SetFailHandler(fh pegomock.FailHandler) { mock.fail = fh }
 func (mock *MockPaymentService) FailHandler() pegomock.FailHandler      { return mock.fail }
 
-func (mock *MockPaymentService) Get(id uuid.UUID) (payments.Payment, error) {
+func (mock *MockPaymentService) Get(organisationID uuid.UUID, id uuid.UUID) (payments.Payment, error) {
 	if mock == nil {
 		panic("mock must not be nil. Use myMock := NewMockPaymentService().")
 	}
-	params := []pegomock.Param{id}
+	params := []pegomock.Param{organisationID, id}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("Get", params, []reflect.Type{reflect.TypeOf((*payments.Payment)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 payments.Payment
 	var ret1 error
@@ -45,11 +45,30 @@ func (mock *MockPaymentService) Get(id uuid.UUID) (payments.Payment, error) {
 	return ret0, ret1
 }
 
-func (mock *MockPaymentService) GetAll() (payments.Payments, error) {
+func (mock *MockPaymentService) GetAsOf(organisationID uuid.UUID, id uuid.UUID, asOf time.Time) (payments.Payment, error) {
 	if mock == nil {
 		panic("mock must not be nil. Use myMock := NewMockPaymentService().")
 	}
-	params := []pegomock.Param{}
+	params := []pegomock.Param{organisationID, id, asOf}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("GetAsOf", params, []reflect.Type{reflect.TypeOf((*payments.Payment)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 payments.Payment
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(payments.Payment)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockPaymentService) GetAll(organisationID uuid.UUID) (payments.Payments, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockPaymentService().")
+	}
+	params := []pegomock.Param{organisationID}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("GetAll", params, []reflect.Type{reflect.TypeOf((*payments.Payments)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 payments.Payments
 	var ret1 error
@@ -64,11 +83,87 @@ func (mock *MockPaymentService) GetAll() (payments.Payments, error) {
 	return ret0, ret1
 }
 
-func (mock *MockPaymentService) Delete(id uuid.UUID) error {
+func (mock *MockPaymentService) List(organisationID uuid.UUID, query payments.PaymentQuery) (payments.PaymentPage, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockPaymentService().")
+	}
+	params := []pegomock.Param{organisationID, query}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("List", params, []reflect.Type{reflect.TypeOf((*payments.PaymentPage)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 payments.PaymentPage
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(payments.PaymentPage)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockPaymentService) GetVersions(organisationID uuid.UUID, id uuid.UUID) ([]payments.Payment, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockPaymentService().")
+	}
+	params := []pegomock.Param{organisationID, id}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("GetVersions", params, []reflect.Type{reflect.TypeOf((*[]payments.Payment)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 []payments.Payment
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].([]payments.Payment)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockPaymentService) GetVersion(organisationID uuid.UUID, id uuid.UUID, version int) (payments.Payment, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockPaymentService().")
+	}
+	params := []pegomock.Param{organisationID, id, version}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("GetVersion", params, []reflect.Type{reflect.TypeOf((*payments.Payment)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 payments.Payment
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(payments.Payment)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockPaymentService) Diff(organisationID uuid.UUID, id uuid.UUID, from int, to int) (payments.Changes, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockPaymentService().")
+	}
+	params := []pegomock.Param{organisationID, id, from, to}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("Diff", params, []reflect.Type{reflect.TypeOf((*payments.Changes)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 payments.Changes
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(payments.Changes)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockPaymentService) Delete(organisationID uuid.UUID, id uuid.UUID, version int, recordedBy string) error {
 	if mock == nil {
 		panic("mock must not be nil. Use myMock := NewMockPaymentService().")
 	}
-	params := []pegomock.Param{id}
+	params := []pegomock.Param{organisationID, id, version, recordedBy}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("Delete", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 error
 	if len(result) != 0 {
@@ -79,26 +174,30 @@ func (mock *MockPaymentService) Delete(id uuid.UUID) error {
 	return ret0
 }
 
-func (mock *MockPaymentService) Update(id uuid.UUID, payment payments.Payment) error {
+func (mock *MockPaymentService) Update(organisationID uuid.UUID, id uuid.UUID, payment payments.Payment) (int, error) {
 	if mock == nil {
 		panic("mock must not be nil. Use myMock := NewMockPaymentService().")
 	}
-	params := []pegomock.Param{id, payment}
-	result := pegomock.GetGenericMockFrom(mock).Invoke("Update", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
-	var ret0 error
+	params := []pegomock.Param{organisationID, id, payment}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("Update", params, []reflect.Type{reflect.TypeOf((*int)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 int
+	var ret1 error
 	if len(result) != 0 {
 		if result[0] != nil {
-			ret0 = result[0].(error)
+			ret0 = result[0].(int)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
 		}
 	}
-	return ret0
+	return ret0, ret1
 }
 
-func (mock *MockPaymentService) Create(payment payments.Payment) (uuid.UUID, error) {
+func (mock *MockPaymentService) Create(organisationID uuid.UUID, payment payments.Payment) (uuid.UUID, error) {
 	if mock == nil {
 		panic("mock must not be nil. Use myMock := NewMockPaymentService().")
 	}
-	params := []pegomock.Param{payment}
+	params := []pegomock.Param{organisationID, payment}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("Create", params, []reflect.Type{reflect.TypeOf((*uuid.UUID)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 uuid.UUID
 	var ret1 error
@@ -113,6 +212,25 @@ func (mock *MockPaymentService) Create(payment payments.Payment) (uuid.UUID, err
 	return ret0, ret1
 }
 
+func (mock *MockPaymentService) Transition(organisationID uuid.UUID, id uuid.UUID, action payments.Action, version int, recordedBy string) (payments.Payment, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockPaymentService().")
+	}
+	params := []pegomock.Param{organisationID, id, action, version, recordedBy}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("Transition", params, []reflect.Type{reflect.TypeOf((*payments.Payment)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 payments.Payment
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(payments.Payment)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
 func (mock *MockPaymentService) VerifyWasCalledOnce() *VerifierMockPaymentService {
 	return &VerifierMockPaymentService{
 		mock:                   mock,
@@ -150,8 +268,8 @@ type VerifierMockPaymentService struct {
 	timeout                time.Duration
 }
 
-func (verifier *VerifierMockPaymentService) Get(id uuid.UUID) *MockPaymentService_Get_OngoingVerification {
-	params := []pegomock.Param{id}
+func (verifier *VerifierMockPaymentService) Get(organisationID uuid.UUID, id uuid.UUID) *MockPaymentService_Get_OngoingVerification {
+	params := []pegomock.Param{organisationID, id}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "Get", params, verifier.timeout)
 	return &MockPaymentService_Get_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -161,24 +279,63 @@ type MockPaymentService_Get_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *MockPaymentService_Get_OngoingVerification) GetCapturedArguments() uuid.UUID {
-	id := c.GetAllCapturedArguments()
-	return id[len(id)-1]
+func (c *MockPaymentService_Get_OngoingVerification) GetCapturedArguments() (uuid.UUID, uuid.UUID) {
+	organisationID, id := c.GetAllCapturedArguments()
+	return organisationID[len(organisationID)-1], id[len(id)-1]
 }
 
-func (c *MockPaymentService_Get_OngoingVerification) GetAllCapturedArguments() (_param0 []uuid.UUID) {
+func (c *MockPaymentService_Get_OngoingVerification) GetAllCapturedArguments() (_param0 []uuid.UUID, _param1 []uuid.UUID) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
 		_param0 = make([]uuid.UUID, len(params[0]))
 		for u, param := range params[0] {
 			_param0[u] = param.(uuid.UUID)
 		}
+		_param1 = make([]uuid.UUID, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(uuid.UUID)
+		}
 	}
 	return
 }
 
-func (verifier *VerifierMockPaymentService) GetAll() *MockPaymentService_GetAll_OngoingVerification {
-	params := []pegomock.Param{}
+func (verifier *VerifierMockPaymentService) GetAsOf(organisationID uuid.UUID, id uuid.UUID, asOf time.Time) *MockPaymentService_GetAsOf_OngoingVerification {
+	params := []pegomock.Param{organisationID, id, asOf}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetAsOf", params, verifier.timeout)
+	return &MockPaymentService_GetAsOf_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockPaymentService_GetAsOf_OngoingVerification struct {
+	mock              *MockPaymentService
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockPaymentService_GetAsOf_OngoingVerification) GetCapturedArguments() (uuid.UUID, uuid.UUID, time.Time) {
+	organisationID, id, asOf := c.GetAllCapturedArguments()
+	return organisationID[len(organisationID)-1], id[len(id)-1], asOf[len(asOf)-1]
+}
+
+func (c *MockPaymentService_GetAsOf_OngoingVerification) GetAllCapturedArguments() (_param0 []uuid.UUID, _param1 []uuid.UUID, _param2 []time.Time) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]uuid.UUID, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(uuid.UUID)
+		}
+		_param1 = make([]uuid.UUID, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(uuid.UUID)
+		}
+		_param2 = make([]time.Time, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(time.Time)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierMockPaymentService) GetAll(organisationID uuid.UUID) *MockPaymentService_GetAll_OngoingVerification {
+	params := []pegomock.Param{organisationID}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetAll", params, verifier.timeout)
 	return &MockPaymentService_GetAll_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -188,14 +345,160 @@ type MockPaymentService_GetAll_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *MockPaymentService_GetAll_OngoingVerification) GetCapturedArguments() {
+func (c *MockPaymentService_GetAll_OngoingVerification) GetCapturedArguments() uuid.UUID {
+	organisationID := c.GetAllCapturedArguments()
+	return organisationID[len(organisationID)-1]
+}
+
+func (c *MockPaymentService_GetAll_OngoingVerification) GetAllCapturedArguments() (_param0 []uuid.UUID) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]uuid.UUID, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(uuid.UUID)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierMockPaymentService) List(organisationID uuid.UUID, query payments.PaymentQuery) *MockPaymentService_List_OngoingVerification {
+	params := []pegomock.Param{organisationID, query}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "List", params, verifier.timeout)
+	return &MockPaymentService_List_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockPaymentService_List_OngoingVerification struct {
+	mock              *MockPaymentService
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockPaymentService_List_OngoingVerification) GetCapturedArguments() (uuid.UUID, payments.PaymentQuery) {
+	organisationID, query := c.GetAllCapturedArguments()
+	return organisationID[len(organisationID)-1], query[len(query)-1]
+}
+
+func (c *MockPaymentService_List_OngoingVerification) GetAllCapturedArguments() (_param0 []uuid.UUID, _param1 []payments.PaymentQuery) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]uuid.UUID, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(uuid.UUID)
+		}
+		_param1 = make([]payments.PaymentQuery, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(payments.PaymentQuery)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierMockPaymentService) GetVersions(organisationID uuid.UUID, id uuid.UUID) *MockPaymentService_GetVersions_OngoingVerification {
+	params := []pegomock.Param{organisationID, id}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetVersions", params, verifier.timeout)
+	return &MockPaymentService_GetVersions_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockPaymentService_GetVersions_OngoingVerification struct {
+	mock              *MockPaymentService
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockPaymentService_GetVersions_OngoingVerification) GetCapturedArguments() (uuid.UUID, uuid.UUID) {
+	organisationID, id := c.GetAllCapturedArguments()
+	return organisationID[len(organisationID)-1], id[len(id)-1]
+}
+
+func (c *MockPaymentService_GetVersions_OngoingVerification) GetAllCapturedArguments() (_param0 []uuid.UUID, _param1 []uuid.UUID) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]uuid.UUID, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(uuid.UUID)
+		}
+		_param1 = make([]uuid.UUID, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(uuid.UUID)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierMockPaymentService) GetVersion(organisationID uuid.UUID, id uuid.UUID, version int) *MockPaymentService_GetVersion_OngoingVerification {
+	params := []pegomock.Param{organisationID, id, version}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetVersion", params, verifier.timeout)
+	return &MockPaymentService_GetVersion_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockPaymentService_GetVersion_OngoingVerification struct {
+	mock              *MockPaymentService
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockPaymentService_GetVersion_OngoingVerification) GetCapturedArguments() (uuid.UUID, uuid.UUID, int) {
+	organisationID, id, version := c.GetAllCapturedArguments()
+	return organisationID[len(organisationID)-1], id[len(id)-1], version[len(version)-1]
+}
+
+func (c *MockPaymentService_GetVersion_OngoingVerification) GetAllCapturedArguments() (_param0 []uuid.UUID, _param1 []uuid.UUID, _param2 []int) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]uuid.UUID, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(uuid.UUID)
+		}
+		_param1 = make([]uuid.UUID, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(uuid.UUID)
+		}
+		_param2 = make([]int, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(int)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierMockPaymentService) Diff(organisationID uuid.UUID, id uuid.UUID, from int, to int) *MockPaymentService_Diff_OngoingVerification {
+	params := []pegomock.Param{organisationID, id, from, to}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "Diff", params, verifier.timeout)
+	return &MockPaymentService_Diff_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockPaymentService_Diff_OngoingVerification struct {
+	mock              *MockPaymentService
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockPaymentService_Diff_OngoingVerification) GetCapturedArguments() (uuid.UUID, uuid.UUID, int, int) {
+	organisationID, id, from, to := c.GetAllCapturedArguments()
+	return organisationID[len(organisationID)-1], id[len(id)-1], from[len(from)-1], to[len(to)-1]
 }
 
-func (c *MockPaymentService_GetAll_OngoingVerification) GetAllCapturedArguments() {
+func (c *MockPaymentService_Diff_OngoingVerification) GetAllCapturedArguments() (_param0 []uuid.UUID, _param1 []uuid.UUID, _param2 []int, _param3 []int) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]uuid.UUID, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(uuid.UUID)
+		}
+		_param1 = make([]uuid.UUID, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(uuid.UUID)
+		}
+		_param2 = make([]int, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(int)
+		}
+		_param3 = make([]int, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(int)
+		}
+	}
+	return
 }
 
-func (verifier *VerifierMockPaymentService) Delete(id uuid.UUID) *MockPaymentService_Delete_OngoingVerification {
-	params := []pegomock.Param{id}
+func (verifier *VerifierMockPaymentService) Delete(organisationID uuid.UUID, id uuid.UUID, version int, recordedBy string) *MockPaymentService_Delete_OngoingVerification {
+	params := []pegomock.Param{organisationID, id, version, recordedBy}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "Delete", params, verifier.timeout)
 	return &MockPaymentService_Delete_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -205,24 +508,36 @@ type MockPaymentService_Delete_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *MockPaymentService_Delete_OngoingVerification) GetCapturedArguments() uuid.UUID {
-	id := c.GetAllCapturedArguments()
-	return id[len(id)-1]
+func (c *MockPaymentService_Delete_OngoingVerification) GetCapturedArguments() (uuid.UUID, uuid.UUID, int, string) {
+	organisationID, id, version, recordedBy := c.GetAllCapturedArguments()
+	return organisationID[len(organisationID)-1], id[len(id)-1], version[len(version)-1], recordedBy[len(recordedBy)-1]
 }
 
-func (c *MockPaymentService_Delete_OngoingVerification) GetAllCapturedArguments() (_param0 []uuid.UUID) {
+func (c *MockPaymentService_Delete_OngoingVerification) GetAllCapturedArguments() (_param0 []uuid.UUID, _param1 []uuid.UUID, _param2 []int, _param3 []string) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
 		_param0 = make([]uuid.UUID, len(params[0]))
 		for u, param := range params[0] {
 			_param0[u] = param.(uuid.UUID)
 		}
+		_param1 = make([]uuid.UUID, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(uuid.UUID)
+		}
+		_param2 = make([]int, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(int)
+		}
+		_param3 = make([]string, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(string)
+		}
 	}
 	return
 }
 
-func (verifier *VerifierMockPaymentService) Update(id uuid.UUID, payment payments.Payment) *MockPaymentService_Update_OngoingVerification {
-	params := []pegomock.Param{id, payment}
+func (verifier *VerifierMockPaymentService) Update(organisationID uuid.UUID, id uuid.UUID, payment payments.Payment) *MockPaymentService_Update_OngoingVerification {
+	params := []pegomock.Param{organisationID, id, payment}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "Update", params, verifier.timeout)
 	return &MockPaymentService_Update_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -232,28 +547,32 @@ type MockPaymentService_Update_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *MockPaymentService_Update_OngoingVerification) GetCapturedArguments() (uuid.UUID, payments.Payment) {
-	id, payment := c.GetAllCapturedArguments()
-	return id[len(id)-1], payment[len(payment)-1]
+func (c *MockPaymentService_Update_OngoingVerification) GetCapturedArguments() (uuid.UUID, uuid.UUID, payments.Payment) {
+	organisationID, id, payment := c.GetAllCapturedArguments()
+	return organisationID[len(organisationID)-1], id[len(id)-1], payment[len(payment)-1]
 }
 
-func (c *MockPaymentService_Update_OngoingVerification) GetAllCapturedArguments() (_param0 []uuid.UUID, _param1 []payments.Payment) {
+func (c *MockPaymentService_Update_OngoingVerification) GetAllCapturedArguments() (_param0 []uuid.UUID, _param1 []uuid.UUID, _param2 []payments.Payment) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
 		_param0 = make([]uuid.UUID, len(params[0]))
 		for u, param := range params[0] {
 			_param0[u] = param.(uuid.UUID)
 		}
-		_param1 = make([]payments.Payment, len(params[1]))
+		_param1 = make([]uuid.UUID, len(params[1]))
 		for u, param := range params[1] {
-			_param1[u] = param.(payments.Payment)
+			_param1[u] = param.(uuid.UUID)
+		}
+		_param2 = make([]payments.Payment, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(payments.Payment)
 		}
 	}
 	return
 }
 
-func (verifier *VerifierMockPaymentService) Create(payment payments.Payment) *MockPaymentService_Create_OngoingVerification {
-	params := []pegomock.Param{payment}
+func (verifier *VerifierMockPaymentService) Create(organisationID uuid.UUID, payment payments.Payment) *MockPaymentService_Create_OngoingVerification {
+	params := []pegomock.Param{organisationID, payment}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "Create", params, verifier.timeout)
 	return &MockPaymentService_Create_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -263,17 +582,64 @@ type MockPaymentService_Create_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *MockPaymentService_Create_OngoingVerification) GetCapturedArguments() payments.Payment {
-	payment := c.GetAllCapturedArguments()
-	return payment[len(payment)-1]
+func (c *MockPaymentService_Create_OngoingVerification) GetCapturedArguments() (uuid.UUID, payments.Payment) {
+	organisationID, payment := c.GetAllCapturedArguments()
+	return organisationID[len(organisationID)-1], payment[len(payment)-1]
 }
 
-func (c *MockPaymentService_Create_OngoingVerification) GetAllCapturedArguments() (_param0 []payments.Payment) {
+func (c *MockPaymentService_Create_OngoingVerification) GetAllCapturedArguments() (_param0 []uuid.UUID, _param1 []payments.Payment) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
-		_param0 = make([]payments.Payment, len(params[0]))
+		_param0 = make([]uuid.UUID, len(params[0]))
 		for u, param := range params[0] {
-			_param0[u] = param.(payments.Payment)
+			_param0[u] = param.(uuid.UUID)
+		}
+		_param1 = make([]payments.Payment, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(payments.Payment)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierMockPaymentService) Transition(organisationID uuid.UUID, id uuid.UUID, action payments.Action, version int, recordedBy string) *MockPaymentService_Transition_OngoingVerification {
+	params := []pegomock.Param{organisationID, id, action, version, recordedBy}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "Transition", params, verifier.timeout)
+	return &MockPaymentService_Transition_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type MockPaymentService_Transition_OngoingVerification struct {
+	mock              *MockPaymentService
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *MockPaymentService_Transition_OngoingVerification) GetCapturedArguments() (uuid.UUID, uuid.UUID, payments.Action, int, string) {
+	organisationID, id, action, version, recordedBy := c.GetAllCapturedArguments()
+	return organisationID[len(organisationID)-1], id[len(id)-1], action[len(action)-1], version[len(version)-1], recordedBy[len(recordedBy)-1]
+}
+
+func (c *MockPaymentService_Transition_OngoingVerification) GetAllCapturedArguments() (_param0 []uuid.UUID, _param1 []uuid.UUID, _param2 []payments.Action, _param3 []int, _param4 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]uuid.UUID, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(uuid.UUID)
+		}
+		_param1 = make([]uuid.UUID, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(uuid.UUID)
+		}
+		_param2 = make([]payments.Action, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(payments.Action)
+		}
+		_param3 = make([]int, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(int)
+		}
+		_param4 = make([]string, len(params[4]))
+		for u, param := range params[4] {
+			_param4[u] = param.(string)
 		}
 	}
 	return
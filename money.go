@@ -0,0 +1,295 @@
+package acme
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+var decimalPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// Decimal is an exact base 10 number with the value coefficient * 10^-scale.
+// The scale is kept as parsed so "2.00000" formats as "2.00000". Use Cmp to compare values,
+// since 1.0 and 1.00 are equal numbers but different Decimals.
+type Decimal struct {
+	coefficient int64
+	scale       int
+}
+
+// NewDecimal creates the decimal coefficient * 10^-scale, e.g. NewDecimal(10021, 2) is 100.21.
+// The scale must not be negative.
+func NewDecimal(coefficient int64, scale int) Decimal {
+	return Decimal{coefficient: coefficient, scale: scale}
+}
+
+// ParseDecimal parses a plain decimal string such as "100.21" or "-5".
+// Exponents, leading plus signs and missing integer digits (".5") are rejected.
+func ParseDecimal(s string) (Decimal, error) {
+	if !decimalPattern.MatchString(s) {
+		return Decimal{}, fmt.Errorf("%q is not a decimal number", s)
+	}
+
+	scale := 0
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		scale = len(s) - i - 1
+		s = s[:i] + s[i+1:]
+	}
+
+	coefficient, ok := new(big.Int).SetString(s, 10)
+	if !ok || !coefficient.IsInt64() {
+		return Decimal{}, fmt.Errorf("%q is out of range", s)
+	}
+	return Decimal{coefficient: coefficient.Int64(), scale: scale}, nil
+}
+
+// Scale is the number of digits after the decimal point
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of d
+func (d Decimal) Sign() int {
+	switch {
+	case d.coefficient < 0:
+		return -1
+	case d.coefficient > 0:
+		return 1
+	}
+	return 0
+}
+
+func (d Decimal) IsZero() bool {
+	return d.coefficient == 0
+}
+
+func (d Decimal) Add(o Decimal) (Decimal, error) {
+	a, b, scale := align(d, o)
+	return fromBig(a.Add(a, b), scale)
+}
+
+func (d Decimal) Sub(o Decimal) (Decimal, error) {
+	a, b, scale := align(d, o)
+	return fromBig(a.Sub(a, b), scale)
+}
+
+func (d Decimal) Mul(o Decimal) (Decimal, error) {
+	product := new(big.Int).Mul(big.NewInt(d.coefficient), big.NewInt(o.coefficient))
+	return fromBig(product, d.scale+o.scale)
+}
+
+// Cmp compares the values of d and o and returns -1, 0 or +1
+func (d Decimal) Cmp(o Decimal) int {
+	a, b, _ := align(d, o)
+	return a.Cmp(b)
+}
+
+// Round rounds d to at most places digits after the decimal point using banker's rounding
+// (round half to even). Decimals that already fit are returned unchanged.
+func (d Decimal) Round(places int) Decimal {
+	if places < 0 {
+		places = 0
+	}
+	if d.scale <= places {
+		return d
+	}
+
+	divisor := pow10(d.scale - places)
+	quotient, remainder := new(big.Int).QuoRem(big.NewInt(d.coefficient), divisor, new(big.Int))
+
+	// compare twice the remainder with the divisor to find which way to round
+	half := new(big.Int).Abs(remainder)
+	half.Lsh(half, 1)
+	if c := half.Cmp(divisor); c > 0 || (c == 0 && quotient.Bit(0) == 1) {
+		quotient.Add(quotient, big.NewInt(int64(d.Sign())))
+	}
+	// the quotient is never larger in magnitude than the original coefficient
+	return Decimal{coefficient: quotient.Int64(), scale: places}
+}
+
+// StringFixed formats d rounded or zero padded to exactly places digits after the decimal point
+func (d Decimal) StringFixed(places int) string {
+	s := d.Round(places).String()
+	if places <= 0 {
+		return s
+	}
+
+	scale := 0
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		scale = len(s) - i - 1
+	} else {
+		s += "."
+	}
+	return s + strings.Repeat("0", places-scale)
+}
+
+func (d Decimal) String() string {
+	digits := big.NewInt(d.coefficient)
+	sign := ""
+	if digits.Sign() < 0 {
+		sign = "-"
+		digits.Abs(digits)
+	}
+
+	s := digits.String()
+	if d.scale == 0 {
+		return sign + s
+	}
+	if len(s) <= d.scale {
+		s = strings.Repeat("0", d.scale-len(s)+1) + s
+	}
+	return sign + s[:len(s)-d.scale] + "." + s[len(s)-d.scale:]
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	*d, err = ParseDecimal(s)
+	return err
+}
+
+// Money is an amount in a currency. The amount never has more decimal places than the
+// currency's minor unit allows.
+type Money struct {
+	Amount   Decimal
+	Currency string
+}
+
+// ParseMoney parses an amount such as "100.21" in the given currency
+func ParseMoney(amount, currency string) (Money, error) {
+	d, err := ParseDecimal(amount)
+	if err != nil {
+		return Money{}, err
+	}
+	return NewMoney(d, currency)
+}
+
+// NewMoney creates money from a decimal amount, rejecting currencies that are not in ISO 4217 and
+// amounts that are more precise than the currency's minor unit
+func NewMoney(amount Decimal, currency string) (Money, error) {
+	if err := CheckCurrency(currency); err != nil {
+		return Money{}, err
+	}
+	places := MinorUnits(currency)
+	if amount.Scale() > places {
+		return Money{}, fmt.Errorf("%s amounts have at most %d decimal places", currency, places)
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// CheckMinorUnits checks that the amount has exactly as many decimal places as the currency's minor
+// unit, as the amounts of a payment must, e.g. "100.00" in GBP and "100" in JPY
+func (m Money) CheckMinorUnits() error {
+	places := MinorUnits(m.Currency)
+	if m.Amount.Scale() != places {
+		return fmt.Errorf("%s amounts have exactly %d decimal places", m.Currency, places)
+	}
+	return nil
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, currencyMismatch(m, o)
+	}
+	amount, err := m.Amount.Add(o.Amount)
+	return Money{Amount: amount, Currency: m.Currency}, err
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, currencyMismatch(m, o)
+	}
+	amount, err := m.Amount.Sub(o.Amount)
+	return Money{Amount: amount, Currency: m.Currency}, err
+}
+
+// Cmp compares two amounts in the same currency and returns -1, 0 or +1
+func (m Money) Cmp(o Money) (int, error) {
+	if m.Currency != o.Currency {
+		return 0, currencyMismatch(m, o)
+	}
+	return m.Amount.Cmp(o.Amount), nil
+}
+
+// Convert converts m into currency at the given exchange rate, rounding half to even
+// to the minor unit of the target currency
+func (m Money) Convert(rate Decimal, currency string) (Money, error) {
+	if err := CheckCurrency(currency); err != nil {
+		return Money{}, err
+	}
+	amount, err := m.Amount.Mul(rate)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: amount.Round(MinorUnits(currency)), Currency: currency}, nil
+}
+
+// MinorAmount is the amount expressed in the currency's minor unit, e.g. pence for GBP
+func (m Money) MinorAmount() (int64, error) {
+	places := MinorUnits(m.Currency)
+	minor, err := fromBig(rescale(m.Amount.Round(places), places), 0)
+	return minor.coefficient, err
+}
+
+// String formats the amount with the currency's minor unit, e.g. "100.20 GBP"
+func (m Money) String() string {
+	return m.Amount.StringFixed(MinorUnits(m.Currency)) + " " + m.Currency
+}
+
+// Money is the payment amount in the payment currency
+func (a Attributes) Money() (Money, error) {
+	return ParseMoney(a.Amount, a.Currency)
+}
+
+func (c Charge) Money() (Money, error) {
+	return ParseMoney(c.Amount, c.Currency)
+}
+
+// ReceiverCharges is the amount charged to the receiver
+func (c ChargesInformation) ReceiverCharges() (Money, error) {
+	return ParseMoney(c.ReceiverChargesAmount, c.ReceiverChargesCurrency)
+}
+
+// OriginalMoney is the amount in the original currency before conversion
+func (f FX) OriginalMoney() (Money, error) {
+	return ParseMoney(f.OriginalAmount, f.OriginalCurrency)
+}
+
+func (f FX) Rate() (Decimal, error) {
+	return ParseDecimal(f.ExchangeRate)
+}
+
+func currencyMismatch(a, b Money) error {
+	return fmt.Errorf("cannot combine %s and %s amounts", a.Currency, b.Currency)
+}
+
+func align(a, b Decimal) (*big.Int, *big.Int, int) {
+	scale := a.scale
+	if b.scale > scale {
+		scale = b.scale
+	}
+	return rescale(a, scale), rescale(b, scale), scale
+}
+
+func rescale(d Decimal, scale int) *big.Int {
+	return new(big.Int).Mul(big.NewInt(d.coefficient), pow10(scale-d.scale))
+}
+
+func fromBig(coefficient *big.Int, scale int) (Decimal, error) {
+	if !coefficient.IsInt64() {
+		return Decimal{}, fmt.Errorf("decimal overflow")
+	}
+	return Decimal{coefficient: coefficient.Int64(), scale: scale}, nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
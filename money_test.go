@@ -0,0 +1,167 @@
+package acme_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/steinfletcher/payments"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := map[string]string{
+		"100.21":  "100.21",
+		"2.00000": "2.00000",
+		"-5":      "-5",
+		"0.05":    "0.05",
+		"-0.5":    "-0.5",
+	}
+	for input, expected := range tests {
+		d, err := acme.ParseDecimal(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, d.String())
+	}
+}
+
+func TestParseDecimal_RejectsInvalidInput(t *testing.T) {
+	for _, input := range []string{"", "abc", "1e9", "+1", ".5", "1.", "1,000.00", "99999999999999999999"} {
+		_, err := acme.ParseDecimal(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestDecimal_Arithmetic(t *testing.T) {
+	a, _ := acme.ParseDecimal("100.21")
+	b, _ := acme.ParseDecimal("0.1")
+
+	sum, err := a.Add(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "100.31", sum.String())
+
+	difference, err := b.Sub(a)
+	assert.NoError(t, err)
+	assert.Equal(t, "-100.11", difference.String())
+
+	product, err := a.Mul(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.021", product.String())
+
+	assert.Equal(t, 1, a.Cmp(b))
+	assert.Equal(t, 0, acme.NewDecimal(10, 1).Cmp(acme.NewDecimal(100, 2)))
+}
+
+func TestDecimal_Overflow(t *testing.T) {
+	max := acme.NewDecimal(9223372036854775807, 0)
+
+	_, err := max.Add(acme.NewDecimal(1, 0))
+
+	assert.Error(t, err)
+}
+
+func TestDecimal_RoundsHalfToEven(t *testing.T) {
+	tests := map[string]string{
+		"1.005":  "1.00",
+		"1.015":  "1.02",
+		"1.0151": "1.02",
+		"-1.005": "-1.00",
+		"-1.015": "-1.02",
+		"1.5":    "1.5",
+	}
+	for input, expected := range tests {
+		d, _ := acme.ParseDecimal(input)
+		assert.Equal(t, expected, d.Round(2).String(), input)
+	}
+}
+
+func TestDecimal_StringFixed(t *testing.T) {
+	d, _ := acme.ParseDecimal("100.2")
+
+	assert.Equal(t, "100.20", d.StringFixed(2))
+	assert.Equal(t, "100", d.StringFixed(0))
+	assert.Equal(t, "100.200", d.StringFixed(3))
+}
+
+func TestParseMoney_EnforcesMinorUnits(t *testing.T) {
+	_, err := acme.ParseMoney("100.211", "GBP")
+	assert.EqualError(t, err, "GBP amounts have at most 2 decimal places")
+
+	_, err = acme.ParseMoney("100.5", "JPY")
+	assert.EqualError(t, err, "JPY amounts have at most 0 decimal places")
+
+	m, err := acme.ParseMoney("100.211", "BHD")
+	assert.NoError(t, err)
+	assert.Equal(t, "100.211 BHD", m.String())
+}
+
+func TestParseMoney_RejectsUnknownCurrencies(t *testing.T) {
+	_, err := acme.ParseMoney("100.21", "XYZ")
+	assert.EqualError(t, err, "XYZ is not an ISO 4217 currency code")
+
+	_, err = acme.ParseMoney("100.21", "gbp")
+	assert.EqualError(t, err, "gbp is not an ISO 4217 currency code")
+}
+
+func TestMoney_CheckMinorUnits(t *testing.T) {
+	tests := map[string]string{
+		"100.21 GBP":   "",
+		"100.2 GBP":    "GBP amounts have exactly 2 decimal places",
+		"100 GBP":      "GBP amounts have exactly 2 decimal places",
+		"100 JPY":      "",
+		"100.211 BHD":  "",
+		"100.21 BHD":   "BHD amounts have exactly 3 decimal places",
+		"1.2345 CLF":   "",
+		"100.00 EUR":   "",
+		"100.000 KWD":  "",
+		"100.0000 UYW": "",
+	}
+	for input, message := range tests {
+		fields := strings.Fields(input)
+		m, err := acme.ParseMoney(fields[0], fields[1])
+		if !assert.NoError(t, err, input) {
+			continue
+		}
+		if message == "" {
+			assert.NoError(t, m.CheckMinorUnits(), input)
+		} else {
+			assert.EqualError(t, m.CheckMinorUnits(), message, input)
+		}
+	}
+}
+
+func TestMoney_Add(t *testing.T) {
+	a, _ := acme.ParseMoney("5.00", "GBP")
+	b, _ := acme.ParseMoney("10.5", "GBP")
+
+	sum, err := a.Add(b)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "15.50 GBP", sum.String())
+}
+
+func TestMoney_AddRejectsDifferentCurrencies(t *testing.T) {
+	a, _ := acme.ParseMoney("5.00", "GBP")
+	b, _ := acme.ParseMoney("10.00", "USD")
+
+	_, err := a.Add(b)
+
+	assert.EqualError(t, err, "cannot combine GBP and USD amounts")
+}
+
+func TestMoney_Convert(t *testing.T) {
+	m, _ := acme.ParseMoney("100.21", "GBP")
+	rate, _ := acme.ParseDecimal("1.23456")
+
+	converted, err := m.Convert(rate, "JPY")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "124 JPY", converted.String())
+}
+
+func TestMoney_MinorAmount(t *testing.T) {
+	m, _ := acme.ParseMoney("100.2", "GBP")
+
+	minor, err := m.MinorAmount()
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10020), minor)
+}
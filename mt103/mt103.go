@@ -0,0 +1,109 @@
+// Package mt103 converts payments to and from SWIFT MT103 single customer credit transfers. A message is
+// read from its text block, with or without the headers of a FIN message, and written as a text block.
+package mt103
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Field is a field of the text block, e.g. 32A and its value. The lines of a value are separated by \n.
+type Field struct {
+	Tag   string
+	Value string
+}
+
+// Message is an MT103 as the fields of its text block, in order
+type Message struct {
+	// Sender is the BIC of the sender in the basic header block, if the message has one
+	Sender string
+	Fields []Field
+}
+
+var (
+	fieldPattern = regexp.MustCompile(`^:([0-9]{2}[A-Z]?):(.*)$`)
+	// basicHeaderPattern matches the application ID, the service ID and the logical terminal address of
+	// the sender, which is the BIC of the sender with a terminal code after its first 8 characters
+	basicHeaderPattern = regexp.MustCompile(`\{1:F01([A-Z0-9]{8})[A-Z0-9]([A-Z0-9]{3})`)
+	// applicationHeaderPattern matches the message type of an input or an output application header
+	applicationHeaderPattern = regexp.MustCompile(`\{2:[IO]([0-9]{3})`)
+)
+
+// Parse reads the fields of an MT103. The text is a FIN message or only its text block, with or without
+// the {4: and -} that delimit it.
+func Parse(text string) (Message, error) {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+
+	if header := applicationHeaderPattern.FindStringSubmatch(text); header != nil && header[1] != "103" {
+		return Message{}, fmt.Errorf("the message is an MT%s, not an MT103", header[1])
+	}
+
+	var m Message
+	if header := basicHeaderPattern.FindStringSubmatch(text); header != nil {
+		m.Sender = header[1]
+		if header[2] != "XXX" {
+			m.Sender += header[2]
+		}
+	}
+	if start := strings.Index(text, "{4:"); start >= 0 {
+		end := strings.Index(text[start:], "\n-}")
+		if end < 0 {
+			return Message{}, fmt.Errorf("the text block is not terminated by -}")
+		}
+		text = text[start+len("{4:") : start+end]
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Message{}, fmt.Errorf("the message has no fields")
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if match := fieldPattern.FindStringSubmatch(line); match != nil {
+			m.Fields = append(m.Fields, Field{Tag: match[1], Value: match[2]})
+			continue
+		}
+		if line == "-" {
+			break
+		}
+		if len(m.Fields) == 0 {
+			return Message{}, fmt.Errorf("the text block must start with a field")
+		}
+		m.Fields[len(m.Fields)-1].Value += "\n" + line
+	}
+	return m, nil
+}
+
+// Get is the value of the first field with one of the tags, and the tag of that field
+func (m Message) Get(tags ...string) (string, string, bool) {
+	for _, f := range m.Fields {
+		for _, tag := range tags {
+			if f.Tag == tag {
+				return f.Value, f.Tag, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// GetAll is the values of every field with the tag, in order
+func (m Message) GetAll(tag string) []string {
+	var values []string
+	for _, f := range m.Fields {
+		if f.Tag == tag {
+			values = append(values, f.Value)
+		}
+	}
+	return values
+}
+
+// String writes the text block of the message with the CRLF line endings of FIN messages
+func (m Message) String() string {
+	var b strings.Builder
+	b.WriteString("{4:\r\n")
+	for _, f := range m.Fields {
+		b.WriteString(":" + f.Tag + ":" + strings.Replace(f.Value, "\n", "\r\n", -1) + "\r\n")
+	}
+	b.WriteString("-}")
+	return b.String()
+}
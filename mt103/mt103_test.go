@@ -0,0 +1,282 @@
+package mt103_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/steinfletcher/payments"
+	"github.com/steinfletcher/payments/mt103"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	m, err := mt103.Parse(readFile(t, "testdata/mt103.txt"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "BARCGB22", m.Sender)
+	assert.Len(t, m.Fields, 12)
+	assert.Equal(t, mt103.Field{Tag: "20", Value: "Wil piano Jan"}, m.Fields[0])
+	value, tag, ok := m.Get("57A", "57C")
+	assert.True(t, ok)
+	assert.Equal(t, "57C", tag)
+	assert.Equal(t, "//SC403000", value)
+	assert.Equal(t, "/31926819\nWilfred Jeremiah Owens\n1 The Beneficiary Localtown SE2", m.Fields[8].Value)
+}
+
+func TestParse_TextBlock(t *testing.T) {
+	m, err := mt103.Parse(":20:REF\r\n:23B:CRED\r\n-")
+	assert.NoError(t, err)
+	assert.Equal(t, mt103.Message{Fields: []mt103.Field{{Tag: "20", Value: "REF"}, {Tag: "23B", Value: "CRED"}}}, m)
+}
+
+func TestParse_RejectsOtherMessages(t *testing.T) {
+	tests := map[string]string{
+		"{2:I202NWBKGB2LXXXXN}{4:\n:20:REF\n-}": "the message is an MT202, not an MT103",
+		"{4:\n:20:REF\n":                        "the text block is not terminated by -}",
+		"REF\n:20:REF":                          "the text block must start with a field",
+		"":                                      "the message has no fields",
+	}
+	for message, expected := range tests {
+		_, err := mt103.Parse(message)
+		assert.EqualError(t, err, expected, message)
+	}
+}
+
+func TestMessage_Attributes(t *testing.T) {
+	m, err := mt103.Parse(readFile(t, "testdata/mt103.txt"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	a, err := m.Attributes()
+	assert.NoError(t, err)
+	assert.Equal(t, attributes(), a)
+}
+
+func TestMessage_AttributesWithoutOptionalFields(t *testing.T) {
+	m, err := mt103.Parse(withoutOptionalFields(readFile(t, "testdata/mt103.txt")))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	a, err := m.Attributes()
+	assert.NoError(t, err)
+	assert.Equal(t, "NOTPROVIDED", a.Reference)
+	assert.Equal(t, "NOTPROVIDED", a.BeneficiaryParty.BankID)
+	assert.Equal(t, "NOTPROVIDED", a.BeneficiaryParty.BankIDCode)
+	assert.Equal(t, "NOTPROVIDED", a.BeneficiaryParty.Address)
+	assert.Equal(t, "Wilfred Jeremiah Owens", a.BeneficiaryParty.Name)
+}
+
+func TestMessage_AttributesWithoutSponsor(t *testing.T) {
+	text := strings.Replace(readFile(t, "testdata/mt103.txt"), ":52A:BARCGB22\n", "", 1)
+	m, err := mt103.Parse(text[strings.Index(text, "{4:") : strings.Index(text, "-}")+2])
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "", m.Sender)
+
+	a, err := m.Attributes()
+	assert.NoError(t, err)
+	assert.Equal(t, "NOTPROVIDED", a.DebtorParty.BankIDCode)
+	assert.Equal(t, &acme.Party{AccountNumber: "NOTPROVIDED", BankID: "NOTPROVIDED", BankIDCode: "NOTPROVIDED"},
+		a.SponsorParty)
+}
+
+func TestMessage_AttributesRejectsInvalidFields(t *testing.T) {
+	tests := map[string]func(text string) string{
+		"field 32A is required": func(text string) string {
+			return strings.Replace(text, ":32A:170118GBP100,21\n", "", 1)
+		},
+		"field 32A must be a value date, a currency and an amount": func(text string) string {
+			return strings.Replace(text, "170118GBP100,21", "170118GBP100.21", 1)
+		},
+		"field 36 must be a rate": func(text string) string {
+			return strings.Replace(text, ":36:2,00000", ":36:2", 1)
+		},
+		"field 59 must be an account and a name": func(text string) string {
+			return strings.Replace(text, ":59:/31926819\n", ":59:", 1)
+		},
+		"field 57C must start with a national clearing code": func(text string) string {
+			return strings.Replace(text, "//SC403000", "//XX403000", 1)
+		},
+		"field 71A must be OUR, BEN or SHA": func(text string) string {
+			return strings.Replace(text, ":71A:SHA", ":71A:ALL", 1)
+		},
+	}
+	for expected, change := range tests {
+		m, err := mt103.Parse(change(readFile(t, "testdata/mt103.txt")))
+		if !assert.NoError(t, err, expected) {
+			continue
+		}
+		_, err = m.Attributes()
+		assert.EqualError(t, err, expected)
+	}
+}
+
+func TestNew(t *testing.T) {
+	m, err := mt103.New(attributes())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	text := readFile(t, "testdata/mt103.txt")
+	textBlock := text[strings.Index(text, "{4:") : strings.Index(text, "-}")+2]
+	assert.Equal(t, textBlock, strings.Replace(m.String(), "\r\n", "\n", -1))
+}
+
+func TestNew_ChargesOfTheReceiver(t *testing.T) {
+	a := attributes()
+	a.ChargesInformation.BearerCode = "DEBT"
+	a.ChargesInformation.ReceiverChargesAmount = "1.00"
+
+	m, err := mt103.New(a)
+	assert.NoError(t, err)
+	assert.Empty(t, m.GetAll("71F"))
+	assert.Equal(t, []string{"OUR"}, m.GetAll("71A"))
+	assert.Equal(t, []string{"GBP1,00"}, m.GetAll("71G"))
+}
+
+func TestRoundTrip(t *testing.T) {
+	parsed, err := mt103.Parse(readFile(t, "testdata/mt103.txt"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	a, err := parsed.Attributes()
+	assert.NoError(t, err)
+
+	m, err := mt103.New(a)
+	assert.NoError(t, err)
+	assert.Equal(t, parsed.Fields, m.Fields)
+
+	reparsed, err := mt103.Parse(m.String())
+	assert.NoError(t, err)
+	roundTripped, err := reparsed.Attributes()
+	assert.NoError(t, err)
+	assert.Equal(t, a, roundTripped)
+}
+
+func TestRoundTrip_WithoutOptionalFields(t *testing.T) {
+	parsed, err := mt103.Parse(withoutOptionalFields(readFile(t, "testdata/mt103.txt")))
+	if !assert.NoError(t, err) {
+		return
+	}
+	a, err := parsed.Attributes()
+	assert.NoError(t, err)
+
+	m, err := mt103.New(a)
+	assert.NoError(t, err)
+	assert.Equal(t, parsed.Fields, m.Fields)
+
+	reparsed, err := mt103.Parse(m.String())
+	assert.NoError(t, err)
+	roundTripped, err := reparsed.Attributes()
+	assert.NoError(t, err)
+	assert.Equal(t, a, roundTripped)
+}
+
+func TestNew_RejectsPaymentsThatDoNotFit(t *testing.T) {
+	tests := map[string]func(a *acme.Attributes){
+		"debtor_party and beneficiary_party are required":        func(a *acme.Attributes) { a.DebtorParty = nil },
+		"processing_date must be a date formatted as YYYY-MM-DD": func(a *acme.Attributes) { a.ProcessingDate = "18/01/2017" },
+		"end_to_end_reference must be 1 to 16 characters that do not start or end with / or contain //": func(a *acme.Attributes) {
+			a.EndToEndReference = "Wilfred Owens piano lessons"
+		},
+		"debtor_party.bank_id must be a BIC to be the ordering institution": func(a *acme.Attributes) {
+			a.DebtorParty.BankID, a.DebtorParty.BankIDCode = "203301", "GBDSC"
+		},
+		"the bank_id_code ABCDE has no national clearing code in SWIFT messages": func(a *acme.Attributes) {
+			a.BeneficiaryParty.BankIDCode = "ABCDE"
+		},
+		"beneficiary_party.name must be 1 to 35 characters": func(a *acme.Attributes) {
+			a.BeneficiaryParty.Name = "Wilfred Jeremiah Owens of Localtown SE2"
+		},
+		`field 59 has characters that SWIFT messages do not allow: "/31926819\nWilfred Öwens\n1 The Beneficiary Localtown SE2"`: func(a *acme.Attributes) {
+			a.BeneficiaryParty.Name = "Wilfred Öwens"
+		},
+		"reference does not fit 4 lines of 35 characters": func(a *acme.Attributes) {
+			a.Reference = strings.Repeat("Em's piano lessons ", 8)
+		},
+		"charges_information.bearer_code must be DEBT, CRED, SHAR or SLEV": func(a *acme.Attributes) {
+			a.ChargesInformation.BearerCode = "ALL"
+		},
+	}
+	for expected, change := range tests {
+		a := attributes()
+		change(&a)
+
+		_, err := mt103.New(a)
+		assert.EqualError(t, err, expected)
+	}
+}
+
+// attributes are the attributes of the payment in testdata/mt103.txt
+func attributes() acme.Attributes {
+	accountType := 0
+	return acme.Attributes{
+		Amount: "100.21",
+		BeneficiaryParty: &acme.Party{
+			AccountName:       "Wilfred Jeremiah Owens",
+			AccountNumber:     "31926819",
+			AccountNumberCode: "BBAN",
+			AccountType:       &accountType,
+			Address:           "1 The Beneficiary Localtown SE2",
+			BankID:            "403000",
+			BankIDCode:        "GBDSC",
+			Name:              "Wilfred Jeremiah Owens",
+		},
+		ChargesInformation: &acme.ChargesInformation{
+			BearerCode:              "SHAR",
+			SenderCharges:           []acme.Charge{{Amount: "5.00", Currency: "GBP"}},
+			ReceiverChargesAmount:   "0.00",
+			ReceiverChargesCurrency: "GBP",
+		},
+		Currency: "GBP",
+		DebtorParty: &acme.Party{
+			AccountName:       "Emelia Jane Brown",
+			AccountNumber:     "GB83XABC10161234567801",
+			AccountNumberCode: "IBAN",
+			AccountType:       &accountType,
+			Address:           "10 Debtor Crescent Sourcetown NE1",
+			BankID:            "BARCGB22",
+			BankIDCode:        "SWBIC",
+			Name:              "Emelia Jane Brown",
+		},
+		EndToEndReference: "Wil piano Jan",
+		FX: &acme.FX{
+			ContractReference: "NOTPROVIDED",
+			ExchangeRate:      "2.00000",
+			OriginalAmount:    "200.42",
+			OriginalCurrency:  "USD",
+		},
+		NumericReference:     "NOTPROVIDED",
+		PaymentID:            "NOTPROVIDED",
+		PaymentPurpose:       "NOTPROVIDED",
+		PaymentScheme:        "SWIFT",
+		PaymentType:          "Credit",
+		ProcessingDate:       "2017-01-18",
+		Reference:            "Em's piano lessons",
+		SchemePaymentSubType: "NOTPROVIDED",
+		SchemePaymentType:    "NOTPROVIDED",
+		SponsorParty:         &acme.Party{AccountNumber: "NOTPROVIDED", BankID: "BARCGB22", BankIDCode: "SWBIC"},
+	}
+}
+
+// withoutOptionalFields leaves out the beneficiary bank in field 57, the remittance information in field
+// 70 and the address of the beneficiary in field 59 of a message
+func withoutOptionalFields(text string) string {
+	text = strings.Replace(text, ":57C://SC403000\n", "", 1)
+	text = strings.Replace(text, ":70:Em's piano lessons\n", "", 1)
+	return strings.Replace(text, "Wilfred Jeremiah Owens\n1 The Beneficiary Localtown SE2\n", "Wilfred Jeremiah Owens\n", 1)
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
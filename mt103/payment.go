@@ -0,0 +1,413 @@
+package mt103
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/steinfletcher/payments"
+)
+
+const (
+	// notProvided is the value of attributes that an MT103 has no field for, or whose optional field
+	// the message leaves out
+	notProvided = "NOTPROVIDED"
+	lineLength  = 35
+	dateFormat  = "2006-01-02"
+	// valueDateFormat is the YYMMDD format of the value date in field 32A
+	valueDateFormat = "060102"
+	paymentScheme   = "SWIFT"
+)
+
+var (
+	// xCharacters is the SWIFT x character set that the fields are written in
+	xCharacters            = regexp.MustCompile(`^[a-zA-Z0-9/\-?:().,'+ \n]*$`)
+	valueDateAmountPattern = regexp.MustCompile(`^([0-9]{6})([A-Z]{3})([0-9]+,[0-9]*)$`)
+	currencyAmountPattern  = regexp.MustCompile(`^([A-Z]{3})([0-9]+,[0-9]*)$`)
+	ratePattern            = regexp.MustCompile(`^[0-9]+,[0-9]*$`)
+	bicPattern             = regexp.MustCompile(`^[A-Z]{6}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+	clearingCodePattern    = regexp.MustCompile(`^//([A-Z]{2})(.+)$`)
+)
+
+// chargeCodes maps the charge bearer codes of the payment attributes to the codes of field 71A
+var chargeCodes = map[string]string{"DEBT": "OUR", "CRED": "BEN", "SHAR": "SHA", "SLEV": "SHA"}
+
+// clearingCodes maps the national clearing system codes of SWIFT, e.g. SC in //SC403000, to the
+// bank_id_code of the payment attributes
+var clearingCodes = map[string]string{
+	"AT": "ATBLZ", "AU": "AUBSB", "BL": "DEBLZ", "CC": "CACPA", "CN": "CNAPS", "ES": "ESNCC", "FW": "USABA",
+	"GR": "GRBIC", "HK": "HKNCC", "IE": "IENCC", "IN": "INFSC", "IT": "ITNCC", "NZ": "NZNCC", "PL": "PLKNR",
+	"PT": "PTNCC", "RU": "RUCBC", "SC": "GBDSC", "SW": "CHBCC", "ZA": "ZANCC",
+}
+
+// New maps the attributes of a payment to an MT103. The debtor bank must be identified by a BIC, as it
+// is the ordering institution in field 52A.
+func New(a acme.Attributes) (Message, error) {
+	w := writer{}
+	w.add("20", w.reference(a.EndToEndReference))
+	w.add("23B", "CRED")
+	w.add("32A", w.valueDate(a.ProcessingDate)+a.Currency+w.amount("amount", a.Amount))
+	if a.FX != nil && a.FX.OriginalAmount != "" {
+		w.add("33B", a.FX.OriginalCurrency+w.amount("fx.original_amount", a.FX.OriginalAmount))
+		if a.FX.OriginalCurrency != a.Currency {
+			w.add("36", w.amount("fx.exchange_rate", a.FX.ExchangeRate))
+		}
+	}
+	if a.DebtorParty == nil || a.BeneficiaryParty == nil {
+		return Message{}, fmt.Errorf("debtor_party and beneficiary_party are required")
+	}
+	w.add("50K", w.customer("debtor_party", a.DebtorParty))
+	if a.DebtorParty.BankIDCode != "SWBIC" {
+		w.fail("debtor_party.bank_id must be a BIC to be the ordering institution")
+	}
+	w.add("52A", a.DebtorParty.BankID)
+	if a.BeneficiaryParty.BankIDCode != notProvided {
+		w.institution("57", a.BeneficiaryParty)
+	}
+	w.add("59", w.customer("beneficiary_party", a.BeneficiaryParty))
+	if a.Reference != "" && a.Reference != notProvided {
+		w.add("70", w.lines("reference", []string{a.Reference}, 4, true))
+	}
+	w.charges(a)
+	return w.message, w.err
+}
+
+// Attributes maps an MT103 to the attributes of a payment. The sender, or else the ordering institution,
+// is the sponsor. Attributes that the message has no field for, or whose optional field it leaves out,
+// such as the reference in field 70 or the beneficiary bank in field 57, are NOTPROVIDED, so that the
+// payment of a message with only the mandatory fields is valid.
+func (m Message) Attributes() (acme.Attributes, error) {
+	r := reader{message: m}
+	a := acme.Attributes{
+		EndToEndReference:    r.required("20"),
+		PaymentType:          "Credit",
+		PaymentScheme:        paymentScheme,
+		PaymentID:            notProvided,
+		NumericReference:     notProvided,
+		PaymentPurpose:       notProvided,
+		SchemePaymentType:    notProvided,
+		SchemePaymentSubType: notProvided,
+		Reference:            notProvided,
+	}
+
+	if match := valueDateAmountPattern.FindStringSubmatch(r.required("32A")); match != nil {
+		date, err := time.Parse(valueDateFormat, match[1])
+		if err != nil {
+			r.fail("field 32A has an invalid value date")
+		}
+		a.ProcessingDate = date.Format(dateFormat)
+		a.Currency = match[2]
+		a.Amount = decimal(match[3], a.Currency)
+	} else if r.err == nil {
+		r.fail("field 32A must be a value date, a currency and an amount")
+	}
+
+	a.FX = &acme.FX{ContractReference: notProvided, ExchangeRate: "1", OriginalAmount: a.Amount,
+		OriginalCurrency: a.Currency}
+	if value, _, ok := m.Get("33B"); ok {
+		match := currencyAmountPattern.FindStringSubmatch(value)
+		if match == nil {
+			r.fail("field 33B must be a currency and an amount")
+		} else {
+			a.FX.OriginalCurrency, a.FX.OriginalAmount = match[1], decimal(match[2], match[1])
+		}
+	}
+	if value, _, ok := m.Get("36"); ok {
+		if !ratePattern.MatchString(value) {
+			r.fail("field 36 must be a rate")
+		}
+		a.FX.ExchangeRate = strings.TrimSuffix(strings.Replace(value, ",", ".", 1), ".")
+	}
+
+	a.DebtorParty = r.customer("50K")
+	a.BeneficiaryParty = r.customer("59")
+	if a.DebtorParty != nil {
+		a.DebtorParty.BankID, a.DebtorParty.BankIDCode = r.institution("52A", "52D")
+	}
+	if a.BeneficiaryParty != nil {
+		a.BeneficiaryParty.BankID, a.BeneficiaryParty.BankIDCode = r.institution("57A", "57C", "57D")
+	}
+	if value, _, ok := m.Get("70"); ok && value != "" {
+		a.Reference = strings.Replace(value, "\n", "", -1)
+	}
+	a.ChargesInformation = r.charges(a.Currency)
+
+	sponsor := m.Sender
+	if sponsor == "" && a.DebtorParty != nil && a.DebtorParty.BankIDCode == "SWBIC" {
+		sponsor = a.DebtorParty.BankID
+	}
+	a.SponsorParty = &acme.Party{AccountNumber: notProvided, BankID: notProvided, BankIDCode: notProvided}
+	if sponsor != "" {
+		a.SponsorParty.BankID, a.SponsorParty.BankIDCode = sponsor, "SWBIC"
+	}
+	return a, r.err
+}
+
+// writer collects the fields of a message and the first error found while writing them
+type writer struct {
+	message Message
+	err     error
+}
+
+func (w *writer) fail(format string, args ...interface{}) {
+	if w.err == nil {
+		w.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (w *writer) add(tag, value string) {
+	if !xCharacters.MatchString(value) {
+		w.fail("field %s has characters that SWIFT messages do not allow: %q", tag, value)
+	}
+	w.message.Fields = append(w.message.Fields, Field{Tag: tag, Value: value})
+}
+
+// reference checks the rules of the sender's reference in field 20
+func (w *writer) reference(reference string) string {
+	if reference == "" || utf8.RuneCountInString(reference) > 16 || strings.HasPrefix(reference, "/") ||
+		strings.HasSuffix(reference, "/") || strings.Contains(reference, "//") {
+		w.fail("end_to_end_reference must be 1 to 16 characters that do not start or end with / or contain //")
+	}
+	return reference
+}
+
+func (w *writer) valueDate(date string) string {
+	d, err := time.Parse(dateFormat, date)
+	if err != nil {
+		w.fail("processing_date must be a date formatted as YYYY-MM-DD")
+	}
+	return d.Format(valueDateFormat)
+}
+
+// amount writes a decimal with a decimal comma, which is written even if there are no decimal places
+func (w *writer) amount(field, amount string) string {
+	d, err := acme.ParseDecimal(amount)
+	if err != nil || d.Sign() < 0 {
+		w.fail("%s must be a positive decimal", field)
+		return amount
+	}
+	s := d.String()
+	if !strings.Contains(s, ".") {
+		s += "."
+	}
+	return strings.Replace(s, ".", ",", 1)
+}
+
+// customer writes the account, the name and the address of a party in the 4 lines of 35 characters that
+// follow the account line
+func (w *writer) customer(field string, p *acme.Party) string {
+	if utf8.RuneCountInString(p.Name) > lineLength || p.Name == "" {
+		w.fail("%s.name must be 1 to %d characters", field, lineLength)
+	}
+	lines := []string{p.Name}
+	if p.Address != notProvided {
+		lines = append(lines, wrap(p.Address)...)
+	}
+	return "/" + p.AccountNumber + "\n" + w.lines(field+".address", lines, 4, false)
+}
+
+// institution writes the bank of the beneficiary as a BIC in option A or as a national clearing code in
+// option C
+func (w *writer) institution(tag string, p *acme.Party) {
+	if p.BankIDCode == "SWBIC" {
+		w.add(tag+"A", p.BankID)
+		return
+	}
+	for code, bankIDCode := range clearingCodes {
+		if bankIDCode == p.BankIDCode {
+			w.add(tag+"C", "//"+code+p.BankID)
+			return
+		}
+	}
+	w.fail("the bank_id_code %s has no national clearing code in SWIFT messages", p.BankIDCode)
+}
+
+// lines checks that lines fit the number of lines of a field. Lines that are longer than a line of the
+// field are split into more lines when split is set.
+func (w *writer) lines(field string, lines []string, n int, split bool) string {
+	var fitted []string
+	for _, line := range lines {
+		for split && utf8.RuneCountInString(line) > lineLength {
+			runes := []rune(line)
+			fitted = append(fitted, string(runes[:lineLength]))
+			line = string(runes[lineLength:])
+		}
+		if utf8.RuneCountInString(line) > lineLength {
+			w.fail("%s does not fit lines of %d characters", field, lineLength)
+		}
+		fitted = append(fitted, line)
+	}
+	if len(fitted) > n {
+		w.fail("%s does not fit %d lines of %d characters", field, n, lineLength)
+	}
+	return strings.Join(fitted, "\n")
+}
+
+// charges writes the charge bearer, the charges of the sender when the debtor does not bear all the
+// charges and the charges of the receiver when the debtor does, as the network rules require
+func (w *writer) charges(a acme.Attributes) {
+	c := a.ChargesInformation
+	if c == nil {
+		w.fail("charges_information is required")
+		return
+	}
+	code, ok := chargeCodes[c.BearerCode]
+	if !ok {
+		w.fail("charges_information.bearer_code must be DEBT, CRED, SHAR or SLEV")
+	}
+	w.add("71A", code)
+	if code != "OUR" {
+		for i, charge := range c.SenderCharges {
+			w.add("71F", charge.Currency+w.amount(fmt.Sprintf("sender_charges.%d.amount", i), charge.Amount))
+		}
+		return
+	}
+	if d, err := acme.ParseDecimal(c.ReceiverChargesAmount); err == nil && !d.IsZero() {
+		w.add("71G", c.ReceiverChargesCurrency+w.amount("receiver_charges_amount", c.ReceiverChargesAmount))
+	}
+}
+
+// reader reads the fields of a message and keeps the first error found
+type reader struct {
+	message Message
+	err     error
+}
+
+func (r *reader) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (r *reader) required(tag string) string {
+	value, _, ok := r.message.Get(tag)
+	if !ok {
+		r.fail("field %s is required", tag)
+	}
+	return value
+}
+
+// customer reads the account, the name and the address of a party. The account number is an IBAN if it
+// has valid IBAN check digits.
+func (r *reader) customer(tag string) *acme.Party {
+	value := r.required(tag)
+	lines := strings.Split(value, "\n")
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "/") {
+		if r.err == nil {
+			r.fail("field %s must be an account and a name", tag)
+		}
+		return nil
+	}
+
+	accountType := 0
+	party := &acme.Party{
+		AccountNumber:     strings.TrimPrefix(lines[0], "/"),
+		AccountNumberCode: "BBAN",
+		AccountType:       &accountType,
+		Name:              lines[1],
+		AccountName:       lines[1],
+		Address:           strings.Join(lines[2:], " "),
+	}
+	if party.Address == "" {
+		party.Address = notProvided
+	}
+	if acme.CheckIBAN(party.AccountNumber) == nil {
+		party.AccountNumberCode = "IBAN"
+	}
+	return party
+}
+
+// institution reads a BIC from option A, or a national clearing code from the party identifier of
+// option C or D. Both are NOTPROVIDED when the message does not have the field.
+func (r *reader) institution(tags ...string) (string, string) {
+	value, tag, ok := r.message.Get(tags...)
+	if !ok {
+		return notProvided, notProvided
+	}
+	lines := strings.Split(value, "\n")
+	if strings.HasSuffix(tag, "A") {
+		bic := lines[len(lines)-1]
+		if !bicPattern.MatchString(bic) {
+			r.fail("field %s must end with a BIC", tag)
+		}
+		return bic, "SWBIC"
+	}
+	match := clearingCodePattern.FindStringSubmatch(lines[0])
+	if match == nil || clearingCodes[match[1]] == "" {
+		r.fail("field %s must start with a national clearing code", tag)
+		return "", ""
+	}
+	return match[2], clearingCodes[match[1]]
+}
+
+// charges reads the charge bearer and the charges of the sender and the receiver. Without field 71G the
+// receiver is charged nothing.
+func (r *reader) charges(currency string) *acme.ChargesInformation {
+	c := &acme.ChargesInformation{
+		SenderCharges:           []acme.Charge{},
+		ReceiverChargesAmount:   decimal("0,", currency),
+		ReceiverChargesCurrency: currency,
+	}
+	code := r.required("71A")
+	for bearer := range chargeCodes {
+		if chargeCodes[bearer] == code && bearer != "SLEV" {
+			c.BearerCode = bearer
+		}
+	}
+	if c.BearerCode == "" && r.err == nil {
+		r.fail("field 71A must be OUR, BEN or SHA")
+	}
+	for _, value := range r.message.GetAll("71F") {
+		match := currencyAmountPattern.FindStringSubmatch(value)
+		if match == nil {
+			r.fail("field 71F must be a currency and an amount")
+			continue
+		}
+		c.SenderCharges = append(c.SenderCharges, acme.Charge{Amount: decimal(match[2], match[1]), Currency: match[1]})
+	}
+	if value, _, ok := r.message.Get("71G"); ok {
+		match := currencyAmountPattern.FindStringSubmatch(value)
+		if match == nil {
+			r.fail("field 71G must be a currency and an amount")
+		} else {
+			c.ReceiverChargesCurrency, c.ReceiverChargesAmount = match[1], decimal(match[2], match[1])
+		}
+	}
+	return c
+}
+
+// decimal converts an amount with a decimal comma to a decimal with the number of decimal places of the
+// currency. An amount that is more precise than its currency is kept as it is, to fail validation.
+func decimal(amount, currency string) string {
+	d, err := acme.ParseDecimal(strings.TrimSuffix(strings.Replace(amount, ",", ".", 1), "."))
+	if err != nil {
+		return amount
+	}
+	if places := acme.MinorUnits(currency); d.Scale() <= places {
+		return d.StringFixed(places)
+	}
+	return d.String()
+}
+
+// wrap splits text into lines of at most 35 characters at spaces
+func wrap(text string) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(text) {
+		switch {
+		case line == "":
+			line = word
+		case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= lineLength:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -1,26 +1,96 @@
 package acme
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
 //go:generate pegomock generate --use-experimental-model-gen --output-dir mocks PaymentService
 
+// PaymentService stores the payments of every organisation. Each method only sees the payments of the
+// given organisation, so the payments of another organisation are not found.
 type PaymentService interface {
-	Get(id uuid.UUID) (Payment, error)
-	GetAll() (Payments, error)
-	Delete(id uuid.UUID) error
-	Update(id uuid.UUID, payment Payment) error
-	Create(payment Payment) (uuid.UUID, error)
+	Get(organisationID, id uuid.UUID) (Payment, error)
+	GetAsOf(organisationID, id uuid.UUID, asOf time.Time) (Payment, error)
+	GetAll(organisationID uuid.UUID) (Payments, error)
+	List(organisationID uuid.UUID, query PaymentQuery) (PaymentPage, error)
+	GetVersions(organisationID, id uuid.UUID) ([]Payment, error)
+	GetVersion(organisationID, id uuid.UUID, version int) (Payment, error)
+	Diff(organisationID, id uuid.UUID, from, to int) (Changes, error)
+	// Delete deletes the payment if its current version is the given version, or AnyVersion. recordedBy is
+	// the subject of the client deleting it. Like an update, only a pending payment can be deleted.
+	Delete(organisationID, id uuid.UUID, version int, recordedBy string) error
+	// Update stores payment as the next version of the payment and returns the new version number.
+	// payment.Version is the version being updated and must be the current version, unless it is AnyVersion.
+	// A payment cannot be moved to another organisation, and only a pending payment can be updated. The
+	// status of the payment is kept.
+	Update(organisationID, id uuid.UUID, payment Payment) (int, error)
+	// Create stores a new pending payment of the organisation
+	Create(organisationID uuid.UUID, payment Payment) (uuid.UUID, error)
+	// Transition takes the action on the payment if its current version is the given version, or AnyVersion,
+	// and returns the version recorded with the new status. recordedBy is the subject of the client taking it.
+	Transition(organisationID, id uuid.UUID, action Action, version int, recordedBy string) (Payment, error)
 }
 
+// AnyVersion skips the check that a payment has not been changed since the version the client last saw
+const AnyVersion = -1
+
 type Payment struct {
-	ID             uuid.UUID   `json:"id"`
-	Version        int         `json:"version"`
-	OrganisationID uuid.UUID   `json:"organisation_id"`
-	Attributes     interface{} `json:"attributes"`
+	ID             uuid.UUID  `json:"id"`
+	Version        int        `json:"version"`
+	OrganisationID uuid.UUID  `json:"organisation_id"`
+	Attributes     Attributes `json:"attributes"`
+	// Status is set by the repository. It is pending when a payment is created and changes by Transition.
+	Status Status `json:"status"`
+	// RecordedAt is when this version was stored. It is set by the repository.
+	RecordedAt time.Time `json:"recorded_at"`
+	// RecordedBy is the subject of the client that stored this version
+	RecordedBy string `json:"recorded_by,omitempty"`
+	// Deleted is only set on the tombstone version recorded when a payment is deleted
+	Deleted bool `json:"deleted,omitempty"`
 }
 
 type Payments struct {
-	Data []Payment `json:"data"`
+	Data  []Payment `json:"data"`
+	Links *Links    `json:"links,omitempty"`
+}
+
+type Links struct {
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// PaymentQuery selects a page of at most Limit payments matching Filter, starting at Cursor.
+// When AsOf is set the payments are listed as they were at that time.
+type PaymentQuery struct {
+	Limit  int
+	Cursor Cursor
+	Filter PaymentFilter
+	AsOf   time.Time
+}
+
+// PaymentFilter narrows down a list of payments. Zero valued fields do not filter.
+type PaymentFilter struct {
+	OrganisationID uuid.UUID
+	Currency       string
+	PaymentScheme  string
+	PaymentType    string
+	// ProcessedFrom and ProcessedTo are the inclusive range of the processing date
+	ProcessedFrom time.Time
+	ProcessedTo   time.Time
+	// AmountMin and AmountMax are the inclusive range of the amount
+	AmountMin *Decimal
+	AmountMax *Decimal
+	// Reference matches payments whose reference contains it, ignoring case
+	Reference string
+	Status    Status
+}
+
+// PaymentPage is a page of payments in ID order. Next and Prev are nil when there
+// are no further payments in that direction.
+type PaymentPage struct {
+	Payments []Payment
+	Next     *Cursor
+	Prev     *Cursor
 }
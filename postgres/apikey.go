@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/steinfletcher/payments"
+)
+
+const createAPIKeyQuery = `INSERT INTO api_keys (id, organisation_id, name, hash, scopes)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING created_at`
+
+const getAPIKeyQuery = `SELECT organisation_id, hash, scopes, revoked_at
+FROM api_keys
+WHERE id = $1`
+
+// revokeAPIKeyQuery keeps the time a key was first revoked
+const revokeAPIKeyQuery = `UPDATE api_keys
+SET revoked_at = COALESCE(revoked_at, now())
+WHERE id = $1
+  AND organisation_id = $2`
+
+// secretSize is the number of random bytes in the secret of an API key
+const secretSize = 32
+
+type apiKeyRepository struct {
+	db *sqlx.DB
+}
+
+type apiKeyRecord struct {
+	OrganisationID string         `db:"organisation_id"`
+	Hash           string         `db:"hash"`
+	Scopes         pq.StringArray `db:"scopes"`
+	RevokedAt      pq.NullTime    `db:"revoked_at"`
+}
+
+func NewAPIKeyRepository(db *sqlx.DB) acme.APIKeyService {
+	return &apiKeyRepository{db}
+}
+
+// Create generates an API key of the form "<id>.<secret>". The ID is used to look up the key so that
+// the secret can be compared with its hash in constant time.
+func (r *apiKeyRepository) Create(organisationID uuid.UUID, name string, scopes []string) (acme.APIKey, string, error) {
+	secret := make([]byte, secretSize)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return acme.APIKey{}, "", errors.WithStack(acme.ServerError)
+	}
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+
+	if scopes == nil {
+		scopes = []string{}
+	}
+	apiKey := acme.APIKey{ID: uuid.New(), OrganisationID: organisationID, Name: name, Scopes: scopes}
+	err = r.db.Get(&apiKey.CreatedAt, createAPIKeyQuery, apiKey.ID.String(), organisationID.String(), name,
+		hashSecret(encodedSecret), pq.StringArray(scopes))
+	if err != nil {
+		return acme.APIKey{}, "", errors.WithStack(acme.ServerError)
+	}
+	return apiKey, apiKey.ID.String() + "." + encodedSecret, nil
+}
+
+func (r *apiKeyRepository) Authenticate(key string) (acme.Principal, error) {
+	unauthorized := acme.Unauthorized
+	unauthorized.Detail = "The API key is not valid"
+
+	parts := strings.SplitN(key, ".", 2)
+	if len(parts) != 2 {
+		return acme.Principal{}, unauthorized
+	}
+	id, err := uuid.Parse(parts[0])
+	if err != nil {
+		return acme.Principal{}, unauthorized
+	}
+
+	var record apiKeyRecord
+	err = r.db.Get(&record, getAPIKeyQuery, id.String())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return acme.Principal{}, unauthorized
+		}
+		return acme.Principal{}, errors.WithStack(acme.ServerError)
+	}
+	if subtle.ConstantTimeCompare([]byte(record.Hash), []byte(hashSecret(parts[1]))) != 1 || record.RevokedAt.Valid {
+		return acme.Principal{}, unauthorized
+	}
+
+	return acme.Principal{
+		Subject:        id.String(),
+		OrganisationID: uuid.MustParse(record.OrganisationID),
+		Scopes:         record.Scopes,
+	}, nil
+}
+
+func (r *apiKeyRepository) Revoke(organisationID, id uuid.UUID) error {
+	result, err := r.db.Exec(revokeAPIKeyQuery, id.String(), organisationID.String())
+	if err != nil {
+		return errors.WithStack(acme.ServerError)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return acme.APIKeyNotFound
+	}
+	return nil
+}
+
+// hashSecret is the stored form of the secret of an API key. The secret is random, so a plain SHA-256
+// is enough to make a leaked hash useless.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
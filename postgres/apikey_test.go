@@ -0,0 +1,82 @@
+package postgres_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/steinfletcher/payments"
+	"github.com/steinfletcher/payments/postgres"
+	"github.com/steinfletcher/payments/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKey_Authenticates(t *testing.T) {
+	test.SkipIntegration(t)
+	db := test.DBSetup(func(tx *sqlx.Tx) {})
+	organisationID := uuid.New()
+	apiKeys := postgres.NewAPIKeyRepository(db)
+
+	apiKey, key, err := apiKeys.Create(organisationID, "test", []string{acme.ScopeRead, acme.ScopeWrite})
+	assert.NoError(t, err)
+	assert.Equal(t, organisationID, apiKey.OrganisationID)
+	assert.Equal(t, "test", apiKey.Name)
+	assert.False(t, apiKey.CreatedAt.IsZero())
+
+	principal, err := apiKeys.Authenticate(key)
+
+	assert.NoError(t, err)
+	assert.Equal(t, acme.Principal{
+		Subject:        apiKey.ID.String(),
+		OrganisationID: organisationID,
+		Scopes:         []string{acme.ScopeRead, acme.ScopeWrite},
+	}, principal)
+}
+
+func TestAPIKey_StoresHashOnly(t *testing.T) {
+	test.SkipIntegration(t)
+	db := test.DBSetup(func(tx *sqlx.Tx) {})
+
+	apiKey, key, err := postgres.NewAPIKeyRepository(db).Create(uuid.New(), "test", nil)
+	assert.NoError(t, err)
+
+	var hash string
+	err = db.Get(&hash, "SELECT hash FROM api_keys WHERE id = $1", apiKey.ID.String())
+	assert.NoError(t, err)
+	assert.NotContains(t, hash, strings.SplitN(key, ".", 2)[1])
+}
+
+func TestAPIKey_RejectsInvalidKeys(t *testing.T) {
+	test.SkipIntegration(t)
+	db := test.DBSetup(func(tx *sqlx.Tx) {})
+	apiKeys := postgres.NewAPIKeyRepository(db)
+	apiKey, _, err := apiKeys.Create(uuid.New(), "test", nil)
+	assert.NoError(t, err)
+
+	for _, key := range []string{"", "no-separator", "not-an-id.secret", uuid.New().String() + ".secret",
+		apiKey.ID.String() + ".wrong-secret"} {
+		_, err := apiKeys.Authenticate(key)
+
+		assert.EqualError(t, err, acme.Unauthorized.Code, key)
+	}
+}
+
+func TestAPIKey_Revoke(t *testing.T) {
+	test.SkipIntegration(t)
+	db := test.DBSetup(func(tx *sqlx.Tx) {})
+	organisationID := uuid.New()
+	apiKeys := postgres.NewAPIKeyRepository(db)
+	apiKey, key, err := apiKeys.Create(organisationID, "test", []string{acme.ScopeRead})
+	assert.NoError(t, err)
+
+	err = apiKeys.Revoke(uuid.New(), apiKey.ID)
+	assert.EqualError(t, err, acme.APIKeyNotFound.Code)
+	_, err = apiKeys.Authenticate(key)
+	assert.NoError(t, err)
+
+	err = apiKeys.Revoke(organisationID, apiKey.ID)
+	assert.NoError(t, err)
+	_, err = apiKeys.Authenticate(key)
+	assert.EqualError(t, err, acme.Unauthorized.Code)
+}
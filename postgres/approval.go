@@ -0,0 +1,288 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/steinfletcher/payments"
+)
+
+const getThresholdQuery = `SELECT currency, amount, approvals
+FROM approval_thresholds
+WHERE organisation_id = $1 %s
+ORDER BY currency`
+
+const setThresholdQuery = `INSERT INTO approval_thresholds (organisation_id, currency, amount, approvals)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (organisation_id, currency) DO UPDATE SET amount = EXCLUDED.amount, approvals = EXCLUDED.approvals`
+
+const removeThresholdQuery = `DELETE
+FROM approval_thresholds
+WHERE organisation_id = $1
+  AND currency = $2`
+
+const insertApprovalQuery = `INSERT INTO approvals (organisation_id, external_id, payment_version, approver, decision)
+VALUES ($1, $2, $3, $4, $5)`
+
+const getApprovalsQuery = `SELECT external_id, payment_version, approver, decision, recorded_at
+FROM approvals
+WHERE organisation_id = $1
+  AND external_id = $2
+ORDER BY id`
+
+// countApprovalsQuery counts the approvals of a version of a payment. Approvals of earlier versions do not
+// count, since the payment has changed since they were given.
+const countApprovalsQuery = `SELECT COUNT(*)
+FROM approvals
+WHERE organisation_id = $1
+  AND external_id = $2
+  AND payment_version = $3
+  AND decision = 'approved'`
+
+// recordedByQuery checks whether a client recorded any version of a payment
+const recordedByQuery = `SELECT EXISTS(SELECT 1
+              FROM payments
+              WHERE organisation_id = $1
+                AND external_id = $2
+                AND recorded_by = $3)`
+
+type approvalRepository struct {
+	db *sqlx.DB
+}
+
+type approvalRecord struct {
+	ExternalID     string    `db:"external_id"`
+	PaymentVersion int       `db:"payment_version"`
+	Approver       string    `db:"approver"`
+	Decision       string    `db:"decision"`
+	RecordedAt     time.Time `db:"recorded_at"`
+}
+
+type thresholdRecord struct {
+	Currency  string `db:"currency"`
+	Amount    string `db:"amount"`
+	Approvals int    `db:"approvals"`
+}
+
+func NewApprovalRepository(db *sqlx.DB) acme.ApprovalService {
+	return &approvalRepository{db}
+}
+
+// Approve locks the payment before it counts the approvals, as two approvers that each counted only their
+// own approval would both leave the payment pending once the quorum is reached
+func (r *approvalRepository) Approve(organisationID, id uuid.UUID, version int, approver string) (acme.Payment,
+	error) {
+	var payment acme.Payment
+	err := withOrganisation(r.db, organisationID, func(tx *sqlx.Tx) error {
+		err := lockPayment(tx, id)
+		if err != nil {
+			return err
+		}
+		payment, err = getPayment(tx, organisationID, id)
+		if err != nil {
+			return err
+		}
+		if version != acme.AnyVersion && version != payment.Version {
+			return acme.VersionConflict
+		}
+		if _, err := payment.Status.Next(acme.ActionApprove); err != nil {
+			return err
+		}
+
+		err = recordDecision(tx, payment, approver, acme.DecisionApproved)
+		if err != nil {
+			return err
+		}
+		reached, err := quorumReached(tx, payment)
+		if err != nil || !reached {
+			return err
+		}
+		payment, err = transitionPayment(tx, payment, acme.ActionApprove, approver)
+		return err
+	})
+	if err != nil {
+		return acme.Payment{}, err
+	}
+	return payment, nil
+}
+
+func (r *approvalRepository) Reject(organisationID, id uuid.UUID, version int, approver string) (acme.Payment,
+	error) {
+	var payment acme.Payment
+	err := withOrganisation(r.db, organisationID, func(tx *sqlx.Tx) error {
+		err := lockPayment(tx, id)
+		if err != nil {
+			return err
+		}
+		payment, err = getPayment(tx, organisationID, id)
+		if err != nil {
+			return err
+		}
+		if version != acme.AnyVersion && version != payment.Version {
+			return acme.VersionConflict
+		}
+
+		if payment.Status == acme.StatusPending {
+			err = recordDecision(tx, payment, approver, acme.DecisionRejected)
+			if err != nil {
+				return err
+			}
+		}
+		payment, err = transitionPayment(tx, payment, acme.ActionReject, approver)
+		return err
+	})
+	if err != nil {
+		return acme.Payment{}, err
+	}
+	return payment, nil
+}
+
+func (r *approvalRepository) GetApprovals(organisationID, id uuid.UUID) ([]acme.Approval, error) {
+	var records []approvalRecord
+	err := withOrganisation(r.db, organisationID, func(tx *sqlx.Tx) error {
+		err := tx.Select(&records, getApprovalsQuery, organisationID.String(), id.String())
+		if err != nil {
+			return errors.WithStack(acme.ServerError)
+		}
+		if len(records) == 0 {
+			_, err = getPayment(tx, organisationID, id)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	approvals := []acme.Approval{}
+	for _, record := range records {
+		approvals = append(approvals, acme.Approval{
+			PaymentID:      uuid.MustParse(record.ExternalID),
+			PaymentVersion: record.PaymentVersion,
+			Approver:       record.Approver,
+			Decision:       acme.Decision(record.Decision),
+			RecordedAt:     record.RecordedAt,
+		})
+	}
+	return approvals, nil
+}
+
+func (r *approvalRepository) GetThresholds(organisationID uuid.UUID) ([]acme.Threshold, error) {
+	var thresholds []acme.Threshold
+	err := withOrganisation(r.db, organisationID, func(tx *sqlx.Tx) error {
+		var records []thresholdRecord
+		err := tx.Select(&records, fmt.Sprintf(getThresholdQuery, ""), organisationID.String())
+		if err != nil {
+			return errors.WithStack(acme.ServerError)
+		}
+		thresholds, err = mapThresholds(records)
+		return err
+	})
+	return thresholds, err
+}
+
+func (r *approvalRepository) SetThreshold(organisationID uuid.UUID, threshold acme.Threshold) error {
+	if !threshold.Valid() {
+		return acme.InvalidThreshold
+	}
+	return withOrganisation(r.db, organisationID, func(tx *sqlx.Tx) error {
+		_, err := tx.Exec(setThresholdQuery, organisationID.String(), threshold.Currency,
+			threshold.Amount.String(), threshold.Approvals)
+		if err != nil {
+			return errors.WithStack(acme.ServerError)
+		}
+		return nil
+	})
+}
+
+func (r *approvalRepository) RemoveThreshold(organisationID uuid.UUID, currency string) error {
+	return withOrganisation(r.db, organisationID, func(tx *sqlx.Tx) error {
+		result, err := tx.Exec(removeThresholdQuery, organisationID.String(), currency)
+		if err != nil {
+			return errors.WithStack(acme.ServerError)
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return acme.ThresholdNotFound
+		}
+		return nil
+	})
+}
+
+// recordDecision adds the decision of an approver about the current version of a payment to the audit
+// trail. An approver cannot approve a payment that it recorded a version of, whatever the amount.
+func recordDecision(tx *sqlx.Tx, payment acme.Payment, approver string, decision acme.Decision) error {
+	if decision == acme.DecisionApproved {
+		var recorded bool
+		err := tx.Get(&recorded, recordedByQuery, payment.OrganisationID.String(), payment.ID.String(), approver)
+		if err != nil {
+			return errors.WithStack(acme.ServerError)
+		}
+		if recorded {
+			return acme.SelfApproval
+		}
+	}
+
+	_, err := tx.Exec(insertApprovalQuery, payment.OrganisationID.String(), payment.ID.String(), payment.Version,
+		approver, string(decision))
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			return acme.DuplicateApproval
+		}
+		return errors.WithStack(acme.ServerError)
+	}
+	return nil
+}
+
+// quorumReached checks whether the current version of a payment has the approvals it needs
+func quorumReached(tx *sqlx.Tx, payment acme.Payment) (bool, error) {
+	threshold, err := getThreshold(tx, payment.OrganisationID, payment.Attributes.Currency)
+	if err != nil {
+		return false, err
+	}
+	needed := threshold.Quorum(payment.Attributes)
+
+	var approvals int
+	err = tx.Get(&approvals, countApprovalsQuery, payment.OrganisationID.String(), payment.ID.String(),
+		payment.Version)
+	if err != nil {
+		return false, errors.WithStack(acme.ServerError)
+	}
+	return approvals >= needed, nil
+}
+
+// getThreshold selects the threshold of the organisation for a currency, or nil if it has none
+func getThreshold(tx *sqlx.Tx, organisationID uuid.UUID, currency string) (*acme.Threshold, error) {
+	var record thresholdRecord
+	err := tx.Get(&record, fmt.Sprintf(getThresholdQuery, "AND currency = $2"), organisationID.String(), currency)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.WithStack(acme.ServerError)
+	}
+	thresholds, err := mapThresholds([]thresholdRecord{record})
+	if err != nil {
+		return nil, err
+	}
+	return &thresholds[0], nil
+}
+
+func mapThresholds(records []thresholdRecord) ([]acme.Threshold, error) {
+	thresholds := []acme.Threshold{}
+	for _, record := range records {
+		amount, err := acme.ParseDecimal(record.Amount)
+		if err != nil {
+			return nil, errors.WithStack(acme.ServerError)
+		}
+		thresholds = append(thresholds, acme.Threshold{
+			Currency:  record.Currency,
+			Amount:    amount,
+			Approvals: record.Approvals,
+		})
+	}
+	return thresholds, nil
+}
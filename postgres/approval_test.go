@@ -0,0 +1,188 @@
+package postgres_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/steinfletcher/payments"
+	"github.com/steinfletcher/payments/postgres"
+	"github.com/steinfletcher/payments/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApprove_SingleApprovalBelowThreshold(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID, organisationID := uuid.New(), uuid.New()
+	db := withPendingPayment(externalID, organisationID, "100.00")
+	approvals := postgres.NewApprovalRepository(db)
+	err := approvals.SetThreshold(organisationID, acme.Threshold{Currency: "GBP", Amount: acme.NewDecimal(1000, 0),
+		Approvals: 2})
+	assert.NoError(t, err)
+
+	payment, err := approvals.Approve(organisationID, externalID, 0, "approver")
+
+	assert.NoError(t, err)
+	assert.Equal(t, acme.StatusApproved, payment.Status)
+	assert.Equal(t, 1, payment.Version)
+}
+
+func TestApprove_SelfApprovalBelowThreshold(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID, organisationID := uuid.New(), uuid.New()
+	db := withPendingPayment(externalID, organisationID, "100.00")
+	approvals := postgres.NewApprovalRepository(db)
+
+	_, err := approvals.Approve(organisationID, externalID, 0, "creator")
+
+	assert.EqualError(t, err, acme.SelfApproval.Code)
+}
+
+func TestApprove_FourEyesAboveThreshold(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID, organisationID := uuid.New(), uuid.New()
+	db := withPendingPayment(externalID, organisationID, "5000.00")
+	approvals := postgres.NewApprovalRepository(db)
+	payments := postgres.NewPaymentRepository(db)
+	err := approvals.SetThreshold(organisationID, acme.Threshold{Currency: "GBP", Amount: acme.NewDecimal(1000, 0),
+		Approvals: 2})
+	assert.NoError(t, err)
+
+	_, err = approvals.Approve(organisationID, externalID, acme.AnyVersion, "creator")
+	assert.EqualError(t, err, acme.SelfApproval.Code)
+
+	payment, err := approvals.Approve(organisationID, externalID, acme.AnyVersion, "first")
+	assert.NoError(t, err)
+	assert.Equal(t, acme.StatusPending, payment.Status)
+
+	_, err = approvals.Approve(organisationID, externalID, acme.AnyVersion, "first")
+	assert.EqualError(t, err, acme.DuplicateApproval.Code)
+	_, err = payments.Transition(organisationID, externalID, acme.ActionApprove, acme.AnyVersion, "first")
+	assert.EqualError(t, err, acme.ApprovalRequired.Code)
+	_, err = payments.Transition(organisationID, externalID, acme.ActionSubmit, acme.AnyVersion, "first")
+	assert.EqualError(t, err, acme.InvalidTransition.Code)
+
+	payment, err = approvals.Approve(organisationID, externalID, acme.AnyVersion, "second")
+	assert.NoError(t, err)
+	assert.Equal(t, acme.StatusApproved, payment.Status)
+	assert.Equal(t, "second", payment.RecordedBy)
+
+	decisions, err := approvals.GetApprovals(organisationID, externalID)
+	assert.NoError(t, err)
+	assert.Len(t, decisions, 2)
+	assert.Equal(t, "first", decisions[0].Approver)
+	assert.Equal(t, acme.DecisionApproved, decisions[1].Decision)
+}
+
+func TestApprove_ApprovalsOfEarlierVersionsDoNotCount(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID, organisationID := uuid.New(), uuid.New()
+	db := withPendingPayment(externalID, organisationID, "5000.00")
+	approvals := postgres.NewApprovalRepository(db)
+	err := approvals.SetThreshold(organisationID, acme.Threshold{Currency: "GBP", Amount: acme.NewDecimal(1000, 0),
+		Approvals: 2})
+	assert.NoError(t, err)
+
+	_, err = approvals.Approve(organisationID, externalID, 0, "first")
+	assert.NoError(t, err)
+	_, err = postgres.NewPaymentRepository(db).Update(organisationID, externalID, acme.Payment{
+		Version:        0,
+		OrganisationID: organisationID,
+		Attributes:     acme.Attributes{Amount: "6000.00", Currency: "GBP"},
+		RecordedBy:     "editor",
+	})
+	assert.NoError(t, err)
+
+	payment, err := approvals.Approve(organisationID, externalID, 1, "second")
+
+	assert.NoError(t, err)
+	assert.Equal(t, acme.StatusPending, payment.Status)
+}
+
+func TestApprove_ConcurrentApprovalsReachQuorum(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID, organisationID := uuid.New(), uuid.New()
+	db := withPendingPayment(externalID, organisationID, "5000.00")
+	approvals := postgres.NewApprovalRepository(db)
+	err := approvals.SetThreshold(organisationID, acme.Threshold{Currency: "GBP", Amount: acme.NewDecimal(1000, 0),
+		Approvals: 2})
+	assert.NoError(t, err)
+
+	start := make(chan struct{})
+	errs := make(chan error)
+	for _, approver := range []string{"first", "second"} {
+		go func(approver string) {
+			<-start
+			_, err := approvals.Approve(organisationID, externalID, 0, approver)
+			errs <- err
+		}(approver)
+	}
+	close(start)
+	assert.NoError(t, <-errs)
+	assert.NoError(t, <-errs)
+
+	payment, err := postgres.NewPaymentRepository(db).Get(organisationID, externalID)
+	assert.NoError(t, err)
+	assert.Equal(t, acme.StatusApproved, payment.Status)
+}
+
+func TestReject_RecordsDecision(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID, organisationID := uuid.New(), uuid.New()
+	db := withPendingPayment(externalID, organisationID, "100.00")
+	approvals := postgres.NewApprovalRepository(db)
+
+	// the client that recorded a payment can withdraw it
+	payment, err := approvals.Reject(organisationID, externalID, acme.AnyVersion, "creator")
+
+	assert.NoError(t, err)
+	assert.Equal(t, acme.StatusRejected, payment.Status)
+	decisions, err := approvals.GetApprovals(organisationID, externalID)
+	assert.NoError(t, err)
+	assert.Len(t, decisions, 1)
+	assert.Equal(t, acme.DecisionRejected, decisions[0].Decision)
+}
+
+func TestGetApprovals_PaymentNotFound(t *testing.T) {
+	test.SkipIntegration(t)
+	db := test.DBSetup(func(tx *sqlx.Tx) {})
+
+	_, err := postgres.NewApprovalRepository(db).GetApprovals(uuid.New(), uuid.New())
+
+	assert.EqualError(t, err, acme.PaymentNotFound.Code)
+}
+
+func TestThresholds(t *testing.T) {
+	test.SkipIntegration(t)
+	db := test.DBSetup(func(tx *sqlx.Tx) {})
+	organisationID := uuid.New()
+	approvals := postgres.NewApprovalRepository(db)
+
+	err := approvals.SetThreshold(organisationID, acme.Threshold{Currency: "GBP", Amount: acme.NewDecimal(1000, 0),
+		Approvals: 2})
+	assert.NoError(t, err)
+	err = approvals.SetThreshold(organisationID, acme.Threshold{Currency: "GBP", Amount: acme.NewDecimal(50000, 2),
+		Approvals: 3})
+	assert.NoError(t, err)
+	err = approvals.SetThreshold(organisationID, acme.Threshold{Currency: "GBP", Approvals: 0})
+	assert.EqualError(t, err, acme.InvalidThreshold.Code)
+
+	thresholds, err := approvals.GetThresholds(organisationID)
+	assert.NoError(t, err)
+	assert.Len(t, thresholds, 1)
+	assert.Equal(t, 3, thresholds[0].Approvals)
+	assert.Equal(t, 0, thresholds[0].Amount.Cmp(acme.NewDecimal(500, 0)))
+
+	assert.NoError(t, approvals.RemoveThreshold(organisationID, "GBP"))
+	assert.EqualError(t, approvals.RemoveThreshold(organisationID, "GBP"), acme.ThresholdNotFound.Code)
+}
+
+// withPendingPayment stores a pending GBP payment of the amount recorded by the client "creator"
+func withPendingPayment(externalID, organisationID uuid.UUID, amount string) *sqlx.DB {
+	return test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id, recorded_by) VALUES
+				('%s', '{"amount": "%s", "currency": "GBP"}', 0, '%s', 'creator')`
+		tx.MustExec(fmt.Sprintf(query, externalID, amount, organisationID))
+	})
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gin-gonic/gin/json"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/pkg/errors"
+	"github.com/steinfletcher/payments"
+)
+
+// reserveKeyQuery takes over a key that has been in progress for longer than the timeout in seconds, as
+// the request that reserved it has failed without releasing it
+const reserveKeyQuery = `INSERT INTO idempotency_keys (organisation_id, key, fingerprint)
+VALUES ($1, $2, $3)
+ON CONFLICT (organisation_id, key) DO UPDATE
+SET fingerprint = EXCLUDED.fingerprint, created_at = now()
+WHERE idempotency_keys.status_code IS NULL
+  AND idempotency_keys.created_at < now() - $4::FLOAT * INTERVAL '1 second'`
+
+const getKeyQuery = `SELECT fingerprint, status_code, header, body
+FROM idempotency_keys
+WHERE organisation_id = $1
+  AND key = $2`
+
+const completeKeyQuery = `UPDATE idempotency_keys
+SET status_code = $3, header = $4, body = $5
+WHERE organisation_id = $1
+  AND key = $2`
+
+// releaseKeyQuery only deletes keys in progress so that a completed response is never lost
+const releaseKeyQuery = `DELETE
+FROM idempotency_keys
+WHERE organisation_id = $1
+  AND key = $2
+  AND status_code IS NULL`
+
+type idempotencyRepository struct {
+	db *sqlx.DB
+	// timeout is how long a key stays in progress before another request can reserve it
+	timeout time.Duration
+}
+
+type idempotencyRecord struct {
+	Fingerprint string         `db:"fingerprint"`
+	StatusCode  sql.NullInt64  `db:"status_code"`
+	Header      types.JSONText `db:"header"`
+	Body        []byte         `db:"body"`
+}
+
+// NewIdempotencyRepository creates a repository of idempotency keys. A key whose request has not completed
+// within the timeout can be reserved again, so that a request that crashed does not block its retries.
+func NewIdempotencyRepository(db *sqlx.DB, timeout time.Duration) acme.IdempotencyService {
+	return &idempotencyRepository{db: db, timeout: timeout}
+}
+
+func (r *idempotencyRepository) Reserve(organisationID uuid.UUID, key, fingerprint string) (*acme.IdempotentResponse, error) {
+	result, err := r.db.Exec(reserveKeyQuery, organisationID.String(), key, fingerprint, r.timeout.Seconds())
+	if err != nil {
+		return nil, errors.WithStack(acme.ServerError)
+	}
+	if rows, _ := result.RowsAffected(); rows == 1 {
+		return nil, nil
+	}
+
+	var record idempotencyRecord
+	err = r.db.Get(&record, getKeyQuery, organisationID.String(), key)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// the original request failed and released the key in the meantime
+			return nil, acme.IdempotencyKeyInProgress
+		}
+		return nil, errors.WithStack(acme.ServerError)
+	}
+	if record.Fingerprint != fingerprint {
+		return nil, acme.IdempotencyKeyReused
+	}
+	if !record.StatusCode.Valid {
+		return nil, acme.IdempotencyKeyInProgress
+	}
+
+	response := &acme.IdempotentResponse{StatusCode: int(record.StatusCode.Int64), Body: record.Body}
+	if len(record.Header) > 0 {
+		err = record.Header.Unmarshal(&response.Header)
+		if err != nil {
+			return nil, errors.WithStack(acme.ServerError)
+		}
+	}
+	return response, nil
+}
+
+func (r *idempotencyRepository) Complete(organisationID uuid.UUID, key string, response acme.IdempotentResponse) error {
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return errors.WithStack(acme.ServerError)
+	}
+	_, err = r.db.Exec(completeKeyQuery, organisationID.String(), key, response.StatusCode, header, response.Body)
+	if err != nil {
+		return errors.WithStack(acme.ServerError)
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) Release(organisationID uuid.UUID, key string) error {
+	_, err := r.db.Exec(releaseKeyQuery, organisationID.String(), key)
+	if err != nil {
+		return errors.WithStack(acme.ServerError)
+	}
+	return nil
+}
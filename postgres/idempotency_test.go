@@ -0,0 +1,130 @@
+package postgres_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/steinfletcher/payments"
+	"github.com/steinfletcher/payments/postgres"
+	"github.com/steinfletcher/payments/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyKey_ReplaysCompletedResponse(t *testing.T) {
+	test.SkipIntegration(t)
+	db := test.DBSetup(func(tx *sqlx.Tx) {})
+	organisationID := uuid.New()
+	keys := postgres.NewIdempotencyRepository(db, time.Minute)
+	response := acme.IdempotentResponse{
+		StatusCode: http.StatusCreated,
+		Header:     map[string]string{"Location": uuid.New().String()},
+	}
+
+	stored, err := keys.Reserve(organisationID, "key-1", "fingerprint")
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+	err = keys.Complete(organisationID, "key-1", response)
+	assert.NoError(t, err)
+
+	stored, err = keys.Reserve(organisationID, "key-1", "fingerprint")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &response, stored)
+}
+
+func TestIdempotencyKey_RejectsDifferentRequest(t *testing.T) {
+	test.SkipIntegration(t)
+	db := test.DBSetup(func(tx *sqlx.Tx) {})
+	organisationID := uuid.New()
+	keys := postgres.NewIdempotencyRepository(db, time.Minute)
+
+	_, err := keys.Reserve(organisationID, "key-1", "fingerprint")
+	assert.NoError(t, err)
+
+	_, err = keys.Reserve(organisationID, "key-1", "other fingerprint")
+	assert.EqualError(t, err, acme.IdempotencyKeyReused.Code)
+}
+
+func TestIdempotencyKey_InProgress(t *testing.T) {
+	test.SkipIntegration(t)
+	db := test.DBSetup(func(tx *sqlx.Tx) {})
+	organisationID := uuid.New()
+	keys := postgres.NewIdempotencyRepository(db, time.Minute)
+
+	_, err := keys.Reserve(organisationID, "key-1", "fingerprint")
+	assert.NoError(t, err)
+
+	_, err = keys.Reserve(organisationID, "key-1", "fingerprint")
+	assert.EqualError(t, err, acme.IdempotencyKeyInProgress.Code)
+}
+
+func TestIdempotencyKey_ReleasedKeyCanBeReserved(t *testing.T) {
+	test.SkipIntegration(t)
+	db := test.DBSetup(func(tx *sqlx.Tx) {})
+	organisationID := uuid.New()
+	keys := postgres.NewIdempotencyRepository(db, time.Minute)
+
+	_, err := keys.Reserve(organisationID, "key-1", "fingerprint")
+	assert.NoError(t, err)
+	err = keys.Release(organisationID, "key-1")
+	assert.NoError(t, err)
+
+	stored, err := keys.Reserve(organisationID, "key-1", "other fingerprint")
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestIdempotencyKey_ScopedToOrganisation(t *testing.T) {
+	test.SkipIntegration(t)
+	db := test.DBSetup(func(tx *sqlx.Tx) {})
+	keys := postgres.NewIdempotencyRepository(db, time.Minute)
+
+	_, err := keys.Reserve(uuid.New(), "key-1", "fingerprint")
+	assert.NoError(t, err)
+
+	stored, err := keys.Reserve(uuid.New(), "key-1", "other fingerprint")
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestIdempotencyKey_ExpiredReservationCanBeReserved(t *testing.T) {
+	test.SkipIntegration(t)
+	db := test.DBSetup(func(tx *sqlx.Tx) {})
+	organisationID := uuid.New()
+	keys := postgres.NewIdempotencyRepository(db, time.Minute)
+
+	_, err := keys.Reserve(organisationID, "key-1", "fingerprint")
+	assert.NoError(t, err)
+	_, err = db.Exec(`UPDATE idempotency_keys SET created_at = now() - INTERVAL '2 minutes'
+WHERE organisation_id = $1 AND key = $2`, organisationID.String(), "key-1")
+	assert.NoError(t, err)
+
+	stored, err := keys.Reserve(organisationID, "key-1", "fingerprint")
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+
+	_, err = keys.Reserve(organisationID, "key-1", "fingerprint")
+	assert.EqualError(t, err, acme.IdempotencyKeyInProgress.Code)
+}
+
+func TestIdempotencyKey_CompletedResponseDoesNotExpire(t *testing.T) {
+	test.SkipIntegration(t)
+	db := test.DBSetup(func(tx *sqlx.Tx) {})
+	organisationID := uuid.New()
+	keys := postgres.NewIdempotencyRepository(db, time.Minute)
+	response := acme.IdempotentResponse{StatusCode: http.StatusCreated}
+
+	_, err := keys.Reserve(organisationID, "key-1", "fingerprint")
+	assert.NoError(t, err)
+	assert.NoError(t, keys.Complete(organisationID, "key-1", response))
+	_, err = db.Exec(`UPDATE idempotency_keys SET created_at = now() - INTERVAL '2 minutes'
+WHERE organisation_id = $1 AND key = $2`, organisationID.String(), "key-1")
+	assert.NoError(t, err)
+
+	stored, err := keys.Reserve(organisationID, "key-1", "fingerprint")
+	assert.NoError(t, err)
+	assert.Equal(t, &response, stored)
+}
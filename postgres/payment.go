@@ -3,26 +3,63 @@ package postgres
 import (
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin/json"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 	"github.com/steinfletcher/payments"
 )
 
-const getQuery = `SELECT p.version, p.external_id, p.organisation_id, p.attributes
+const getQuery = `SELECT p.version, p.external_id, p.organisation_id, p.attributes, p.status, p.recorded_at,
+       p.recorded_by
 FROM payments p
          JOIN (
     SELECT MAX(version) as version, external_id
     FROM payments vp
     GROUP BY external_id) t
               ON t.external_id = p.external_id AND t.version = p.version
-WHERE p.deleted = FALSE %s`
+WHERE p.deleted = FALSE
+  AND p.organisation_id = $1 %s`
 
-const insertQuery = `INSERT INTO payments (external_id, attributes, organisation_id, version, deleted)
- VALUES ($1, $2, $3, $4, $5)`
+// listQuery selects the latest version of each payment in external ID order. The anti-join lets postgres
+// walk the external ID index and stop as soon as the page is full. The first verb restricts the later
+// versions considered by the anti-join when listing payments as they were at a point in time.
+const listQuery = `SELECT p.version, p.external_id, p.organisation_id, p.attributes, p.status, p.recorded_at,
+       p.recorded_by
+FROM payments p
+WHERE p.deleted = FALSE
+  AND NOT EXISTS(SELECT 1 FROM payments n WHERE n.external_id = p.external_id AND n.version > p.version %s) %s
+ORDER BY p.external_id %s
+LIMIT $%d`
+
+// versionsQuery selects the stored versions of a payment, including the tombstone of a deleted payment
+const versionsQuery = `SELECT version, external_id, organisation_id, attributes, status,
+       COALESCE(deleted, FALSE) AS deleted, recorded_at, recorded_by
+FROM payments
+WHERE external_id = $1
+  AND organisation_id = $2 %s
+ORDER BY version`
+
+// asOfQuery selects the latest version of a payment recorded at or before the given time
+const asOfQuery = `SELECT version, external_id, organisation_id, attributes, status,
+       COALESCE(deleted, FALSE) AS deleted, recorded_at, recorded_by
+FROM payments
+WHERE external_id = $1
+  AND organisation_id = $2
+  AND recorded_at <= $3
+ORDER BY version DESC
+LIMIT 1`
+
+const dateFormat = "2006-01-02"
+
+const insertQuery = `INSERT INTO payments (external_id, attributes, organisation_id, version, deleted, status,
+                      recorded_by)
+ VALUES ($1, $2, $3, $4, $5, $6, $7)`
 
 type paymentRepository struct {
 	db *sqlx.DB
@@ -33,23 +70,133 @@ type paymentRecord struct {
 	ExternalID     string         `db:"external_id"`
 	OrganisationID string         `db:"organisation_id"`
 	Attributes     types.JSONText `db:"attributes"`
+	Status         string         `db:"status"`
+	Deleted        bool           `db:"deleted"`
+	RecordedAt     time.Time      `db:"recorded_at"`
+	RecordedBy     string         `db:"recorded_by"`
 }
 
-func (r *paymentRepository) GetAll() (acme.Payments, error) {
+func (r *paymentRepository) GetAll(organisationID uuid.UUID) (acme.Payments, error) {
 	var p []paymentRecord
-	err := withTx(r.db, func(tx *sql.Tx) error {
-		return r.db.Select(&p, fmt.Sprintf(getQuery, ""))
+	err := withOrganisation(r.db, organisationID, func(tx *sqlx.Tx) error {
+		err := tx.Select(&p, fmt.Sprintf(getQuery, ""), organisationID.String())
+		if err != nil {
+			return errors.WithStack(acme.ServerError)
+		}
+		return nil
 	})
 	if err != nil {
 		return acme.Payments{}, err
 	}
-	return mapPayments(p), nil
+	return mapPayments(p)
 }
 
-func (r *paymentRepository) Get(id uuid.UUID) (acme.Payment, error) {
+func (r *paymentRepository) List(organisationID uuid.UUID, query acme.PaymentQuery) (acme.PaymentPage, error) {
+	where := filterConditions(query.Filter)
+	where.add("p.organisation_id = $%d", organisationID.String())
+	later := ""
+	if !query.AsOf.IsZero() {
+		where.add("p.recorded_at <= $%d", query.AsOf)
+		later = fmt.Sprintf("AND n.recorded_at <= $%d", len(where.args))
+	}
+	order := "ASC"
+	if query.Cursor.ID != uuid.Nil {
+		if query.Cursor.Before {
+			where.add("p.external_id < $%d", query.Cursor.ID.String())
+			order = "DESC"
+		} else {
+			where.add("p.external_id > $%d", query.Cursor.ID.String())
+		}
+	}
+	args := where.args
+	// fetch one extra row to find out if there is another page
+	args = append(args, query.Limit+1)
+
+	var records []paymentRecord
+	err := withOrganisation(r.db, organisationID, func(tx *sqlx.Tx) error {
+		err := tx.Select(&records, fmt.Sprintf(listQuery, later, where, order, len(args)), args...)
+		if err != nil {
+			return errors.WithStack(acme.ServerError)
+		}
+		return nil
+	})
+	if err != nil {
+		return acme.PaymentPage{}, err
+	}
+
+	more := len(records) > query.Limit
+	if more {
+		records = records[:query.Limit]
+	}
+	if query.Cursor.Before {
+		for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+			records[i], records[j] = records[j], records[i]
+		}
+	}
+
+	payments, err := mapPayments(records)
+	if err != nil {
+		return acme.PaymentPage{}, err
+	}
+
+	page := acme.PaymentPage{Payments: payments.Data}
+	if len(page.Payments) == 0 {
+		return page, nil
+	}
+	first, last := page.Payments[0].ID, page.Payments[len(page.Payments)-1].ID
+	if query.Cursor.Before {
+		page.Next = acme.CursorAfter(last)
+		if more {
+			page.Prev = acme.CursorBefore(first)
+		}
+	} else {
+		if more {
+			page.Next = acme.CursorAfter(last)
+		}
+		if query.Cursor.ID != uuid.Nil {
+			page.Prev = acme.CursorBefore(first)
+		}
+	}
+	return page, nil
+}
+
+func (r *paymentRepository) Get(organisationID, id uuid.UUID) (acme.Payment, error) {
+	var payment acme.Payment
+	err := withOrganisation(r.db, organisationID, func(tx *sqlx.Tx) error {
+		var err error
+		payment, err = getPayment(tx, organisationID, id)
+		return err
+	})
+	return payment, err
+}
+
+// getPayment selects the latest version of a payment of the organisation
+func getPayment(tx *sqlx.Tx, organisationID, id uuid.UUID) (acme.Payment, error) {
 	var p paymentRecord
-	err := withTx(r.db, func(tx *sql.Tx) error {
-		err := r.db.Get(&p, fmt.Sprintf(getQuery, fmt.Sprintf("AND p.external_id = '%s'", id)))
+	err := tx.Get(&p, fmt.Sprintf(getQuery, "AND p.external_id = $2"), organisationID.String(), id.String())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return acme.Payment{}, acme.PaymentNotFound
+		}
+		return acme.Payment{}, errors.WithStack(acme.ServerError)
+	}
+	return mapPayment(p)
+}
+
+// lockPayment makes the transactions that decide about a payment wait for each other until the end of the
+// transaction, so that each one reads the versions and the approvals that the one before it committed
+func lockPayment(tx *sqlx.Tx, id uuid.UUID) error {
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", id.String())
+	if err != nil {
+		return errors.WithStack(acme.ServerError)
+	}
+	return nil
+}
+
+func (r *paymentRepository) GetAsOf(organisationID, id uuid.UUID, asOf time.Time) (acme.Payment, error) {
+	var record paymentRecord
+	err := withOrganisation(r.db, organisationID, func(tx *sqlx.Tx) error {
+		err := tx.Get(&record, asOfQuery, id.String(), organisationID.String(), asOf)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				return acme.PaymentNotFound
@@ -61,94 +208,317 @@ func (r *paymentRepository) Get(id uuid.UUID) (acme.Payment, error) {
 	if err != nil {
 		return acme.Payment{}, err
 	}
-	return mapPayment(p), nil
+	// the payment had been deleted by then
+	if record.Deleted {
+		return acme.Payment{}, acme.PaymentNotFound
+	}
+	return mapPayment(record)
 }
 
-func (r *paymentRepository) Create(p acme.Payment) (uuid.UUID, error) {
-	newID := uuid.New()
-	attributes, err := json.Marshal(p.Attributes)
+func (r *paymentRepository) GetVersions(organisationID, id uuid.UUID) ([]acme.Payment, error) {
+	var records []paymentRecord
+	err := withOrganisation(r.db, organisationID, func(tx *sqlx.Tx) error {
+		err := tx.Select(&records, fmt.Sprintf(versionsQuery, ""), id.String(), organisationID.String())
+		if err != nil {
+			return errors.WithStack(acme.ServerError)
+		}
+		return nil
+	})
 	if err != nil {
-		return newID, acme.ServerError
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, acme.PaymentNotFound
+	}
+
+	payments, err := mapPayments(records)
+	if err != nil {
+		return nil, err
 	}
+	return payments.Data, nil
+}
 
-	err = withTx(r.db, func(tx *sql.Tx) error {
-		_, err := r.db.Exec(insertQuery, newID, attributes, p.OrganisationID, p.Version, false)
+func (r *paymentRepository) GetVersion(organisationID, id uuid.UUID, version int) (acme.Payment, error) {
+	var record paymentRecord
+	err := withOrganisation(r.db, organisationID, func(tx *sqlx.Tx) error {
+		err := tx.Get(&record, fmt.Sprintf(versionsQuery, "AND version = $3"), id.String(), organisationID.String(),
+			version)
 		if err != nil {
+			if err == sql.ErrNoRows {
+				return acme.PaymentVersionNotFound
+			}
 			return errors.WithStack(acme.ServerError)
 		}
 		return nil
 	})
+	if err != nil {
+		return acme.Payment{}, err
+	}
+	return mapPayment(record)
+}
+
+func (r *paymentRepository) Diff(organisationID, id uuid.UUID, from, to int) (acme.Changes, error) {
+	fromPayment, err := r.GetVersion(organisationID, id, from)
+	if err != nil {
+		return acme.Changes{}, err
+	}
+	toPayment, err := r.GetVersion(organisationID, id, to)
+	if err != nil {
+		return acme.Changes{}, err
+	}
+
+	changes, err := acme.Diff(fromPayment, toPayment)
+	if err != nil {
+		return acme.Changes{}, errors.WithStack(acme.ServerError)
+	}
+	return acme.Changes{From: from, To: to, Data: changes}, nil
+}
+
+func (r *paymentRepository) Create(organisationID uuid.UUID, p acme.Payment) (uuid.UUID, error) {
+	newID := uuid.New()
+	attributes, err := json.Marshal(p.Attributes)
+	if err != nil {
+		return newID, acme.ServerError
+	}
+
+	err = withOrganisation(r.db, organisationID, func(tx *sqlx.Tx) error {
+		return insertPayment(tx, newID, attributes, organisationID, p.Version, false, acme.StatusPending,
+			p.RecordedBy)
+	})
 	return newID, err
 }
 
-func (r *paymentRepository) Update(id uuid.UUID, updatedPayment acme.Payment) error {
-	return withTx(r.db, func(tx *sql.Tx) error {
-		payment, err := r.Get(id)
+func (r *paymentRepository) Update(organisationID, id uuid.UUID, updatedPayment acme.Payment) (int, error) {
+	attributes, err := json.Marshal(updatedPayment.Attributes)
+	if err != nil {
+		return 0, errors.WithStack(acme.ServerError)
+	}
+
+	var version int
+	err = withOrganisation(r.db, organisationID, func(tx *sqlx.Tx) error {
+		payment, err := getPayment(tx, organisationID, id)
 		if err != nil {
 			return err
 		}
-
-		result, err := r.db.Exec(insertQuery, payment.ID, updatedPayment.Attributes, updatedPayment.OrganisationID,
-			payment.Version+1, false)
-		if err != nil {
-			return errors.WithStack(acme.ServerError)
+		if updatedPayment.Version != acme.AnyVersion && updatedPayment.Version != payment.Version {
+			return acme.VersionConflict
 		}
-
-		rows, _ := result.RowsAffected()
-		if rows <= 0 {
-			return acme.PaymentNotFound
+		if payment.Status != acme.StatusPending {
+			return acme.PaymentNotPending
 		}
-		return nil
+
+		version = payment.Version + 1
+		return insertPayment(tx, payment.ID, attributes, organisationID, version, false, payment.Status,
+			updatedPayment.RecordedBy)
 	})
+	return version, err
 }
 
-func (r *paymentRepository) Delete(id uuid.UUID) error {
-	return withTx(r.db, func(tx *sql.Tx) error {
-		payment, err := r.Get(id)
+func (r *paymentRepository) Delete(organisationID, id uuid.UUID, version int, recordedBy string) error {
+	return withOrganisation(r.db, organisationID, func(tx *sqlx.Tx) error {
+		payment, err := getPayment(tx, organisationID, id)
 		if err != nil {
 			return err
 		}
+		if version != acme.AnyVersion && version != payment.Version {
+			return acme.VersionConflict
+		}
+		// a payment that has left pending is part of the audit trail of what was sent to the scheme
+		if payment.Status != acme.StatusPending {
+			return acme.PaymentNotPending
+		}
 
-		result, err := r.db.Exec(insertQuery, payment.ID, payment.Attributes, payment.OrganisationID, payment.Version+1, true)
+		attributes, err := json.Marshal(payment.Attributes)
 		if err != nil {
 			return errors.WithStack(acme.ServerError)
 		}
+		return insertPayment(tx, payment.ID, attributes, payment.OrganisationID, payment.Version+1, true,
+			payment.Status, recordedBy)
+	})
+}
 
-		rows, _ := result.RowsAffected()
-		if rows <= 0 {
-			return acme.PaymentNotFound
+// Transition takes an action on a payment. A payment is only approved once it has the quorum of approvals
+// recorded by the approval repository.
+func (r *paymentRepository) Transition(organisationID, id uuid.UUID, action acme.Action, version int,
+	recordedBy string) (acme.Payment, error) {
+	var payment acme.Payment
+	err := withOrganisation(r.db, organisationID, func(tx *sqlx.Tx) error {
+		var err error
+		payment, err = getPayment(tx, organisationID, id)
+		if err != nil {
+			return err
 		}
-		return nil
+		if version != acme.AnyVersion && version != payment.Version {
+			return acme.VersionConflict
+		}
+		if action == acme.ActionApprove && payment.Status == acme.StatusPending {
+			reached, err := quorumReached(tx, payment)
+			if err != nil {
+				return err
+			}
+			if !reached {
+				return acme.ApprovalRequired
+			}
+		}
+		payment, err = transitionPayment(tx, payment, action, recordedBy)
+		return err
 	})
+	if err != nil {
+		return acme.Payment{}, err
+	}
+	return payment, nil
+}
+
+// transitionPayment stores the next version of a payment with the status the action moves it to and
+// returns it
+func transitionPayment(tx *sqlx.Tx, payment acme.Payment, action acme.Action, recordedBy string) (acme.Payment,
+	error) {
+	status, err := payment.Status.Next(action)
+	if err != nil {
+		return acme.Payment{}, err
+	}
+
+	attributes, err := json.Marshal(payment.Attributes)
+	if err != nil {
+		return acme.Payment{}, errors.WithStack(acme.ServerError)
+	}
+	err = insertPayment(tx, payment.ID, attributes, payment.OrganisationID, payment.Version+1, false,
+		status, recordedBy)
+	if err != nil {
+		return acme.Payment{}, err
+	}
+	return getPayment(tx, payment.OrganisationID, payment.ID)
+}
+
+// insertPayment stores a version of a payment. Concurrent writers that read the same version both try to
+// insert the next version, so the unique index on the external ID and version lets only the first succeed.
+func insertPayment(tx *sqlx.Tx, id uuid.UUID, attributes []byte, organisationID uuid.UUID, version int,
+	deleted bool, status acme.Status, recordedBy string) error {
+	_, err := tx.Exec(insertQuery, id, attributes, organisationID, version, deleted, string(status), recordedBy)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			return acme.VersionConflict
+		}
+		return errors.WithStack(acme.ServerError)
+	}
+	return nil
+}
+
+// amountExpression is the numeric amount of a payment, or NULL if the stored amount is not a plain decimal
+const amountExpression = `(CASE WHEN p.attributes->>'amount' ~ '^[0-9]+(\.[0-9]+)?$' THEN (p.attributes->>'amount')::NUMERIC END)`
+
+// filterConditions pushes a payment filter down into SQL conditions over the JSON attributes
+func filterConditions(filter acme.PaymentFilter) *conditions {
+	where := &conditions{}
+	if filter.OrganisationID != uuid.Nil {
+		where.add("p.organisation_id = $%d", filter.OrganisationID.String())
+	}
+	if filter.Currency != "" {
+		where.add("p.attributes->>'currency' = $%d", filter.Currency)
+	}
+	if filter.PaymentScheme != "" {
+		where.add("p.attributes->>'payment_scheme' = $%d", filter.PaymentScheme)
+	}
+	if filter.PaymentType != "" {
+		where.add("p.attributes->>'payment_type' = $%d", filter.PaymentType)
+	}
+	if !filter.ProcessedFrom.IsZero() {
+		where.add("p.attributes->>'processing_date' >= $%d", filter.ProcessedFrom.Format(dateFormat))
+	}
+	if !filter.ProcessedTo.IsZero() {
+		where.add("p.attributes->>'processing_date' <= $%d", filter.ProcessedTo.Format(dateFormat))
+	}
+	if filter.AmountMin != nil {
+		where.add(amountExpression+" >= $%d::NUMERIC", filter.AmountMin.String())
+	}
+	if filter.AmountMax != nil {
+		where.add(amountExpression+" <= $%d::NUMERIC", filter.AmountMax.String())
+	}
+	if filter.Status != "" {
+		where.add("p.status = $%d", string(filter.Status))
+	}
+	if filter.Reference != "" {
+		where.add("p.attributes->>'reference' ILIKE $%d", "%"+likeEscaper.Replace(filter.Reference)+"%")
+	}
+	return where
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// conditions builds the AND conditions of a WHERE clause with numbered placeholders
+type conditions struct {
+	clauses []string
+	args    []interface{}
+}
+
+// add appends a condition, where the clause has a single $%d verb for the placeholder of arg
+func (c *conditions) add(clause string, arg interface{}) {
+	c.args = append(c.args, arg)
+	c.clauses = append(c.clauses, fmt.Sprintf(clause, len(c.args)))
+}
+
+func (c *conditions) String() string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+	return "AND " + strings.Join(c.clauses, " AND ")
 }
 
 func NewPaymentRepository(db *sqlx.DB) acme.PaymentService {
 	return &paymentRepository{db}
 }
 
-func mapPayments(dbRecords []paymentRecord) acme.Payments {
+func mapPayments(dbRecords []paymentRecord) (acme.Payments, error) {
 	payments := []acme.Payment{}
 	for _, v := range dbRecords {
-		payments = append(payments, mapPayment(v))
+		payment, err := mapPayment(v)
+		if err != nil {
+			return acme.Payments{}, err
+		}
+		payments = append(payments, payment)
 	}
 	return acme.Payments{
 		Data: payments,
-	}
+	}, nil
 }
 
-func mapPayment(dbRecord paymentRecord) acme.Payment {
+func mapPayment(dbRecord paymentRecord) (acme.Payment, error) {
+	var attributes acme.Attributes
+	err := dbRecord.Attributes.Unmarshal(&attributes)
+	if err != nil {
+		return acme.Payment{}, errors.WithStack(acme.ServerError)
+	}
+
 	return acme.Payment{
 		ID:             uuid.MustParse(dbRecord.ExternalID),
 		Version:        dbRecord.Version,
 		OrganisationID: uuid.MustParse(dbRecord.OrganisationID),
-		Attributes:     dbRecord.Attributes,
-	}
+		Attributes:     attributes,
+		Status:         acme.Status(dbRecord.Status),
+		RecordedAt:     dbRecord.RecordedAt,
+		RecordedBy:     dbRecord.RecordedBy,
+		Deleted:        dbRecord.Deleted,
+	}, nil
+}
+
+// withOrganisation runs fn in a transaction that can only see the payments of the organisation. The
+// queries filter on the organisation themselves, and the setting also enables the row level security
+// policy for database roles that are subject to it.
+func withOrganisation(db *sqlx.DB, organisationID uuid.UUID, fn func(*sqlx.Tx) error) error {
+	return withTx(db, func(tx *sqlx.Tx) error {
+		_, err := tx.Exec("SELECT set_config('app.organisation_id', $1, true)", organisationID.String())
+		if err != nil {
+			return errors.WithStack(acme.ServerError)
+		}
+		return fn(tx)
+	})
 }
 
 // withTx encapsulates transaction concerns such as rollbacks and commit.
 // This helps decouple lower level transaction handling from business logic.
-func withTx(db *sqlx.DB, fn func(*sql.Tx) error) error {
-	tx, err := db.Begin()
+func withTx(db *sqlx.DB, fn func(*sqlx.Tx) error) (err error) {
+	tx, err := db.Beginx()
 	if err != nil {
 		return errors.WithStack(acme.ServerError)
 	}
@@ -163,7 +533,9 @@ func withTx(db *sqlx.DB, fn func(*sql.Tx) error) error {
 			tx.Rollback()
 		} else {
 			// all good, commit
-			err = tx.Commit()
+			if tx.Commit() != nil {
+				err = errors.WithStack(acme.ServerError)
+			}
 		}
 	}()
 
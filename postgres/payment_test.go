@@ -1,12 +1,14 @@
 package postgres_test
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
-	"github.com/jmoiron/sqlx/types"
 	"github.com/steinfletcher/payments"
 	_ "github.com/steinfletcher/payments/migrations"
 	"github.com/steinfletcher/payments/postgres"
@@ -20,28 +22,30 @@ func TestCreatePayment(t *testing.T) {
 	organisationID := uuid.New()
 	repository := postgres.NewPaymentRepository(db)
 
-	id, err := repository.Create(acme.Payment{
+	id, err := repository.Create(organisationID, acme.Payment{
 		OrganisationID: organisationID,
-		Attributes:     types.JSONText(`{"key":"value"}`),
+		Attributes:     acme.Attributes{Amount: "100.21", Currency: "GBP", Extra: extra("key", "value")},
 		Version:        0,
+		RecordedBy:     "test",
 	})
 	assert.NoError(t, err)
-	payment, err := repository.Get(id)
+	payment, err := repository.Get(organisationID, id)
 
 	assert.NoError(t, err)
 	assert.Equal(t, acme.Payment{
 		ID:             id,
 		Version:        0,
 		OrganisationID: organisationID,
-		Attributes:     types.JSONText(`{"key":"value"}`),
-	}, payment)
+		Attributes:     acme.Attributes{Amount: "100.21", Currency: "GBP", Extra: extra("key", "value")},
+		Status:         acme.StatusPending,
+		RecordedBy:     "test",
+	}, withoutRecordedAt(t, payment))
 }
 
 func TestUpdatePayment(t *testing.T) {
 	test.SkipIntegration(t)
 	externalID := uuid.New()
 	organisationID := uuid.New()
-	updatedOrganisationID := uuid.MustParse("efa9c7a2-5bc7-461b-a977-20853c7221cd")
 	db := test.DBSetup(func(tx *sqlx.Tx) {
 		query := `INSERT INTO payments (external_id, attributes, version, organisation_id) VALUES
 				('%s', '{"key": "value"}', 0, '%s')`
@@ -49,53 +53,229 @@ func TestUpdatePayment(t *testing.T) {
 	})
 	repository := postgres.NewPaymentRepository(db)
 
-	err := repository.Update(externalID, acme.Payment{
-		OrganisationID: updatedOrganisationID,
-		Attributes:     types.JSONText(`{"key":"newValue"}`),
+	version, err := repository.Update(organisationID, externalID, acme.Payment{
+		Version:        0,
+		OrganisationID: organisationID,
+		Attributes:     acme.Attributes{Extra: extra("key", "newValue")},
 	})
 	assert.NoError(t, err)
-	payment, err := repository.Get(externalID)
+	assert.Equal(t, 1, version)
+	payment, err := repository.Get(organisationID, externalID)
 
 	assert.NoError(t, err)
 	assert.Equal(t, acme.Payment{
 		ID:             externalID,
 		Version:        1,
-		OrganisationID: updatedOrganisationID,
-		Attributes:     types.JSONText(`{"key":"newValue"}`),
-	}, payment)
+		OrganisationID: organisationID,
+		Attributes:     acme.Attributes{Extra: extra("key", "newValue")},
+		Status:         acme.StatusPending,
+	}, withoutRecordedAt(t, payment))
+}
+
+func TestUpdatePayment_VersionConflict(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID := uuid.New()
+	organisationID := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id) VALUES
+				('%s', '{"key": "value"}', %d, '%s')`
+		tx.MustExec(fmt.Sprintf(query, externalID, 0, organisationID))
+		tx.MustExec(fmt.Sprintf(query, externalID, 1, organisationID))
+	})
+	repository := postgres.NewPaymentRepository(db)
+
+	_, err := repository.Update(organisationID, externalID, acme.Payment{
+		Version:        0,
+		OrganisationID: organisationID,
+		Attributes:     acme.Attributes{Extra: extra("key", "newValue")},
+	})
+
+	assert.EqualError(t, err, acme.VersionConflict.Code)
+	payment, err := repository.Get(organisationID, externalID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, payment.Version)
+}
+
+func TestUpdatePayment_ConcurrentUpdatesConflict(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID := uuid.New()
+	organisationID := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id) VALUES
+				('%s', '{"key": "value"}', 0, '%s')`
+		tx.MustExec(fmt.Sprintf(query, externalID, organisationID))
+	})
+	repository := postgres.NewPaymentRepository(db)
+
+	errs := make(chan error)
+	for i := 0; i < 5; i++ {
+		go func(i int) {
+			_, err := repository.Update(organisationID, externalID, acme.Payment{
+				Version:        acme.AnyVersion,
+				OrganisationID: organisationID,
+				Attributes:     acme.Attributes{Extra: extra("key", strconv.Itoa(i))},
+			})
+			errs <- err
+		}(i)
+	}
+
+	// every update either succeeds or reports a conflict, never a server error
+	for i := 0; i < 5; i++ {
+		if err := <-errs; err != nil {
+			assert.EqualError(t, err, acme.VersionConflict.Code)
+		}
+	}
 }
 
 func TestDeletePayment_MarksThePaymentDeleted(t *testing.T) {
 	test.SkipIntegration(t)
 	externalID := uuid.New()
+	organisationID := uuid.New()
 	db := test.DBSetup(func(tx *sqlx.Tx) {
 		query := `INSERT INTO payments (external_id, attributes, version, organisation_id) VALUES
 				('%s', '{"key": "value"}', 0, '%s')`
-		tx.MustExec(fmt.Sprintf(query, externalID, uuid.New()))
+		tx.MustExec(fmt.Sprintf(query, externalID, organisationID))
 	})
 	payments := postgres.NewPaymentRepository(db)
 
-	err := payments.Delete(externalID)
+	err := payments.Delete(organisationID, externalID, 0, "test")
 
 	assert.NoError(t, err)
-	_, err = payments.Get(externalID)
+	_, err = payments.Get(organisationID, externalID)
 	assert.EqualError(t, err, acme.PaymentNotFound.Code)
 }
 
+func TestDeletePayment_VersionConflict(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID := uuid.New()
+	organisationID := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id) VALUES
+				('%s', '{"key": "value"}', 0, '%s')`
+		tx.MustExec(fmt.Sprintf(query, externalID, organisationID))
+	})
+	payments := postgres.NewPaymentRepository(db)
+
+	err := payments.Delete(organisationID, externalID, 3, "test")
+
+	assert.EqualError(t, err, acme.VersionConflict.Code)
+	_, err = payments.Get(organisationID, externalID)
+	assert.NoError(t, err)
+}
+
+func TestDeletePayment_OnlyPending(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID := uuid.New()
+	organisationID := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id, status) VALUES
+				('%s', '{"key": "value"}', 0, '%s', 'submitted')`
+		tx.MustExec(fmt.Sprintf(query, externalID, organisationID))
+	})
+	payments := postgres.NewPaymentRepository(db)
+
+	err := payments.Delete(organisationID, externalID, acme.AnyVersion, "test")
+
+	assert.EqualError(t, err, acme.PaymentNotPending.Code)
+	_, err = payments.Get(organisationID, externalID)
+	assert.NoError(t, err)
+}
+
 func TestDeletePayment_ReportsPaymentNotFound(t *testing.T) {
 	test.SkipIntegration(t)
 	db := test.DBSetup(func(tx *sqlx.Tx) {})
 
-	err := postgres.NewPaymentRepository(db).Delete(uuid.New())
+	err := postgres.NewPaymentRepository(db).Delete(uuid.New(), uuid.New(), acme.AnyVersion, "test")
 
 	assert.EqualError(t, err, acme.PaymentNotFound.Code)
 }
 
+func TestTransitionPayment_RecordsNewVersion(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID := uuid.New()
+	organisationID := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id) VALUES
+				('%s', '{"key": "value"}', 0, '%s')`
+		tx.MustExec(fmt.Sprintf(query, externalID, organisationID))
+	})
+	repository := postgres.NewPaymentRepository(db)
+
+	payment, err := repository.Transition(organisationID, externalID, acme.ActionApprove, 0, "approver")
+
+	assert.NoError(t, err)
+	assert.Equal(t, acme.Payment{
+		ID:             externalID,
+		Version:        1,
+		OrganisationID: organisationID,
+		Attributes:     acme.Attributes{Extra: extra("key", "value")},
+		Status:         acme.StatusApproved,
+		RecordedBy:     "approver",
+	}, withoutRecordedAt(t, payment))
+	versions, err := repository.GetVersions(organisationID, externalID)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, acme.StatusPending, versions[0].Status)
+}
+
+func TestTransitionPayment_InvalidTransition(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID := uuid.New()
+	organisationID := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id, status) VALUES
+				('%s', '{"key": "value"}', 0, '%s', 'settled')`
+		tx.MustExec(fmt.Sprintf(query, externalID, organisationID))
+	})
+	repository := postgres.NewPaymentRepository(db)
+
+	_, err := repository.Transition(organisationID, externalID, acme.ActionCancel, acme.AnyVersion, "test")
+
+	assert.EqualError(t, err, acme.InvalidTransition.Code)
+	payment, err := repository.Get(organisationID, externalID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, payment.Version)
+}
+
+func TestTransitionPayment_VersionConflict(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID := uuid.New()
+	organisationID := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id) VALUES
+				('%s', '{"key": "value"}', 0, '%s')`
+		tx.MustExec(fmt.Sprintf(query, externalID, organisationID))
+	})
+
+	_, err := postgres.NewPaymentRepository(db).Transition(organisationID, externalID, acme.ActionApprove, 3, "test")
+
+	assert.EqualError(t, err, acme.VersionConflict.Code)
+}
+
+func TestUpdatePayment_OnlyPending(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID := uuid.New()
+	organisationID := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id, status) VALUES
+				('%s', '{"key": "value"}', 0, '%s', 'approved')`
+		tx.MustExec(fmt.Sprintf(query, externalID, organisationID))
+	})
+
+	_, err := postgres.NewPaymentRepository(db).Update(organisationID, externalID, acme.Payment{
+		Version:        acme.AnyVersion,
+		OrganisationID: organisationID,
+		Attributes:     acme.Attributes{Extra: extra("key", "newValue")},
+	})
+
+	assert.EqualError(t, err, acme.PaymentNotPending.Code)
+}
+
 func TestGetPayments_EmptyListIfNoPayments(t *testing.T) {
 	test.SkipIntegration(t)
 	db := test.DBSetup(func(tx *sqlx.Tx) {})
 
-	payments, err := postgres.NewPaymentRepository(db).GetAll()
+	payments, err := postgres.NewPaymentRepository(db).GetAll(uuid.New())
 
 	assert.NoError(t, err)
 	assert.Empty(t, payments.Data)
@@ -103,13 +283,14 @@ func TestGetPayments_EmptyListIfNoPayments(t *testing.T) {
 
 func TestGetPayments_DoesNotReturnDeletedPayments(t *testing.T) {
 	test.SkipIntegration(t)
+	organisationID := uuid.New()
 	db := test.DBSetup(func(tx *sqlx.Tx) {
 		query := `INSERT INTO payments (external_id, attributes, version, organisation_id, deleted) VALUES
 				('%s', '{"key": "valueOriginal"}', 0, '%s', true)`
-		tx.MustExec(fmt.Sprintf(query, uuid.New(), uuid.New()))
+		tx.MustExec(fmt.Sprintf(query, uuid.New(), organisationID))
 	})
 
-	payments, err := postgres.NewPaymentRepository(db).GetAll()
+	payments, err := postgres.NewPaymentRepository(db).GetAll(organisationID)
 
 	assert.NoError(t, err)
 	assert.Empty(t, payments.Data)
@@ -129,19 +310,252 @@ func TestGetPayments_ReturnsLatestVersion(t *testing.T) {
 		tx.MustExec(fmt.Sprintf(v1, externalID, organisationID))
 	})
 
-	payments, err := postgres.NewPaymentRepository(db).GetAll()
+	payments, err := postgres.NewPaymentRepository(db).GetAll(organisationID)
 
 	assert.NoError(t, err)
-	assert.Equal(t, acme.Payments{
-		Data: []acme.Payment{
-			{
-				ID:             externalID,
-				Version:        1,
-				OrganisationID: organisationID,
-				Attributes:     types.JSONText(`{"key":"valueUpdated"}`),
-			},
+	assert.Len(t, payments.Data, 1)
+	assert.Equal(t, acme.Payment{
+		ID:             externalID,
+		Version:        1,
+		OrganisationID: organisationID,
+		Attributes:     acme.Attributes{Extra: extra("key", "valueUpdated")},
+		Status:         acme.StatusPending,
+	}, withoutRecordedAt(t, payments.Data[0]))
+}
+
+func TestListPayments_PagesInIDOrder(t *testing.T) {
+	test.SkipIntegration(t)
+	ids := []uuid.UUID{
+		uuid.MustParse("1f2d3c4b-0000-4000-8000-000000000001"),
+		uuid.MustParse("2f2d3c4b-0000-4000-8000-000000000002"),
+		uuid.MustParse("3f2d3c4b-0000-4000-8000-000000000003"),
+	}
+	organisationID := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id) VALUES
+				('%s', '{"key":"value"}', 0, '%s')`
+		for _, id := range []uuid.UUID{ids[2], ids[0], ids[1]} {
+			tx.MustExec(fmt.Sprintf(query, id, organisationID))
+		}
+	})
+	repository := postgres.NewPaymentRepository(db)
+
+	first, err := repository.List(organisationID, acme.PaymentQuery{Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{ids[0], ids[1]}, paymentIDs(first))
+	assert.Equal(t, acme.CursorAfter(ids[1]), first.Next)
+	assert.Nil(t, first.Prev)
+
+	second, err := repository.List(organisationID, acme.PaymentQuery{Limit: 2, Cursor: *first.Next})
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{ids[2]}, paymentIDs(second))
+	assert.Nil(t, second.Next)
+	assert.Equal(t, acme.CursorBefore(ids[2]), second.Prev)
+
+	previous, err := repository.List(organisationID, acme.PaymentQuery{Limit: 2, Cursor: *second.Prev})
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{ids[0], ids[1]}, paymentIDs(previous))
+	assert.Equal(t, acme.CursorAfter(ids[1]), previous.Next)
+	assert.Nil(t, previous.Prev)
+}
+
+func TestListPayments_ReturnsLatestVersionAndSkipsDeleted(t *testing.T) {
+	test.SkipIntegration(t)
+	updatedID, deletedID := uuid.New(), uuid.New()
+	organisationID := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id, deleted) VALUES
+				('%s', '{"key":"%s"}', %d, '%s', %t)`
+		tx.MustExec(fmt.Sprintf(query, updatedID, "valueOriginal", 0, organisationID, false))
+		tx.MustExec(fmt.Sprintf(query, updatedID, "valueUpdated", 1, organisationID, false))
+		tx.MustExec(fmt.Sprintf(query, deletedID, "value", 0, organisationID, false))
+		tx.MustExec(fmt.Sprintf(query, deletedID, "value", 1, organisationID, true))
+	})
+
+	page, err := postgres.NewPaymentRepository(db).List(organisationID, acme.PaymentQuery{Limit: 10})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Payments, 1)
+	assert.Equal(t, acme.Payment{
+		ID:             updatedID,
+		Version:        1,
+		OrganisationID: organisationID,
+		Attributes:     acme.Attributes{Extra: extra("key", "valueUpdated")},
+		Status:         acme.StatusPending,
+	}, withoutRecordedAt(t, page.Payments[0]))
+	assert.Nil(t, page.Next)
+}
+
+func TestListPayments_Filtered(t *testing.T) {
+	test.SkipIntegration(t)
+	organisationID := uuid.New()
+	matching := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id) VALUES ('%s', '%s', 0, '%s')`
+		tx.MustExec(fmt.Sprintf(query, matching, `{"amount":"100.21","currency":"GBP","payment_scheme":"FPS",
+			"payment_type":"Credit","processing_date":"2017-01-18","reference":"Payment for piano lessons"}`,
+			organisationID))
+		tx.MustExec(fmt.Sprintf(query, uuid.New(), `{"amount":"100.21","currency":"USD","payment_scheme":"FPS",
+			"payment_type":"Credit","processing_date":"2017-01-18","reference":"piano"}`, organisationID))
+		tx.MustExec(fmt.Sprintf(query, uuid.New(), `{"amount":"5000.00","currency":"GBP","payment_scheme":"FPS",
+			"payment_type":"Credit","processing_date":"2017-01-18","reference":"piano"}`, organisationID))
+		tx.MustExec(fmt.Sprintf(query, uuid.New(), `{"amount":"100.21","currency":"GBP","payment_scheme":"FPS",
+			"payment_type":"Credit","processing_date":"2017-03-01","reference":"piano"}`, organisationID))
+		tx.MustExec(fmt.Sprintf(query, uuid.New(), `{"amount":"100.21","currency":"GBP","payment_scheme":"FPS",
+			"payment_type":"Credit","processing_date":"2017-01-18","reference":"piano"}`, uuid.New()))
+		tx.MustExec(fmt.Sprintf(query, uuid.New(), `{"key":"value"}`, organisationID))
+	})
+	min, max := acme.NewDecimal(100, 0), acme.NewDecimal(1000, 0)
+
+	page, err := postgres.NewPaymentRepository(db).List(organisationID, acme.PaymentQuery{
+		Limit: 10,
+		Filter: acme.PaymentFilter{
+			OrganisationID: organisationID,
+			Currency:       "GBP",
+			PaymentScheme:  "FPS",
+			PaymentType:    "Credit",
+			ProcessedFrom:  time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+			ProcessedTo:    time.Date(2017, 1, 31, 0, 0, 0, 0, time.UTC),
+			AmountMin:      &min,
+			AmountMax:      &max,
+			Reference:      "PIANO",
 		},
-	}, payments)
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{matching}, paymentIDs(page))
+}
+
+func TestListPayments_ByStatus(t *testing.T) {
+	test.SkipIntegration(t)
+	organisationID := uuid.New()
+	approved := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id, status) VALUES
+				('%s', '{"key": "value"}', %d, '%s', '%s')`
+		tx.MustExec(fmt.Sprintf(query, approved, 0, organisationID, acme.StatusPending))
+		tx.MustExec(fmt.Sprintf(query, approved, 1, organisationID, acme.StatusApproved))
+		tx.MustExec(fmt.Sprintf(query, uuid.New(), 0, organisationID, acme.StatusPending))
+	})
+
+	page, err := postgres.NewPaymentRepository(db).List(organisationID, acme.PaymentQuery{
+		Limit:  10,
+		Filter: acme.PaymentFilter{Status: acme.StatusApproved},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{approved}, paymentIDs(page))
+}
+
+func TestListPayments_ReferenceWildcardsAreLiteral(t *testing.T) {
+	test.SkipIntegration(t)
+	organisationID := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id) VALUES
+				('%s', '{"reference":"100 percent"}', 0, '%s')`
+		tx.MustExec(fmt.Sprintf(query, uuid.New(), organisationID))
+	})
+
+	page, err := postgres.NewPaymentRepository(db).List(organisationID, acme.PaymentQuery{
+		Limit:  10,
+		Filter: acme.PaymentFilter{Reference: "100%"},
+	})
+
+	assert.NoError(t, err)
+	assert.Empty(t, page.Payments)
+}
+
+func TestGetPaymentVersions_IncludesTombstone(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID := uuid.New()
+	organisationID := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id) VALUES
+				('%s', '{"key": "value"}', 0, '%s')`
+		tx.MustExec(fmt.Sprintf(query, externalID, organisationID))
+	})
+	repository := postgres.NewPaymentRepository(db)
+	_, err := repository.Update(organisationID, externalID, acme.Payment{
+		Version:        0,
+		OrganisationID: organisationID,
+		Attributes:     acme.Attributes{Extra: extra("key", "newValue")},
+	})
+	assert.NoError(t, err)
+	err = repository.Delete(organisationID, externalID, 1, "test")
+	assert.NoError(t, err)
+
+	versions, err := repository.GetVersions(organisationID, externalID)
+
+	assert.NoError(t, err)
+	for i := range versions {
+		versions[i] = withoutRecordedAt(t, versions[i])
+	}
+	assert.Equal(t, []acme.Payment{
+		{ID: externalID, Version: 0, OrganisationID: organisationID, Attributes: acme.Attributes{Extra: extra("key", "value")}},
+		{ID: externalID, Version: 1, OrganisationID: organisationID, Attributes: acme.Attributes{Extra: extra("key", "newValue")}},
+		{ID: externalID, Version: 2, OrganisationID: organisationID, Attributes: acme.Attributes{Extra: extra("key", "newValue")}, Deleted: true},
+	}, versions)
+}
+
+func TestGetPaymentVersions_NotFound(t *testing.T) {
+	test.SkipIntegration(t)
+	db := test.DBSetup(func(tx *sqlx.Tx) {})
+
+	_, err := postgres.NewPaymentRepository(db).GetVersions(uuid.New(), uuid.New())
+
+	assert.EqualError(t, err, acme.PaymentNotFound.Code)
+}
+
+func TestGetPaymentVersion(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID := uuid.New()
+	organisationID := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id) VALUES
+				('%s', '{"key":"%s"}', %d, '%s')`
+		tx.MustExec(fmt.Sprintf(query, externalID, "valueOriginal", 0, organisationID))
+		tx.MustExec(fmt.Sprintf(query, externalID, "valueUpdated", 1, organisationID))
+	})
+	repository := postgres.NewPaymentRepository(db)
+
+	payment, err := repository.GetVersion(organisationID, externalID, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, acme.Payment{
+		ID:             externalID,
+		Version:        0,
+		OrganisationID: organisationID,
+		Attributes:     acme.Attributes{Extra: extra("key", "valueOriginal")},
+		Status:         acme.StatusPending,
+	}, withoutRecordedAt(t, payment))
+
+	_, err = repository.GetVersion(organisationID, externalID, 2)
+	assert.EqualError(t, err, acme.PaymentVersionNotFound.Code)
+}
+
+func TestDiffPaymentVersions(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID := uuid.New()
+	organisationID := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id) VALUES
+				('%s', '%s', %d, '%s')`
+		tx.MustExec(fmt.Sprintf(query, externalID, `{"amount":"100.21","currency":"GBP"}`, 0, organisationID))
+		tx.MustExec(fmt.Sprintf(query, externalID, `{"amount":"200.00","currency":"GBP"}`, 1, organisationID))
+	})
+
+	changes, err := postgres.NewPaymentRepository(db).Diff(organisationID, externalID, 0, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, acme.Changes{
+		From: 0,
+		To:   1,
+		Data: []acme.Change{{
+			Op:   "replace",
+			Path: "/attributes/amount",
+			Old:  json.RawMessage(`"100.21"`),
+			New:  json.RawMessage(`"200.00"`),
+		}},
+	}, changes)
 }
 
 func TestGetPayment_ByID(t *testing.T) {
@@ -154,14 +568,14 @@ func TestGetPayment_ByID(t *testing.T) {
 		tx.MustExec(fmt.Sprintf(query, externalID, organisationID))
 	})
 
-	payment, err := postgres.NewPaymentRepository(db).Get(externalID)
+	payment, err := postgres.NewPaymentRepository(db).Get(organisationID, externalID)
 
 	assert.NoError(t, err)
-	assert.Equal(t, payment, acme.Payment{
+	assert.Equal(t, withoutRecordedAt(t, payment), acme.Payment{
 		ID:             externalID,
 		Version:        0,
 		OrganisationID: organisationID,
-		Attributes:     types.JSONText(`{"key":"value"}`),
+		Attributes:     acme.Attributes{Extra: extra("key", "value")},
 	})
 }
 
@@ -169,7 +583,151 @@ func TestGetPayment_ByID_NotFound(t *testing.T) {
 	test.SkipIntegration(t)
 	db := test.DBSetup(func(tx *sqlx.Tx) {})
 
-	_, err := postgres.NewPaymentRepository(db).Get(uuid.New())
+	_, err := postgres.NewPaymentRepository(db).Get(uuid.New(), uuid.New())
+
+	assert.EqualError(t, err, acme.PaymentNotFound.Code)
+}
+
+func TestPayments_OtherOrganisationNotFound(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID := uuid.New()
+	organisationID, otherOrganisationID := uuid.New(), uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id) VALUES
+				('%s', '{"key":"value"}', 0, '%s')`
+		tx.MustExec(fmt.Sprintf(query, externalID, organisationID))
+	})
+	repository := postgres.NewPaymentRepository(db)
 
+	_, err := repository.Get(otherOrganisationID, externalID)
 	assert.EqualError(t, err, acme.PaymentNotFound.Code)
+	_, err = repository.GetAsOf(otherOrganisationID, externalID, time.Now())
+	assert.EqualError(t, err, acme.PaymentNotFound.Code)
+	_, err = repository.GetVersions(otherOrganisationID, externalID)
+	assert.EqualError(t, err, acme.PaymentNotFound.Code)
+	_, err = repository.GetVersion(otherOrganisationID, externalID, 0)
+	assert.EqualError(t, err, acme.PaymentVersionNotFound.Code)
+	_, err = repository.Update(otherOrganisationID, externalID, acme.Payment{
+		Version:        acme.AnyVersion,
+		OrganisationID: otherOrganisationID,
+		Attributes:     acme.Attributes{Extra: extra("key", "newValue")},
+	})
+	assert.EqualError(t, err, acme.PaymentNotFound.Code)
+	err = repository.Delete(otherOrganisationID, externalID, acme.AnyVersion, "test")
+	assert.EqualError(t, err, acme.PaymentNotFound.Code)
+
+	all, err := repository.GetAll(otherOrganisationID)
+	assert.NoError(t, err)
+	assert.Empty(t, all.Data)
+	page, err := repository.List(otherOrganisationID, acme.PaymentQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Payments)
+
+	payment, err := repository.Get(organisationID, externalID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, payment.Version)
+}
+
+func TestPayments_RowLevelSecurity(t *testing.T) {
+	test.SkipIntegration(t)
+	organisationID := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id) VALUES
+				('%s', '{"key":"value"}', 0, '%s')`
+		tx.MustExec(fmt.Sprintf(query, uuid.New(), organisationID))
+		tx.MustExec(fmt.Sprintf(query, uuid.New(), uuid.New()))
+	})
+
+	tx := db.MustBegin()
+	defer tx.Rollback()
+	// superusers bypass row level security, so the queries run as a role that is subject to it
+	tx.MustExec("CREATE ROLE payments_row_level_security NOLOGIN")
+	tx.MustExec("GRANT SELECT ON payments TO payments_row_level_security")
+	tx.MustExec("SET LOCAL ROLE payments_row_level_security")
+
+	var count int
+	err := tx.Get(&count, "SELECT COUNT(*) FROM payments")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	tx.MustExec("SELECT set_config('app.organisation_id', $1, true)", organisationID.String())
+	err = tx.Get(&count, "SELECT COUNT(*) FROM payments")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestGetPaymentAsOf(t *testing.T) {
+	test.SkipIntegration(t)
+	externalID := uuid.New()
+	organisationID := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id, deleted, recorded_at) VALUES
+				('%s', '{"key":"%s"}', %d, '%s', %t, '%s')`
+		tx.MustExec(fmt.Sprintf(query, externalID, "valueOriginal", 0, organisationID, false, "2019-05-01T09:00:00Z"))
+		tx.MustExec(fmt.Sprintf(query, externalID, "valueUpdated", 1, organisationID, false, "2019-05-02T09:00:00Z"))
+		tx.MustExec(fmt.Sprintf(query, externalID, "valueUpdated", 2, organisationID, true, "2019-05-03T09:00:00Z"))
+	})
+	repository := postgres.NewPaymentRepository(db)
+
+	payment, err := repository.GetAsOf(organisationID, externalID, time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, payment.Version)
+	assert.Equal(t, extra("key", "valueOriginal"), payment.Attributes.Extra)
+	assert.True(t, payment.RecordedAt.Equal(time.Date(2019, 5, 1, 9, 0, 0, 0, time.UTC)))
+
+	payment, err = repository.GetAsOf(organisationID, externalID, time.Date(2019, 5, 2, 9, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, payment.Version)
+
+	_, err = repository.GetAsOf(organisationID, externalID, time.Date(2019, 4, 30, 0, 0, 0, 0, time.UTC))
+	assert.EqualError(t, err, acme.PaymentNotFound.Code)
+
+	_, err = repository.GetAsOf(organisationID, externalID, time.Date(2019, 5, 4, 0, 0, 0, 0, time.UTC))
+	assert.EqualError(t, err, acme.PaymentNotFound.Code)
+}
+
+func TestListPayments_AsOf(t *testing.T) {
+	test.SkipIntegration(t)
+	updatedID, deletedID, laterID := uuid.New(), uuid.New(), uuid.New()
+	organisationID := uuid.New()
+	db := test.DBSetup(func(tx *sqlx.Tx) {
+		query := `INSERT INTO payments (external_id, attributes, version, organisation_id, deleted, recorded_at) VALUES
+				('%s', '{"key":"%s"}', %d, '%s', %t, '%s')`
+		tx.MustExec(fmt.Sprintf(query, updatedID, "valueOriginal", 0, organisationID, false, "2019-05-01T09:00:00Z"))
+		tx.MustExec(fmt.Sprintf(query, updatedID, "valueUpdated", 1, organisationID, false, "2019-05-03T09:00:00Z"))
+		tx.MustExec(fmt.Sprintf(query, deletedID, "value", 0, organisationID, false, "2019-05-01T09:00:00Z"))
+		tx.MustExec(fmt.Sprintf(query, deletedID, "value", 1, organisationID, true, "2019-05-03T09:00:00Z"))
+		tx.MustExec(fmt.Sprintf(query, laterID, "value", 0, organisationID, false, "2019-05-03T09:00:00Z"))
+	})
+
+	page, err := postgres.NewPaymentRepository(db).List(organisationID, acme.PaymentQuery{
+		Limit: 10,
+		AsOf:  time.Date(2019, 5, 2, 0, 0, 0, 0, time.UTC),
+	})
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{updatedID, deletedID}, paymentIDs(page))
+	for _, payment := range page.Payments {
+		assert.Equal(t, 0, payment.Version)
+	}
+}
+
+func extra(key, value string) map[string]json.RawMessage {
+	return map[string]json.RawMessage{key: json.RawMessage(strconv.Quote(value))}
+}
+
+func paymentIDs(page acme.PaymentPage) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, payment := range page.Payments {
+		ids = append(ids, payment.ID)
+	}
+	return ids
+}
+
+// withoutRecordedAt checks that the database recorded when the payment was stored and clears the time
+// so that the rest of the payment can be compared
+func withoutRecordedAt(t *testing.T, payment acme.Payment) acme.Payment {
+	assert.False(t, payment.RecordedAt.IsZero())
+	payment.RecordedAt = time.Time{}
+	return payment
 }
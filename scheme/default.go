@@ -0,0 +1,48 @@
+package scheme
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/steinfletcher/payments"
+)
+
+// Payment schemes with rules in the default registry
+const (
+	FPS   = "FPS"
+	BACS  = "BACS"
+	CHAPS = "CHAPS"
+	SEPA  = "SEPA"
+)
+
+// NewDefaultRegistry creates a registry with the rules of the payment schemes we make payments through.
+// It fails if the time zone database is not available to find the cut-off time of CHAPS.
+func NewDefaultRegistry(now func() time.Time) (*Registry, error) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the time zone of the CHAPS cut-off")
+	}
+
+	r := NewRegistry(now)
+	r.Register(FPS,
+		Currency("GBP"),
+		MaxAmount(acme.Money{Amount: acme.NewDecimal(1000000, 0), Currency: "GBP"}),
+		MaxReferenceLength(18),
+		SchemePaymentTypes("ImmediatePayment", "ForwardDatedPayment", "StandingOrder"),
+	)
+	r.Register(BACS,
+		Currency("GBP"),
+		SortCodeParties(),
+		MaxReferenceLength(18),
+	)
+	r.Register(CHAPS,
+		Currency("GBP"),
+		CutOff(london, 17, 40),
+	)
+	r.Register(SEPA,
+		Currency("EUR"),
+		IBANParties(),
+		MaxReferenceLength(140),
+	)
+	return r, nil
+}
@@ -0,0 +1,149 @@
+package scheme
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/steinfletcher/payments"
+)
+
+const dateFormat = "2006-01-02"
+
+// Currency only allows payments in one of the currencies
+func Currency(currencies ...string) Rule {
+	return func(a acme.Attributes, _ time.Time) []acme.FieldError {
+		for _, currency := range currencies {
+			if a.Currency == currency {
+				return nil
+			}
+		}
+		return []acme.FieldError{fieldError("currency", "UNSUPPORTED_CURRENCY",
+			fmt.Sprintf("%s payments must be in %s", a.PaymentScheme, strings.Join(currencies, " or ")))}
+	}
+}
+
+// MaxAmount limits the amount of payments in the currency of the limit. An amount that cannot be read is
+// left to the validation of amounts.
+func MaxAmount(limit acme.Money) Rule {
+	return func(a acme.Attributes, _ time.Time) []acme.FieldError {
+		amount, err := acme.ParseDecimal(a.Amount)
+		if err != nil || a.Currency != limit.Currency || amount.Cmp(limit.Amount) <= 0 {
+			return nil
+		}
+		return []acme.FieldError{fieldError("amount", "AMOUNT_LIMIT_EXCEEDED",
+			fmt.Sprintf("%s payments must not be more than %s", a.PaymentScheme, limit))}
+	}
+}
+
+// MaxReferenceLength limits the number of characters of the reference
+func MaxReferenceLength(n int) Rule {
+	return func(a acme.Attributes, _ time.Time) []acme.FieldError {
+		if utf8.RuneCountInString(a.Reference) <= n {
+			return nil
+		}
+		return []acme.FieldError{fieldError("reference", "TOO_LONG",
+			fmt.Sprintf("%s references must be at most %d characters", a.PaymentScheme, n))}
+	}
+}
+
+// SchemePaymentTypes only allows the given scheme payment types
+func SchemePaymentTypes(types ...string) Rule {
+	return func(a acme.Attributes, _ time.Time) []acme.FieldError {
+		for _, t := range types {
+			if a.SchemePaymentType == t {
+				return nil
+			}
+		}
+		return []acme.FieldError{fieldError("scheme_payment_type", "UNSUPPORTED_SCHEME_PAYMENT_TYPE",
+			fmt.Sprintf("%s payments must have a scheme_payment_type of %s", a.PaymentScheme,
+				strings.Join(types, ", ")))}
+	}
+}
+
+// SortCodeParties requires the debtor and the beneficiary to be identified by a UK sort code and an
+// 8 digit account number
+func SortCodeParties() Rule {
+	return func(a acme.Attributes, _ time.Time) []acme.FieldError {
+		var errs []acme.FieldError
+		for _, party := range parties(a) {
+			if party.BankIDCode != "GBDSC" || acme.CheckSortCode(party.BankID) != nil {
+				errs = append(errs, fieldError(party.field+".bank_id", "SORT_CODE_REQUIRED",
+					fmt.Sprintf("%s parties must have a bank_id_code of GBDSC and a 6 digit sort code",
+						a.PaymentScheme)))
+			}
+			if acme.CheckUKAccountNumber(party.AccountNumber) != nil {
+				errs = append(errs, fieldError(party.field+".account_number", "ACCOUNT_NUMBER_REQUIRED",
+					fmt.Sprintf("%s parties must have an 8 digit account number", a.PaymentScheme)))
+			}
+		}
+		return errs
+	}
+}
+
+// IBANParties requires the debtor and the beneficiary to be identified by an IBAN and a BIC
+func IBANParties() Rule {
+	return func(a acme.Attributes, _ time.Time) []acme.FieldError {
+		var errs []acme.FieldError
+		for _, party := range parties(a) {
+			if party.AccountNumberCode != "IBAN" || party.AccountNumber == "" {
+				errs = append(errs, fieldError(party.field+".account_number", "IBAN_REQUIRED",
+					fmt.Sprintf("%s parties must have an account_number_code of IBAN", a.PaymentScheme)))
+			}
+			if party.BankIDCode != "SWBIC" || party.BankID == "" {
+				errs = append(errs, fieldError(party.field+".bank_id", "BIC_REQUIRED",
+					fmt.Sprintf("%s parties must have a bank_id_code of SWBIC", a.PaymentScheme)))
+			}
+		}
+		return errs
+	}
+}
+
+// CutOff requires the processing date to be a business day that is not in the past, and a payment
+// processed today to be made before the cut-off time of the day in the location of the scheme
+func CutOff(location *time.Location, hour, minute int) Rule {
+	return func(a acme.Attributes, now time.Time) []acme.FieldError {
+		date, err := time.ParseInLocation(dateFormat, a.ProcessingDate, location)
+		if err != nil {
+			return []acme.FieldError{fieldError("processing_date", "INVALID_DATE",
+				"processing_date must be a date formatted as YYYY-MM-DD")}
+		}
+
+		now = now.In(location)
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+		cutOff := today.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+		switch {
+		case date.Weekday() == time.Saturday || date.Weekday() == time.Sunday:
+			return []acme.FieldError{fieldError("processing_date", "NOT_A_BUSINESS_DAY",
+				fmt.Sprintf("%s payments are not processed at weekends", a.PaymentScheme))}
+		case date.Before(today):
+			return []acme.FieldError{fieldError("processing_date", "PROCESSING_DATE_PASSED",
+				"processing_date must not be in the past")}
+		case date.Equal(today) && !now.Before(cutOff):
+			return []acme.FieldError{fieldError("processing_date", "CUT_OFF_PASSED",
+				fmt.Sprintf("%s payments for today must be made before %02d:%02d %s", a.PaymentScheme, hour,
+					minute, location))}
+		}
+		return nil
+	}
+}
+
+// party is the debtor or the beneficiary of a payment with the name of its attribute
+type party struct {
+	field string
+	*acme.Party
+}
+
+// parties are the debtor and the beneficiary of a payment. The schema requires both, so a missing party is
+// not reported again.
+func parties(a acme.Attributes) []party {
+	var parties []party
+	if a.DebtorParty != nil {
+		parties = append(parties, party{"debtor_party", a.DebtorParty})
+	}
+	if a.BeneficiaryParty != nil {
+		parties = append(parties, party{"beneficiary_party", a.BeneficiaryParty})
+	}
+	return parties
+}
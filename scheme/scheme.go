@@ -0,0 +1,67 @@
+// Package scheme applies the rules of the payment schemes that payments are made through, e.g. the amount
+// limit of Faster Payments or the cut-off time of CHAPS. The attributes schema only checks the shape of a
+// payment, the rules check that the payment scheme would accept it.
+package scheme
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/steinfletcher/payments"
+)
+
+// Rule checks a constraint of a payment scheme and returns a field error for each attribute that breaks
+// it. now is the current time, for rules that depend on it.
+type Rule func(attributes acme.Attributes, now time.Time) []acme.FieldError
+
+// Registry holds the rules of each payment scheme, keyed by the payment_scheme attribute. A payment whose
+// scheme has no rules is not checked.
+type Registry struct {
+	rules map[string][]Rule
+	now   func() time.Time
+}
+
+// NewRegistry creates an empty registry. now is the clock that rules are given the current time by.
+func NewRegistry(now func() time.Time) *Registry {
+	return &Registry{rules: map[string][]Rule{}, now: now}
+}
+
+// Register adds rules to a payment scheme
+func (r *Registry) Register(scheme string, rules ...Rule) {
+	r.rules[scheme] = append(r.rules[scheme], rules...)
+}
+
+// Schemes lists the payment schemes that have rules in alphabetical order
+func (r *Registry) Schemes() []string {
+	var schemes []string
+	for scheme := range r.rules {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Validate applies the rules of the payment scheme of the attributes and returns every field error found
+func (r *Registry) Validate(attributes acme.Attributes) []acme.FieldError {
+	now := r.now()
+	var errs []acme.FieldError
+	for _, rule := range r.rules[attributes.PaymentScheme] {
+		errs = append(errs, rule(attributes, now)...)
+	}
+	return errs
+}
+
+// fieldError is a field error of an attribute, where field is the dotted path of the attribute, e.g.
+// debtor_party.bank_id
+func fieldError(field, code, message string) acme.FieldError {
+	pointer := "/attributes"
+	for _, token := range strings.Split(field, ".") {
+		pointer += "/" + acme.EscapePointer(token)
+	}
+	return acme.FieldError{
+		Pointer: pointer,
+		Code:    code,
+		Message: message,
+	}
+}
@@ -0,0 +1,194 @@
+package scheme_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/steinfletcher/payments"
+	"github.com/steinfletcher/payments/scheme"
+	"github.com/stretchr/testify/assert"
+)
+
+// now is a Wednesday afternoon in London, which is an hour ahead of UTC in June
+var now = time.Date(2019, 6, 19, 14, 0, 0, 0, time.UTC)
+
+func TestRegistry_AppliesTheRulesOfTheScheme(t *testing.T) {
+	registry := scheme.NewRegistry(func() time.Time { return now })
+	var given time.Time
+	registry.Register("FPS", func(a acme.Attributes, now time.Time) []acme.FieldError {
+		given = now
+		return []acme.FieldError{{Pointer: "/attributes/reference", Code: "FPS_RULE"}}
+	})
+	registry.Register("BACS", func(acme.Attributes, time.Time) []acme.FieldError {
+		return []acme.FieldError{{Pointer: "/attributes/reference", Code: "BACS_RULE"}}
+	})
+
+	errs := registry.Validate(acme.Attributes{PaymentScheme: "FPS"})
+
+	assert.Equal(t, []acme.FieldError{{Pointer: "/attributes/reference", Code: "FPS_RULE"}}, errs)
+	assert.Equal(t, now, given)
+	assert.Empty(t, registry.Validate(acme.Attributes{PaymentScheme: "SWIFT"}))
+	assert.Equal(t, []string{"BACS", "FPS"}, registry.Schemes())
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	tests := map[string]struct {
+		attributes acme.Attributes
+		errs       []string
+	}{
+		"valid FPS": {
+			attributes: fps(),
+		},
+		"FPS amount limit": {
+			attributes: with(fps(), func(a *acme.Attributes) { a.Amount = "1000000.01" }),
+			errs:       []string{"/attributes/amount AMOUNT_LIMIT_EXCEEDED"},
+		},
+		"FPS reference": {
+			attributes: with(fps(), func(a *acme.Attributes) { a.Reference = "Payment for Em's piano" }),
+			errs:       []string{"/attributes/reference TOO_LONG"},
+		},
+		"FPS currency and scheme payment type": {
+			attributes: with(fps(), func(a *acme.Attributes) {
+				a.Currency = "EUR"
+				a.SchemePaymentType = "Cheque"
+			}),
+			errs: []string{"/attributes/currency UNSUPPORTED_CURRENCY",
+				"/attributes/scheme_payment_type UNSUPPORTED_SCHEME_PAYMENT_TYPE"},
+		},
+		"valid BACS": {
+			attributes: bacs(),
+		},
+		"BACS party without a sort code": {
+			attributes: with(bacs(), func(a *acme.Attributes) {
+				a.DebtorParty = &acme.Party{AccountNumber: "GB83XABC10161234567801", AccountNumberCode: "IBAN",
+					BankID: "XABCGB2L", BankIDCode: "SWBIC"}
+			}),
+			errs: []string{"/attributes/debtor_party/bank_id SORT_CODE_REQUIRED",
+				"/attributes/debtor_party/account_number ACCOUNT_NUMBER_REQUIRED"},
+		},
+		"valid SEPA": {
+			attributes: sepa(),
+		},
+		"SEPA in GBP with sort codes": {
+			attributes: with(sepa(), func(a *acme.Attributes) {
+				a.Currency = "GBP"
+				a.BeneficiaryParty = &acme.Party{AccountNumber: "31926819", AccountNumberCode: "BBAN",
+					BankID: "403000", BankIDCode: "GBDSC"}
+			}),
+			errs: []string{"/attributes/currency UNSUPPORTED_CURRENCY",
+				"/attributes/beneficiary_party/account_number IBAN_REQUIRED",
+				"/attributes/beneficiary_party/bank_id BIC_REQUIRED"},
+		},
+		"CHAPS today before the cut-off": {
+			attributes: chaps("2019-06-19"),
+		},
+		"CHAPS in the future": {
+			attributes: chaps("2019-06-20"),
+		},
+		"CHAPS in the past": {
+			attributes: chaps("2019-06-18"),
+			errs:       []string{"/attributes/processing_date PROCESSING_DATE_PASSED"},
+		},
+		"CHAPS at a weekend": {
+			attributes: chaps("2019-06-22"),
+			errs:       []string{"/attributes/processing_date NOT_A_BUSINESS_DAY"},
+		},
+		"CHAPS with a malformed date": {
+			attributes: chaps("19/06/2019"),
+			errs:       []string{"/attributes/processing_date INVALID_DATE"},
+		},
+	}
+	registry, err := scheme.NewDefaultRegistry(func() time.Time { return now })
+	assert.NoError(t, err)
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.errs, describe(registry.Validate(tt.attributes)))
+		})
+	}
+}
+
+func TestDefaultRegistry_CHAPSCutOff(t *testing.T) {
+	tests := map[string]struct {
+		now  time.Time
+		errs []string
+	}{
+		"before":      {time.Date(2019, 6, 19, 16, 39, 59, 0, time.UTC), nil},
+		"at":          {time.Date(2019, 6, 19, 16, 40, 0, 0, time.UTC), []string{"/attributes/processing_date CUT_OFF_PASSED"}},
+		"winter time": {time.Date(2019, 12, 18, 17, 39, 0, 0, time.UTC), nil},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			registry, err := scheme.NewDefaultRegistry(func() time.Time { return tt.now })
+			assert.NoError(t, err)
+
+			errs := registry.Validate(chaps(tt.now.Format("2006-01-02")))
+
+			assert.Equal(t, tt.errs, describe(errs))
+		})
+	}
+}
+
+func TestDefaultRegistry_Messages(t *testing.T) {
+	registry, err := scheme.NewDefaultRegistry(func() time.Time { return now })
+	assert.NoError(t, err)
+
+	errs := registry.Validate(with(fps(), func(a *acme.Attributes) { a.Amount = "2000000.00" }))
+
+	assert.Equal(t, "FPS payments must not be more than 1000000.00 GBP", errs[0].Message)
+}
+
+func fps() acme.Attributes {
+	return acme.Attributes{
+		Amount:            "100.21",
+		Currency:          "GBP",
+		PaymentScheme:     scheme.FPS,
+		Reference:         "Em's piano lessons",
+		SchemePaymentType: "ImmediatePayment",
+	}
+}
+
+func bacs() acme.Attributes {
+	return acme.Attributes{
+		Amount:           "100.21",
+		Currency:         "GBP",
+		PaymentScheme:    scheme.BACS,
+		Reference:        "Em's piano lessons",
+		DebtorParty:      &acme.Party{AccountNumber: "12345678", BankID: "203301", BankIDCode: "GBDSC"},
+		BeneficiaryParty: &acme.Party{AccountNumber: "31926819", BankID: "403000", BankIDCode: "GBDSC"},
+	}
+}
+
+func sepa() acme.Attributes {
+	return acme.Attributes{
+		Amount:        "100.21",
+		Currency:      "EUR",
+		PaymentScheme: scheme.SEPA,
+		DebtorParty: &acme.Party{AccountNumber: "DE89370400440532013000", AccountNumberCode: "IBAN",
+			BankID: "COBADEFFXXX", BankIDCode: "SWBIC"},
+		BeneficiaryParty: &acme.Party{AccountNumber: "FR1420041010050500013M02606", AccountNumberCode: "IBAN",
+			BankID: "PSSTFRPPPAR", BankIDCode: "SWBIC"},
+	}
+}
+
+func chaps(processingDate string) acme.Attributes {
+	return acme.Attributes{
+		Amount:         "100.21",
+		Currency:       "GBP",
+		PaymentScheme:  scheme.CHAPS,
+		ProcessingDate: processingDate,
+	}
+}
+
+func with(a acme.Attributes, change func(*acme.Attributes)) acme.Attributes {
+	change(&a)
+	return a
+}
+
+// describe lists the pointer and code of each field error
+func describe(errs []acme.FieldError) []string {
+	var descriptions []string
+	for _, err := range errs {
+		descriptions = append(descriptions, err.Pointer+" "+err.Code)
+	}
+	return descriptions
+}
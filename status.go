@@ -0,0 +1,91 @@
+package acme
+
+// Status is the stage of its lifecycle a payment is at. A payment is created pending and moves between
+// statuses through the actions in the transition table.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusApproved  Status = "approved"
+	StatusSubmitted Status = "submitted"
+	StatusSettled   Status = "settled"
+	StatusRejected  Status = "rejected"
+	StatusReturned  Status = "returned"
+	StatusCancelled Status = "cancelled"
+)
+
+// Statuses lists every status of the payment lifecycle
+var Statuses = []Status{
+	StatusPending, StatusApproved, StatusSubmitted, StatusSettled, StatusRejected, StatusReturned, StatusCancelled,
+}
+
+// Valid is whether the status is one of Statuses
+func (s Status) Valid() bool {
+	for _, status := range Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Action moves a payment from one status to another
+type Action string
+
+const (
+	ActionApprove Action = "approve"
+	ActionSubmit  Action = "submit"
+	ActionSettle  Action = "settle"
+	ActionReject  Action = "reject"
+	ActionReturn  Action = "return"
+	ActionCancel  Action = "cancel"
+)
+
+// Transition is a row of the transition table. The action moves a payment with the From status to the
+// To status.
+type Transition struct {
+	Action Action
+	From   Status
+	To     Status
+}
+
+// Transitions is the transition table of the payment lifecycle. A payment is approved, submitted to the
+// payment scheme and settled by it. It can be rejected until it has settled, cancelled until it has been
+// submitted and returned by the beneficiary bank once it has settled.
+var Transitions = []Transition{
+	{ActionApprove, StatusPending, StatusApproved},
+	{ActionSubmit, StatusApproved, StatusSubmitted},
+	{ActionSettle, StatusSubmitted, StatusSettled},
+	{ActionReject, StatusPending, StatusRejected},
+	{ActionReject, StatusApproved, StatusRejected},
+	{ActionReject, StatusSubmitted, StatusRejected},
+	{ActionReturn, StatusSettled, StatusReturned},
+	{ActionCancel, StatusPending, StatusCancelled},
+	{ActionCancel, StatusApproved, StatusCancelled},
+}
+
+// Actions lists every action of the transition table once, in the order of the table
+func Actions() []Action {
+	var actions []Action
+	seen := map[Action]bool{}
+	for _, t := range Transitions {
+		if !seen[t.Action] {
+			seen[t.Action] = true
+			actions = append(actions, t.Action)
+		}
+	}
+	return actions
+}
+
+// Next is the status a payment with the given status moves to when the action is taken. It is
+// InvalidTransition if the transition table does not allow the action from that status.
+func (s Status) Next(action Action) (Status, error) {
+	for _, t := range Transitions {
+		if t.Action == action && t.From == s {
+			return t.To, nil
+		}
+	}
+	err := InvalidTransition
+	err.Detail = "A " + string(s) + " payment cannot " + string(action)
+	return "", err
+}
@@ -0,0 +1,65 @@
+package acme_test
+
+import (
+	"testing"
+
+	"github.com/steinfletcher/payments"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus_Next(t *testing.T) {
+	tests := []struct {
+		from   acme.Status
+		action acme.Action
+		to     acme.Status
+	}{
+		{acme.StatusPending, acme.ActionApprove, acme.StatusApproved},
+		{acme.StatusApproved, acme.ActionSubmit, acme.StatusSubmitted},
+		{acme.StatusSubmitted, acme.ActionSettle, acme.StatusSettled},
+		{acme.StatusSubmitted, acme.ActionReject, acme.StatusRejected},
+		{acme.StatusSettled, acme.ActionReturn, acme.StatusReturned},
+		{acme.StatusApproved, acme.ActionCancel, acme.StatusCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+" "+string(tt.action), func(t *testing.T) {
+			to, err := tt.from.Next(tt.action)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.to, to)
+		})
+	}
+}
+
+func TestStatus_NextInvalidTransition(t *testing.T) {
+	tests := []struct {
+		from   acme.Status
+		action acme.Action
+	}{
+		{acme.StatusPending, acme.ActionSubmit},
+		{acme.StatusApproved, acme.ActionApprove},
+		{acme.StatusSubmitted, acme.ActionCancel},
+		{acme.StatusSettled, acme.ActionReject},
+		{acme.StatusReturned, acme.ActionReturn},
+		{acme.StatusCancelled, acme.ActionApprove},
+		{acme.StatusPending, acme.Action("unknown")},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+" "+string(tt.action), func(t *testing.T) {
+			_, err := tt.from.Next(tt.action)
+
+			assert.Equal(t, acme.InvalidTransition.Code, err.(acme.Error).Code)
+			assert.Equal(t, "A "+string(tt.from)+" payment cannot "+string(tt.action), err.(acme.Error).Detail)
+		})
+	}
+}
+
+func TestActions(t *testing.T) {
+	assert.Equal(t, []acme.Action{acme.ActionApprove, acme.ActionSubmit, acme.ActionSettle, acme.ActionReject,
+		acme.ActionReturn, acme.ActionCancel}, acme.Actions())
+}
+
+func TestStatus_Valid(t *testing.T) {
+	assert.True(t, acme.StatusSettled.Valid())
+	assert.False(t, acme.Status("paid").Valid())
+	assert.False(t, acme.Status("").Valid())
+}
@@ -21,7 +21,7 @@ func tearDown(db *sqlx.DB) {
 		panic(err)
 	}
 
-	db.MustExec(`TRUNCATE TABLE payments`)
+	db.MustExec(`TRUNCATE TABLE payments, idempotency_keys, api_keys, approvals, approval_thresholds`)
 
 	err = tx.Commit()
 	if err != nil {